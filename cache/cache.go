@@ -5,14 +5,10 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/coocood/freecache"
 	"github.com/piyuo/libsrv/digit"
 	"github.com/pkg/errors"
 )
 
-// In bytes, where 1024 * 1024 represents a single Megabyte, and 20 * 1024*1024 represents 20 Megabytes.
-var cache = freecache.NewCache(20 * 1024 * 1024)
-
 // defaultDuration is 20 minutes
 const defaultDuration = 20 * time.Minute
 
@@ -29,7 +25,7 @@ func Set(key string, value []byte, d time.Duration) error {
 	}
 
 	fmt.Printf("%v cached\n", key)
-	return cache.Set([]byte(key), value, int(d.Seconds()))
+	return current().Set(namespaceKey(key), value, d)
 }
 
 // Set an entry to the cache, replacing any existing entry. If the duration is 0 (DefaultExpiration), the cache's default 20 minutes is used
@@ -43,7 +39,7 @@ func ISet(key int64, value []byte, d time.Duration) error {
 	if d == -1 {
 		return nil
 	}
-	return cache.SetInt(key, value, int(d.Seconds()))
+	return current().Set(intKey(key), value, d)
 }
 
 // Get an entry from the cache. Returns the item or nil, and a bool indicating whether the key was found.
@@ -51,14 +47,11 @@ func ISet(key int64, value []byte, d time.Duration) error {
 //	found, bytes, err := Get("key1")
 //
 func Get(key string) (bool, []byte, error) {
-	value, err := cache.Get([]byte(key))
-	if err != nil {
-		if err == freecache.ErrNotFound {
-			return false, nil, nil
-		}
-		return false, nil, err
+	found, value, err := current().Get(namespaceKey(key))
+	if err == nil {
+		recordGet(key, found)
 	}
-	return true, value, nil
+	return found, value, err
 }
 
 // Get an entry from the cache. Returns the item or nil, and a bool indicating whether the key was found.
@@ -66,14 +59,19 @@ func Get(key string) (bool, []byte, error) {
 //	found, bytes, err := IGet(123)
 //
 func IGet(key int64) (bool, []byte, error) {
-	value, err := cache.GetInt(key)
-	if err != nil {
-		if err == freecache.ErrNotFound {
-			return false, nil, nil
-		}
-		return false, nil, err
+	found, value, err := current().Get(intKey(key))
+	if err == nil {
+		recordGet("", found)
 	}
-	return true, value, nil
+	return found, value, err
+}
+
+// intKey convert int64 key to string key, it is the same key freecache used for int key
+//
+func intKey(key int64) string {
+	bytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bytes, uint64(key))
+	return string(bytes)
 }
 
 // SetString set string entry to the cache, replacing any existing entry. If the duration is 0 (DefaultExpiration), the cache's default 20 minutes is used
@@ -130,7 +128,7 @@ func GetInt64(key string) (bool, int64, error) {
 //	Delete("key1")
 //
 func Delete(key string) {
-	cache.Del([]byte(key))
+	current().Delete(namespaceKey(key))
 }
 
 // IDelete delete entry from cache
@@ -138,7 +136,7 @@ func Delete(key string) {
 //	IDelete(123)
 //
 func IDelete(key int64) {
-	cache.DelInt(key)
+	current().Delete(intKey(key))
 }
 
 // Count return cache entry total count
@@ -146,7 +144,8 @@ func IDelete(key int64) {
 //	count := Count()
 //
 func Count() int64 {
-	return cache.EntryCount()
+	count, _ := current().Count()
+	return count
 }
 
 // GzipSet compress byte array before set an entry to the cache
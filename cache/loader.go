@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"encoding/binary"
+	"strconv"
+	"time"
+
+	"github.com/piyuo/libsrv/digit"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound return by loader when value not exist, not found result can be cached so loader will not be called again until it expired
+//
+//	return nil, cache.ErrNotFound
+//
+var ErrNotFound = errors.New("not found")
+
+// LoadFunc load value when cache miss, return ErrNotFound if value not exist
+//
+//	func() ([]byte, error) {
+//		return file.Read(...)
+//	}
+//
+type LoadFunc func() ([]byte, error)
+
+// LoadOption define how loaded value is cached
+//
+type LoadOption struct {
+
+	// NotFound is how long not found result is cached, 0 mean not found result is not cached
+	//
+	NotFound time.Duration
+
+	// Stale is how long expired value can still be returned while value reload in background, 0 mean expired value is never returned
+	//
+	Stale time.Duration
+}
+
+// entryHeaderSize is size of loaded entry header, 1 byte found flag and 8 bytes fresh time
+//
+const entryHeaderSize = 9
+
+// group deduplicate concurrent load on the same key
+//
+var group singleflight.Group
+
+// loaded is result of loader
+//
+type loaded struct {
+	found bool
+	value []byte
+}
+
+// GetOrLoad return value from cache, call loader to load value if cache miss. concurrent call on the same key only call loader once.
+// not found result is cached for ttl, if ttl is 0 cache's default 20 minutes is used, if ttl is -1 there is no cache. value is cached with header, only read it using GetOrLoad() or Load()
+//
+//	found, bytes, err := GetOrLoad("key1", time.Minute, func() ([]byte, error) {
+//		return []byte("hi"), nil
+//	})
+//
+func GetOrLoad(key string, ttl time.Duration, loader LoadFunc) (bool, []byte, error) {
+	return Load(key, ttl, LoadOption{NotFound: ttl}, loader)
+}
+
+// Load return value from cache, call loader to load value if cache miss. concurrent call on the same key only call loader once.
+// option define how not found result and expired value is cached
+//
+//	found, bytes, err := Load("key1", time.Minute, LoadOption{NotFound: 10*time.Second, Stale: time.Hour}, loader)
+//
+func Load(key string, ttl time.Duration, option LoadOption, loader LoadFunc) (bool, []byte, error) {
+	if ttl == -1 {
+		return callLoader(loader)
+	}
+	if ttl == 0 {
+		ttl = defaultDuration
+	}
+
+	found, data, err := Get(key)
+	if err != nil {
+		return false, nil, errors.Wrap(err, "get "+key)
+	}
+	if found {
+		if entryFound, value, fresh, ok := decodeEntry(data); ok {
+			if time.Now().Before(fresh) {
+				return entryFound, value, nil
+			}
+			if option.Stale > 0 {
+				// serve stale value, reload in background
+				group.DoChan(key, func() (interface{}, error) {
+					return load(key, ttl, option, loader)
+				})
+				return entryFound, value, nil
+			}
+		}
+	}
+
+	result, err, _ := group.Do(key, func() (interface{}, error) {
+		return load(key, ttl, option, loader)
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	l := result.(*loaded)
+	return l.found, l.value, nil
+}
+
+// load call loader and cache result
+//
+func load(key string, ttl time.Duration, option LoadOption, loader LoadFunc) (*loaded, error) {
+	found, value, err := callLoader(loader)
+	if err != nil {
+		return nil, err
+	}
+	d := ttl
+	if !found {
+		if option.NotFound <= 0 {
+			return &loaded{}, nil
+		}
+		d = option.NotFound
+	}
+	if err := Set(key, encodeEntry(found, value, time.Now().Add(d)), d+option.Stale); err != nil {
+		return nil, errors.Wrap(err, "set "+key)
+	}
+	return &loaded{found: found, value: value}, nil
+}
+
+// callLoader call loader and convert ErrNotFound to not found result
+//
+func callLoader(loader LoadFunc) (bool, []byte, error) {
+	value, err := loader()
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+	return true, value, nil
+}
+
+// encodeEntry encode loaded value with header
+//
+func encodeEntry(found bool, value []byte, fresh time.Time) []byte {
+	data := make([]byte, entryHeaderSize, entryHeaderSize+len(value))
+	if found {
+		data[0] = 1
+	}
+	binary.LittleEndian.PutUint64(data[1:], uint64(fresh.UnixNano()))
+	return append(data, value...)
+}
+
+// decodeEntry decode loaded value, return false if data is not loaded value
+//
+func decodeEntry(data []byte) (bool, []byte, time.Time, bool) {
+	if len(data) < entryHeaderSize || data[0] > 1 {
+		return false, nil, time.Time{}, false
+	}
+	fresh := time.Unix(0, int64(binary.LittleEndian.Uint64(data[1:entryHeaderSize])))
+	if data[0] == 0 {
+		return false, nil, fresh, true
+	}
+	return true, data[entryHeaderSize:], fresh, true
+}
+
+// GetOrLoadString return string from cache, call loader to load string if cache miss
+//
+//	found, str, err := GetOrLoadString("key1", time.Minute, func() (string, error) {
+//		return "hi", nil
+//	})
+//
+func GetOrLoadString(key string, ttl time.Duration, loader func() (string, error)) (bool, string, error) {
+	found, value, err := GetOrLoad(key, ttl, func() ([]byte, error) {
+		str, err := loader()
+		return []byte(str), err
+	})
+	if err != nil || !found {
+		return false, "", err
+	}
+	return true, string(value), nil
+}
+
+// GetOrLoadInt64 return int64 from cache, call loader to load int64 if cache miss
+//
+//	found, num, err := GetOrLoadInt64("key1", time.Minute, func() (int64, error) {
+//		return 1, nil
+//	})
+//
+func GetOrLoadInt64(key string, ttl time.Duration, loader func() (int64, error)) (bool, int64, error) {
+	found, value, err := GetOrLoad(key, ttl, func() ([]byte, error) {
+		num, err := loader()
+		return []byte(strconv.FormatInt(num, 10)), err
+	})
+	if err != nil || !found {
+		return false, -1, err
+	}
+	num, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return false, -1, errors.Wrap(err, "parse "+key)
+	}
+	return true, num, nil
+}
+
+// GzipGetOrLoad return bytes from cache, call loader to load bytes if cache miss, bytes is compressed in cache
+//
+//	found, bytes, err := GzipGetOrLoad("key1", time.Minute, func() ([]byte, error) {
+//		return file.Read(...)
+//	})
+//
+func GzipGetOrLoad(key string, ttl time.Duration, loader LoadFunc) (bool, []byte, error) {
+	found, zipped, err := GetOrLoad(key, ttl, func() ([]byte, error) {
+		value, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		zipped, err := digit.Compress(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "compress")
+		}
+		return zipped, nil
+	})
+	if err != nil || !found {
+		return false, nil, err
+	}
+	value, err := digit.Decompress(zipped)
+	if err != nil {
+		return false, nil, errors.Wrap(err, "decompress")
+	}
+	return true, value, nil
+}
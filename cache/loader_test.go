@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/identifier"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	key := "load-" + identifier.UUID()
+	calls := 0
+	loader := func() ([]byte, error) {
+		calls++
+		return []byte("hi"), nil
+	}
+	found, value, err := GetOrLoad(key, time.Minute, loader)
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal([]byte("hi"), value)
+	found, value, err = GetOrLoad(key, time.Minute, loader)
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal([]byte("hi"), value)
+	assert.Equal(1, calls)
+
+	// delete to invalidate
+	Delete(key)
+	_, _, err = GetOrLoad(key, time.Minute, loader)
+	assert.Nil(err)
+	assert.Equal(2, calls)
+
+	// loader error is not cached
+	errKey := "load-err-" + identifier.UUID()
+	_, _, err = GetOrLoad(errKey, time.Minute, func() ([]byte, error) {
+		return nil, errors.New("failed")
+	})
+	assert.NotNil(err)
+	found, _, err = GetOrLoad(errKey, time.Minute, loader)
+	assert.Nil(err)
+	assert.True(found)
+
+	// no cache
+	noKey := "load-no-" + identifier.UUID()
+	GetOrLoad(noKey, -1, loader)
+	GetOrLoad(noKey, -1, loader)
+	assert.Equal(5, calls)
+}
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	key := "load-flight-" + identifier.UUID()
+	var calls int32
+	loader := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		return []byte("hi"), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found, value, err := GetOrLoad(key, time.Minute, loader)
+			if err != nil || !found || string(value) != "hi" {
+				t.Errorf("load failed, found %v value %s err %v", found, value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGetOrLoadNotFound(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	key := "load-not-found-" + identifier.UUID()
+	calls := 0
+	loader := func() ([]byte, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+	found, value, err := GetOrLoad(key, time.Minute, loader)
+	assert.Nil(err)
+	assert.False(found)
+	assert.Nil(value)
+	found, _, err = GetOrLoad(key, time.Minute, loader)
+	assert.Nil(err)
+	assert.False(found)
+	assert.Equal(1, calls)
+
+	// not cache not found
+	key = "load-not-found-" + identifier.UUID()
+	Load(key, time.Minute, LoadOption{}, loader)
+	Load(key, time.Minute, LoadOption{}, loader)
+	assert.Equal(3, calls)
+}
+
+func TestGetOrLoadStale(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	key := "load-stale-" + identifier.UUID()
+	var calls int32
+	reloaded := make(chan bool, 1)
+	loader := func() ([]byte, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return []byte("v1"), nil
+		}
+		defer func() { reloaded <- true }()
+		return []byte("v2"), nil
+	}
+	option := LoadOption{Stale: time.Minute}
+	_, value, err := Load(key, 100*time.Millisecond, option, loader)
+	assert.Nil(err)
+	assert.Equal([]byte("v1"), value)
+
+	// expired value returned while reload in background
+	time.Sleep(150 * time.Millisecond)
+	_, value, err = Load(key, 100*time.Millisecond, option, loader)
+	assert.Nil(err)
+	assert.Equal([]byte("v1"), value)
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("value not reloaded")
+	}
+	time.Sleep(10 * time.Millisecond)
+	_, value, err = Load(key, 100*time.Millisecond, option, loader)
+	assert.Nil(err)
+	assert.Equal([]byte("v2"), value)
+
+	// without stale, expired value will be reloaded
+	key = "load-expired-" + identifier.UUID()
+	calls = 1
+	Load(key, 100*time.Millisecond, LoadOption{}, loader)
+	<-reloaded
+	time.Sleep(150 * time.Millisecond)
+	Load(key, 100*time.Millisecond, LoadOption{}, loader)
+	<-reloaded
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestGetOrLoadTyped(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	found, str, err := GetOrLoadString("load-str-"+identifier.UUID(), time.Minute, func() (string, error) {
+		return "hi", nil
+	})
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal("hi", str)
+
+	found, num, err := GetOrLoadInt64("load-int-"+identifier.UUID(), time.Minute, func() (int64, error) {
+		return 65536, nil
+	})
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal(int64(65536), num)
+
+	found, num, err = GetOrLoadInt64("load-int-"+identifier.UUID(), time.Minute, func() (int64, error) {
+		return 0, ErrNotFound
+	})
+	assert.Nil(err)
+	assert.False(found)
+	assert.Equal(int64(-1), num)
+
+	key := "load-gzip-" + identifier.UUID()
+	for i := 0; i < 2; i++ {
+		found, value, err := GzipGetOrLoad(key, time.Minute, func() ([]byte, error) {
+			return []byte("hello gzip"), nil
+		})
+		assert.Nil(err)
+		assert.True(found)
+		assert.Equal([]byte("hello gzip"), value)
+	}
+}
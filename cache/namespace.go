@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// generationKey is store key prefix to keep namespace generation, so instances share the same store see the same generation
+//
+const generationKey = "#generation-"
+
+// generationRefresh is how long generation read from store is trusted before read again
+//
+const generationRefresh = time.Second
+
+// generationDuration is how long generation is kept in store
+//
+const generationDuration = 365 * 24 * time.Hour
+
+// generation is namespace generation read from store
+//
+type generation struct {
+
+	// value is generation, 0 mean namespace never invalidated
+	//
+	value int64
+
+	// checked is time generation read from store
+	//
+	checked time.Time
+}
+
+// maxGenerations is max namespace generation kept in memory, namespace never invalidated is removed first when limit reached, so key like uuid won't grow generations unbounded
+//
+const maxGenerations = 1000
+
+// generations is generation by namespace prefix
+//
+var generations = map[string]*generation{}
+
+// generationsMutex protect generations
+//
+var generationsMutex sync.Mutex
+
+// InvalidateNamespace invalidate all entry which key start with prefix, prefix is key up to and including first "-" like "m-".
+// entry is not deleted, namespace generation is increased so old entry will never be read again and will expire by itself
+//
+//	err := InvalidateNamespace("m-")
+//
+func InvalidateNamespace(prefix string) error {
+	if prefix == "" || prefixOf(prefix) != prefix {
+		return errors.New("invalid namespace " + prefix)
+	}
+	value := time.Now().UnixNano()
+	if last := generationOf(prefix); value <= last {
+		value = last + 1
+	}
+	if err := current().Set(generationKey+prefix, []byte(strconv.FormatInt(value, 10)), generationDuration); err != nil {
+		return errors.Wrap(err, "set generation "+prefix)
+	}
+	generationsMutex.Lock()
+	defer generationsMutex.Unlock()
+	generations[prefix] = &generation{value: value, checked: time.Now()}
+	return nil
+}
+
+// generationOf return namespace generation, generation in memory is never decreased even generation in store is evicted
+//
+func generationOf(prefix string) int64 {
+	generationsMutex.Lock()
+	g := generations[prefix]
+	generationsMutex.Unlock()
+	if g != nil && time.Since(g.checked) < generationRefresh {
+		return g.value
+	}
+
+	var value int64
+	if found, data, err := current().Get(generationKey + prefix); err == nil && found {
+		value, _ = strconv.ParseInt(string(data), 10, 64)
+	}
+	generationsMutex.Lock()
+	defer generationsMutex.Unlock()
+	if g = generations[prefix]; g != nil && g.value > value {
+		value = g.value
+	}
+	if g == nil && value == 0 && len(generations) >= maxGenerations {
+		cleanupGenerations()
+		if len(generations) >= maxGenerations {
+			// namespace never invalidated is read from store next time
+			return value
+		}
+	}
+	generations[prefix] = &generation{value: value, checked: time.Now()}
+	return value
+}
+
+// cleanupGenerations remove expired generation of namespace never invalidated, it will be read from store again when needed. caller must hold generationsMutex
+//
+func cleanupGenerations() {
+	for prefix, g := range generations {
+		if g.value == 0 && time.Since(g.checked) >= generationRefresh {
+			delete(generations, prefix)
+		}
+	}
+}
+
+// namespaceKey return key in current namespace generation, key without prefix or namespace never invalidated is returned as is
+//
+//	key := namespaceKey("m-welcome") // "m-<generation>#welcome" after InvalidateNamespace("m-")
+//
+func namespaceKey(key string) string {
+	prefix := prefixOf(key)
+	if prefix == "" {
+		return key
+	}
+	value := generationOf(prefix)
+	if value == 0 {
+		return key
+	}
+	return prefix + strconv.FormatInt(value, 36) + "#" + key[len(prefix):]
+}
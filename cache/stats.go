@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Stat is cache counter of key prefix
+//
+type Stat struct {
+
+	// Prefix is key prefix like "m-", empty prefix is key without prefix, "other" is key of prefix not counted separately
+	//
+	Prefix string `json:"prefix"`
+
+	// Hits is count of get found entry
+	//
+	Hits int64 `json:"hits"`
+
+	// Misses is count of get not found entry
+	//
+	Misses int64 `json:"misses"`
+
+	// Evictions is count of entry evicted by store to make room for new entry, only store can report eviction like LRUStore is counted
+	//
+	Evictions int64 `json:"evictions"`
+}
+
+// counter keep cache counter of key prefix, use atomic to update
+//
+type counter struct {
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// maxStatPrefixes is max key prefix counted separately, key of new prefix is counted in otherPrefix once limit reached, so key like uuid won't grow counters unbounded
+//
+const maxStatPrefixes = 100
+
+// otherPrefix is prefix of counter count key after maxStatPrefixes reached
+//
+const otherPrefix = "other"
+
+// counters is counter by key prefix
+//
+var counters = map[string]*counter{}
+
+// countersMutex protect counters
+//
+var countersMutex sync.RWMutex
+
+// evictNotifier is store can report evicted key
+//
+type evictNotifier interface {
+	OnEvict(f func(key string))
+}
+
+// prefixOf return key prefix, prefix is key up to and including first "-", like "m-" in "m-welcome"
+//
+//	prefix := prefixOf("m-welcome") // "m-"
+//
+func prefixOf(key string) string {
+	if i := strings.IndexByte(key, '-'); i >= 0 {
+		return key[:i+1]
+	}
+	return ""
+}
+
+// counterOf return counter of key prefix, create one if not exist, return counter of otherPrefix if maxStatPrefixes reached
+//
+func counterOf(key string) *counter {
+	prefix := prefixOf(key)
+	countersMutex.RLock()
+	c := counters[prefix]
+	countersMutex.RUnlock()
+	if c != nil {
+		return c
+	}
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	if c = counters[prefix]; c != nil {
+		return c
+	}
+	if len(counters) >= maxStatPrefixes {
+		prefix = otherPrefix
+		if c = counters[prefix]; c != nil {
+			return c
+		}
+	}
+	c = &counter{}
+	counters[prefix] = c
+	return c
+}
+
+// recordGet count hit or miss of key
+//
+func recordGet(key string, found bool) {
+	if found {
+		atomic.AddInt64(&counterOf(key).hits, 1)
+		return
+	}
+	atomic.AddInt64(&counterOf(key).misses, 1)
+}
+
+// recordEviction count eviction of key
+//
+func recordEviction(key string) {
+	atomic.AddInt64(&counterOf(key).evictions, 1)
+}
+
+// Stats return cache counter by key prefix, sorted by prefix
+//
+//	stats := Stats()
+//	fmt.Println(stats[0].Prefix, stats[0].Hits)
+//
+func Stats() []Stat {
+	countersMutex.RLock()
+	defer countersMutex.RUnlock()
+	stats := make([]Stat, 0, len(counters))
+	for prefix, c := range counters {
+		stats = append(stats, Stat{
+			Prefix:    prefix,
+			Hits:      atomic.LoadInt64(&c.hits),
+			Misses:    atomic.LoadInt64(&c.misses),
+			Evictions: atomic.LoadInt64(&c.evictions),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Prefix < stats[j].Prefix
+	})
+	return stats
+}
+
+// ResetStats clear all cache counter
+//
+//	ResetStats()
+//
+func ResetStats() {
+	countersMutex.Lock()
+	defer countersMutex.Unlock()
+	counters = map[string]*counter{}
+}
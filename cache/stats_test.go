@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// statOf return stat of prefix, return empty stat if not found
+//
+func statOf(prefix string) Stat {
+	for _, stat := range Stats() {
+		if stat.Prefix == prefix {
+			return stat
+		}
+	}
+	return Stat{Prefix: prefix}
+}
+
+func TestPrefixOf(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.Equal("m-", prefixOf("m-welcome"))
+	assert.Equal("m-", prefixOf("m-a-b"))
+	assert.Equal("", prefixOf("welcome"))
+	assert.Equal("", prefixOf(""))
+}
+
+func TestStats(t *testing.T) {
+	assert := assert.New(t)
+	lru := NewLRUStore(2, 0)
+	Use(lru)
+	defer Use(NewFreecacheStore(defaultStoreSize))
+	ResetStats()
+
+	Set("stat-a", []byte("1"), 0)
+	Get("stat-a")
+	Get("stat-a")
+	Get("stat-b")
+	Get("other-a")
+	stat := statOf("stat-")
+	assert.Equal(int64(2), stat.Hits)
+	assert.Equal(int64(1), stat.Misses)
+	assert.Equal(int64(1), statOf("other-").Misses)
+
+	// lru store report eviction
+	Set("stat-b", []byte("2"), 0)
+	Set("stat-c", []byte("3"), 0)
+	assert.Equal(int64(1), statOf("stat-").Evictions)
+
+	ResetStats()
+	assert.Empty(Stats())
+}
+
+func TestStatsOtherPrefix(t *testing.T) {
+	assert := assert.New(t)
+	ResetStats()
+	defer ResetStats()
+	for i := 0; i < maxStatPrefixes+10; i++ {
+		Get(strconv.Itoa(i) + "-key")
+	}
+	stats := Stats()
+	assert.Len(stats, maxStatPrefixes+1)
+	assert.Equal(int64(10), statOf(otherPrefix).Misses)
+}
+
+func TestGenerationLimit(t *testing.T) {
+	assert := assert.New(t)
+	for i := 0; i < maxGenerations+10; i++ {
+		generationOf("limit" + strconv.Itoa(i) + "-")
+	}
+	generationsMutex.Lock()
+	assert.LessOrEqual(len(generations), maxGenerations)
+	// expire generation so it can be removed
+	for _, g := range generations {
+		g.checked = time.Time{}
+	}
+	generationsMutex.Unlock()
+
+	// invalidated namespace is kept
+	err := InvalidateNamespace("limit-")
+	assert.Nil(err)
+	for i := 0; i < maxGenerations+10; i++ {
+		generationOf("limit" + strconv.Itoa(i) + "-")
+	}
+	generationsMutex.Lock()
+	assert.LessOrEqual(len(generations), maxGenerations)
+	assert.NotNil(generations["limit-"])
+	generationsMutex.Unlock()
+}
+
+func TestInvalidateNamespace(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	err := SetString("ns-a", "1", time.Minute)
+	assert.Nil(err)
+	err = SetString("ns-b", "2", time.Minute)
+	assert.Nil(err)
+	err = SetString("keep-a", "3", time.Minute)
+	assert.Nil(err)
+
+	err = InvalidateNamespace("ns-")
+	assert.Nil(err)
+	found, _, _ := GetString("ns-a")
+	assert.False(found)
+	found, _, _ = GetString("ns-b")
+	assert.False(found)
+	found, value, _ := GetString("keep-a")
+	assert.True(found)
+	assert.Equal("3", value)
+
+	// new entry in new generation
+	err = SetString("ns-a", "4", time.Minute)
+	assert.Nil(err)
+	found, value, _ = GetString("ns-a")
+	assert.True(found)
+	assert.Equal("4", value)
+	Delete("ns-a")
+	found, _, _ = GetString("ns-a")
+	assert.False(found)
+
+	err = InvalidateNamespace("ns")
+	assert.NotNil(err)
+	err = InvalidateNamespace("ns-a")
+	assert.NotNil(err)
+}
+
+func TestGenerationShared(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	err := SetString("shared-a", "1", time.Minute)
+	assert.Nil(err)
+
+	// other instance invalidate namespace in shared store
+	err = current().Set(generationKey+"shared-", []byte("100"), time.Minute)
+	assert.Nil(err)
+	generationsMutex.Lock()
+	delete(generations, "shared-")
+	generationsMutex.Unlock()
+	found, _, _ := GetString("shared-a")
+	assert.False(found)
+	assert.Equal(int64(100), generationOf("shared-"))
+
+	// generation in memory is not decreased when generation in store evicted
+	current().Delete(generationKey + "shared-")
+	generationsMutex.Lock()
+	generations["shared-"].checked = time.Time{}
+	generationsMutex.Unlock()
+	assert.Equal(int64(100), generationOf("shared-"))
+}
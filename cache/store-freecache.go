@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/coocood/freecache"
+)
+
+// FreecacheStore keep entry in fixed size memory using freecache, entry will be evicted when memory is full
+//
+type FreecacheStore struct {
+	Store
+
+	// cache is freecache instance
+	//
+	cache *freecache.Cache
+}
+
+// NewFreecacheStore create freecache store with size in bytes, minimum size is 512KB
+//
+//	store := NewFreecacheStore(20 * 1024 * 1024)
+//
+func NewFreecacheStore(size int) *FreecacheStore {
+	return &FreecacheStore{
+		cache: freecache.NewCache(size),
+	}
+}
+
+// Set an entry to the store, replacing any existing entry, entry will expire after duration, duration less than a second will be a second
+//
+//	err = store.Set("key1", []byte("hi"), time.Minute)
+//
+func (c *FreecacheStore) Set(key string, value []byte, d time.Duration) error {
+	seconds := int(d.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	return c.cache.Set([]byte(key), value, seconds)
+}
+
+// Get an entry from the store, return false if entry not found or expired
+//
+//	found, bytes, err := store.Get("key1")
+//
+func (c *FreecacheStore) Get(key string) (bool, []byte, error) {
+	value, err := c.cache.Get([]byte(key))
+	if err != nil {
+		if err == freecache.ErrNotFound {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+	return true, value, nil
+}
+
+// Delete entry from store, no error if entry not exist
+//
+//	err := store.Delete("key1")
+//
+func (c *FreecacheStore) Delete(key string) error {
+	c.cache.Del([]byte(key))
+	return nil
+}
+
+// Count return entry count in store
+//
+//	count, err := store.Count()
+//
+func (c *FreecacheStore) Count() (int64, error) {
+	return c.cache.EntryCount(), nil
+}
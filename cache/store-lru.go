@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruEntry is entry in lru store
+//
+type lruEntry struct {
+	key     string
+	value   []byte
+	expired time.Time
+}
+
+// LRUStore keep entry in memory, least recently used entry will be evicted when entry count or total size exceed limit
+//
+type LRUStore struct {
+	Store
+
+	// mutex protect store
+	//
+	mutex sync.Mutex
+
+	// maxEntries is max entry count, 0 mean no limit
+	//
+	maxEntries int
+
+	// maxBytes is max total size of key and value, 0 mean no limit
+	//
+	maxBytes int64
+
+	// bytes is current total size of key and value
+	//
+	bytes int64
+
+	// order is entry in recently used order, front is most recently used
+	//
+	order *list.List
+
+	// entries is entry element by key
+	//
+	entries map[string]*list.Element
+
+	// onEvict is called with key when entry is evicted to make room for new entry
+	//
+	onEvict func(key string)
+}
+
+// NewLRUStore create lru store, maxEntries is max entry count and maxBytes is max total size of key and value, 0 mean no limit
+//
+//	store := NewLRUStore(10000, 50 * 1024 * 1024)
+//
+func NewLRUStore(maxEntries int, maxBytes int64) *LRUStore {
+	return &LRUStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+// Set an entry to the store, replacing any existing entry, entry will expire after duration
+//
+//	err = store.Set("key1", []byte("hi"), time.Minute)
+//
+func (c *LRUStore) Set(key string, value []byte, d time.Duration) error {
+	evicted, onEvict := c.set(key, value, d)
+	// call onEvict after unlock so callback can use store
+	if onEvict != nil {
+		for _, key := range evicted {
+			onEvict(key)
+		}
+	}
+	return nil
+}
+
+// set entry to the store and evict entry exceed limit, return evicted key and onEvict to call after unlock
+//
+func (c *LRUStore) set(key string, value []byte, d time.Duration) ([]string, func(key string)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, found := c.entries[key]; found {
+		c.remove(element)
+	}
+	entry := &lruEntry{
+		key:     key,
+		value:   append([]byte(nil), value...),
+		expired: time.Now().Add(d),
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.bytes += entrySize(entry)
+	var evicted []string
+	for c.order.Len() > 1 && ((c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		evicted = append(evicted, c.remove(c.order.Back()).key)
+	}
+	return evicted, c.onEvict
+}
+
+// Get an entry from the store, return false if entry not found or expired
+//
+//	found, bytes, err := store.Get("key1")
+//
+func (c *LRUStore) Get(key string) (bool, []byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, found := c.entries[key]
+	if !found {
+		return false, nil, nil
+	}
+	entry := element.Value.(*lruEntry)
+	if !time.Now().Before(entry.expired) {
+		c.remove(element)
+		return false, nil, nil
+	}
+	c.order.MoveToFront(element)
+	return true, append([]byte(nil), entry.value...), nil
+}
+
+// Delete entry from store, no error if entry not exist
+//
+//	err := store.Delete("key1")
+//
+func (c *LRUStore) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, found := c.entries[key]; found {
+		c.remove(element)
+	}
+	return nil
+}
+
+// Count return entry count in store, expired entry not yet evicted is counted
+//
+//	count, err := store.Count()
+//
+func (c *LRUStore) Count() (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return int64(c.order.Len()), nil
+}
+
+// OnEvict set function called with key when entry is evicted to make room for new entry, expired or deleted entry is not evicted. f is called without lock so it can use store
+//
+//	store.OnEvict(func(key string) {
+//		fmt.Println(key + " evicted")
+//	})
+//
+func (c *LRUStore) OnEvict(f func(key string)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onEvict = f
+}
+
+// remove element from store and return removed entry, caller must hold mutex
+//
+func (c *LRUStore) remove(element *list.Element) *lruEntry {
+	entry := c.order.Remove(element).(*lruEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entrySize(entry)
+	return entry
+}
+
+// entrySize return size of key and value
+//
+func entrySize(entry *lruEntry) int64 {
+	return int64(len(entry.key) + len(entry.value))
+}
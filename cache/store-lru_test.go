@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUStore(t *testing.T) {
+	t.Parallel()
+	testStore(t, NewLRUStore(0, 0))
+}
+
+func TestLRUStoreEvict(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	store := NewLRUStore(3, 0)
+	for i := 0; i < 3; i++ {
+		store.Set(strconv.Itoa(i), []byte("v"), time.Minute)
+	}
+	// use 0 so 1 is least recently used
+	found, _, _ := store.Get("0")
+	assert.True(found)
+	store.Set("3", []byte("v"), time.Minute)
+	count, _ := store.Count()
+	assert.Equal(int64(3), count)
+	found, _, _ = store.Get("1")
+	assert.False(found)
+	found, _, _ = store.Get("0")
+	assert.True(found)
+
+	// size limit
+	store = NewLRUStore(0, 10)
+	store.Set("a", []byte("1234"), time.Minute)
+	store.Set("b", []byte("1234"), time.Minute)
+	store.Set("c", []byte("1234"), time.Minute)
+	found, _, _ = store.Get("a")
+	assert.False(found)
+	found, _, _ = store.Get("c")
+	assert.True(found)
+
+	// entry larger than limit is kept until next set
+	store.Set("big", []byte("12345678901234567890"), time.Minute)
+	found, _, _ = store.Get("big")
+	assert.True(found)
+	count, _ = store.Count()
+	assert.Equal(int64(1), count)
+}
+
+func TestLRUStoreOnEvictReentrant(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	store := NewLRUStore(1, 0)
+	var evicted []string
+	store.OnEvict(func(key string) {
+		// callback can use store without deadlock
+		count, _ := store.Count()
+		assert.Equal(int64(1), count)
+		evicted = append(evicted, key)
+	})
+	store.Set("a", []byte("1"), time.Minute)
+	store.Set("b", []byte("2"), time.Minute)
+	assert.Equal([]string{"a"}, evicted)
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// redisTimeout is timeout of dial and every redis command
+//
+const redisTimeout = 3 * time.Second
+
+// redisConn is connection to redis
+//
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// RedisStore keep entry in redis or any server speak redis protocol, so all instances share the same cache
+//
+type RedisStore struct {
+	Store
+
+	// addr is redis address like "10.0.0.3:6379"
+	//
+	addr string
+
+	// pool is idle connections
+	//
+	pool chan *redisConn
+}
+
+// NewRedisStore create redis store, poolSize is max idle connections kept, connection is created when first used
+//
+//	store := NewRedisStore("10.0.0.3:6379", 10)
+//
+func NewRedisStore(addr string, poolSize int) *RedisStore {
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	return &RedisStore{
+		addr: addr,
+		pool: make(chan *redisConn, poolSize),
+	}
+}
+
+// Set an entry to the store, replacing any existing entry, entry will expire after duration, duration less than a millisecond will be a millisecond
+//
+//	err = store.Set("key1", []byte("hi"), time.Minute)
+//
+func (c *RedisStore) Set(key string, value []byte, d time.Duration) error {
+	ms := d.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	_, err := c.do([]byte("SET"), []byte(key), value, []byte("PX"), []byte(strconv.FormatInt(ms, 10)))
+	return err
+}
+
+// Get an entry from the store, return false if entry not found or expired
+//
+//	found, bytes, err := store.Get("key1")
+//
+func (c *RedisStore) Get(key string) (bool, []byte, error) {
+	reply, err := c.do([]byte("GET"), []byte(key))
+	if err != nil {
+		return false, nil, err
+	}
+	if reply == nil {
+		return false, nil, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return false, nil, errors.Errorf("unexpected reply %T", reply)
+	}
+	return true, value, nil
+}
+
+// Delete entry from store, no error if entry not exist
+//
+//	err := store.Delete("key1")
+//
+func (c *RedisStore) Delete(key string) error {
+	_, err := c.do([]byte("DEL"), []byte(key))
+	return err
+}
+
+// Count return entry count in redis database
+//
+//	count, err := store.Count()
+//
+func (c *RedisStore) Count() (int64, error) {
+	reply, err := c.do([]byte("DBSIZE"))
+	if err != nil {
+		return 0, err
+	}
+	count, ok := reply.(int64)
+	if !ok {
+		return 0, errors.Errorf("unexpected reply %T", reply)
+	}
+	return count, nil
+}
+
+// Close close all idle connections
+//
+//	store.Close()
+//
+func (c *RedisStore) Close() {
+	for {
+		select {
+		case rc := <-c.pool:
+			rc.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// do send command to redis and return reply, reply is []byte, int64, string or nil
+//
+func (c *RedisStore) do(args ...[]byte) (interface{}, error) {
+	rc, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	rc.conn.SetDeadline(time.Now().Add(redisTimeout))
+	if err := writeCommand(rc.conn, args); err != nil {
+		rc.conn.Close()
+		return nil, errors.Wrapf(err, "write %s", args[0])
+	}
+	reply, err := readReply(rc.reader)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			rc.conn.Close()
+			return nil, errors.Wrapf(err, "read %s", args[0])
+		}
+	}
+	c.put(rc)
+	return reply, err
+}
+
+// get return idle connection or create new one
+//
+func (c *RedisStore) get() (*redisConn, error) {
+	select {
+	case rc := <-c.pool:
+		return rc, nil
+	default:
+	}
+	conn, err := net.DialTimeout("tcp", c.addr, redisTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dial redis %v", c.addr)
+	}
+	return &redisConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// put return connection to pool, close it if pool is full
+//
+func (c *RedisStore) put(rc *redisConn) {
+	select {
+	case c.pool <- rc:
+	default:
+		rc.conn.Close()
+	}
+}
+
+// redisError is error reply from redis
+//
+type redisError string
+
+// Error return error message
+//
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// writeCommand write command as array of bulk strings
+//
+func writeCommand(w io.Writer, args [][]byte) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// readReply read one reply, bulk string return []byte, integer return int64, simple string return string, nil bulk return nil, array return []interface{}
+//
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, errors.Wrap(err, "bulk length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, errors.Wrap(err, "array length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			if list[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return nil, errors.Errorf("unknown reply %q", line)
+}
+
+// readLine read line end with \r\n, return line without \r\n
+//
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("invalid line ending")
+	}
+	return line[:len(line)-2], nil
+}
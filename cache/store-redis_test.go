@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// redisStandIn is in-process server speak redis protocol, it support SET with PX, GET, DEL and DBSIZE
+//
+type redisStandIn struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	entries  map[string]*lruEntry
+}
+
+// startRedisStandIn start stand-in on random local port
+//
+func startRedisStandIn(t *testing.T) *redisStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &redisStandIn{listener: listener, entries: map[string]*lruEntry{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// addr return stand-in address
+//
+func (s *redisStandIn) addr() string {
+	return s.listener.Addr().String()
+}
+
+// serve handle command from connection
+//
+func (s *redisStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		reply, err := readReply(reader)
+		if err != nil {
+			return
+		}
+		args := reply.([]interface{})
+		if _, err := conn.Write([]byte(s.exec(args))); err != nil {
+			return
+		}
+	}
+}
+
+// exec execute command and return reply
+//
+func (s *redisStandIn) exec(args []interface{}) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cmd := strings.ToUpper(string(args[0].([]byte)))
+	switch cmd {
+	case "SET":
+		ms, _ := strconv.Atoi(string(args[4].([]byte)))
+		key := string(args[1].([]byte))
+		s.entries[key] = &lruEntry{key: key, value: args[2].([]byte), expired: time.Now().Add(time.Duration(ms) * time.Millisecond)}
+		return "+OK\r\n"
+	case "GET":
+		entry, found := s.entries[string(args[1].([]byte))]
+		if !found || !time.Now().Before(entry.expired) {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(entry.value)) + "\r\n" + string(entry.value) + "\r\n"
+	case "DEL":
+		key := string(args[1].([]byte))
+		_, found := s.entries[key]
+		delete(s.entries, key)
+		if found {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "DBSIZE":
+		return ":" + strconv.Itoa(len(s.entries)) + "\r\n"
+	}
+	return "-ERR unknown command '" + cmd + "'\r\n"
+}
+
+func TestRedisStore(t *testing.T) {
+	t.Parallel()
+	standIn := startRedisStandIn(t)
+	store := NewRedisStore(standIn.addr(), 2)
+	defer store.Close()
+	testStore(t, store)
+}
+
+func TestRedisStoreConcurrent(t *testing.T) {
+	t.Parallel()
+	standIn := startRedisStandIn(t)
+	store := NewRedisStore(standIn.addr(), 2)
+	defer store.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "concurrent-" + strconv.Itoa(i)
+			if err := store.Set(key, []byte(key), time.Minute); err != nil {
+				t.Error(err)
+				return
+			}
+			found, value, err := store.Get(key)
+			if err != nil || !found || string(value) != key {
+				t.Errorf("get %v failed, found %v value %s err %v", key, found, value, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRedisStoreError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	standIn := startRedisStandIn(t)
+	store := NewRedisStore(standIn.addr(), 1)
+	defer store.Close()
+
+	// error reply keep connection usable
+	_, err := store.do([]byte("PING"))
+	assert.NotNil(err)
+	err = store.Set("a", []byte("1"), time.Minute)
+	assert.Nil(err)
+
+	// server not available
+	store = NewRedisStore("127.0.0.1:1", 1)
+	_, _, err = store.Get("a")
+	assert.NotNil(err)
+}
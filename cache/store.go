@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Store is cache backend, package function like Get() and Set() use store set by Use(), default is 20MB freecache in memory
+//
+type Store interface {
+
+	// Set an entry to the store, replacing any existing entry, entry will expire after duration
+	//
+	//	err = store.Set("key1", []byte("hi"), time.Minute)
+	//
+	Set(key string, value []byte, d time.Duration) error
+
+	// Get an entry from the store, return false if entry not found or expired
+	//
+	//	found, bytes, err := store.Get("key1")
+	//
+	Get(key string) (bool, []byte, error)
+
+	// Delete entry from store, no error if entry not exist
+	//
+	//	err := store.Delete("key1")
+	//
+	Delete(key string) error
+
+	// Count return entry count in store
+	//
+	//	count, err := store.Count()
+	//
+	Count() (int64, error)
+}
+
+// defaultStoreSize is default freecache size in bytes, where 1024 * 1024 represents a single Megabyte, and 20 * 1024*1024 represents 20 Megabytes.
+//
+const defaultStoreSize = 20 * 1024 * 1024
+
+// store is current cache backend
+//
+var store Store = NewFreecacheStore(defaultStoreSize)
+
+// storeMutex protect store
+//
+var storeMutex sync.RWMutex
+
+// Use set cache backend used by package function, call it at startup before use cache, eviction of store like LRUStore is counted in Stats()
+//
+//	cache.Use(cache.NewRedisStore("10.0.0.3:6379", 10))
+//
+func Use(s Store) {
+	if notifier, ok := s.(evictNotifier); ok {
+		notifier.OnEvict(recordEviction)
+	}
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	store = s
+}
+
+// current return cache backend in use
+//
+func current() Store {
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	return store
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore test store set/get/delete/count
+//
+func testStore(t *testing.T, store Store) {
+	assert := assert.New(t)
+	found, value, err := store.Get("store-a")
+	assert.Nil(err)
+	assert.False(found)
+	assert.Nil(value)
+
+	err = store.Set("store-a", []byte("1"), time.Minute)
+	assert.Nil(err)
+	err = store.Set("store-b", []byte{}, time.Minute)
+	assert.Nil(err)
+	found, value, err = store.Get("store-a")
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal([]byte("1"), value)
+	found, value, err = store.Get("store-b")
+	assert.Nil(err)
+	assert.True(found)
+	assert.Empty(value)
+
+	count, err := store.Count()
+	assert.Nil(err)
+	assert.Equal(int64(2), count)
+
+	// replace
+	err = store.Set("store-a", []byte("2"), time.Minute)
+	assert.Nil(err)
+	_, value, err = store.Get("store-a")
+	assert.Nil(err)
+	assert.Equal([]byte("2"), value)
+
+	err = store.Delete("store-a")
+	assert.Nil(err)
+	found, _, err = store.Get("store-a")
+	assert.Nil(err)
+	assert.False(found)
+	err = store.Delete("not-exist")
+	assert.Nil(err)
+
+	// expired
+	err = store.Set("store-c", []byte("3"), time.Millisecond)
+	assert.Nil(err)
+	time.Sleep(1100 * time.Millisecond)
+	found, _, err = store.Get("store-c")
+	assert.Nil(err)
+	assert.False(found)
+}
+
+func TestFreecacheStore(t *testing.T) {
+	t.Parallel()
+	testStore(t, NewFreecacheStore(1024*1024))
+}
+
+func TestUseStore(t *testing.T) {
+	assert := assert.New(t)
+	lru := NewLRUStore(0, 0)
+	Use(lru)
+	defer Use(NewFreecacheStore(defaultStoreSize))
+
+	err := SetString("use-a", "hi", 0)
+	assert.Nil(err)
+	found, value, err := lru.Get("use-a")
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal([]byte("hi"), value)
+	assert.Equal(int64(1), Count())
+
+	err = ISet(1, []byte("i"), 0)
+	assert.Nil(err)
+	found, value, err = IGet(1)
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal([]byte("i"), value)
+	IDelete(1)
+	found, _, err = IGet(1)
+	assert.Nil(err)
+	assert.False(found)
+
+	Delete("use-a")
+	assert.Equal(int64(0), Count())
+}
@@ -3,9 +3,12 @@ package command
 import (
 	"context"
 	"encoding/binary"
+	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/piyuo/libsrv/command/simple"
+	"github.com/piyuo/libsrv/env"
 	"github.com/piyuo/libsrv/log"
 
 	"github.com/pkg/errors"
@@ -19,6 +22,24 @@ type Action interface {
 	XXX_MapName() string
 }
 
+// Idempotent can be implemented by action that is safe to run again, like read only query, so it is never rejected by duplicate check
+//
+//	func (c *CmdGetProfile) Idempotent() bool {
+//		return true
+//	}
+//
+type Idempotent interface {
+	Idempotent() bool
+}
+
+// Limiter check call of action before it run, return block response like BlockShort or BlockLong if caller exceed rate limit, return nil if call is allowed. firewall.Limiter implement it
+//
+//	dispatch.Limiter = firewall.NewLimiter(firewall.NewMemoryStore(), rules...)
+//
+type Limiter interface {
+	Check(ctx context.Context, action string) (interface{}, error)
+}
+
 // Response interface
 type Response interface {
 	//  1 to 32,767 is valid service id,-1 to -32,768 is shared id between all service
@@ -34,6 +55,22 @@ type IMap interface {
 // Dispatch manage action,handler,response
 type Dispatch struct {
 	Map IMap
+
+	// DuplicateWindow is how long the same command from the same user and ip is rejected with BlockHighFrequency, 0 mean no duplicate check. action implement Idempotent is never rejected
+	//
+	DuplicateWindow time.Duration
+
+	// Limiter check action name before action run, caller exceed rate limit get block response, nil mean no rate limit
+	//
+	Limiter Limiter
+
+	// guard remember command fingerprint
+	//
+	guard *duplicateGuard
+
+	// guardOnce create guard once
+	//
+	guardOnce sync.Once
 }
 
 // Route get action from httpRequest and write response to httpResponse, write http error text if some thing went wrong
@@ -44,10 +81,25 @@ func (dp *Dispatch) Route(ctx context.Context, bytes []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	ctx = env.SetCommand(ctx, action.(Action).XXX_MapName())
 	log.Info(ctx, "exec %v (%v bytes), ", action.(Action).XXX_MapName(), len(bytes))
-	responseID, response, err := dp.runAction(ctx, action)
-	if err != nil {
-		return nil, err
+	var responseID uint16
+	var response interface{}
+	if blocked := dp.checkLimit(ctx, action); blocked != nil {
+		response = blocked
+		responseID = blocked.(Response).XXX_MapID()
+	} else if dp.isDuplicate(ctx, action, bytes) {
+		response = BlockHighFrequency
+		responseID = BlockHighFrequency.XXX_MapID()
+	} else {
+		responseID, response, err = dp.runAction(ctx, action)
+		if err != nil {
+			dp.forgetDuplicate(ctx, action, bytes)
+			return nil, err
+		}
+		if _, failed := response.(*simple.Error); failed {
+			dp.forgetDuplicate(ctx, action, bytes)
+		}
 	}
 	var returnBytes []byte
 	returnBytes, err = dp.EncodeCommand(responseID, response)
@@ -59,6 +111,53 @@ func (dp *Dispatch) Route(ctx context.Context, bytes []byte) ([]byte, error) {
 	return returnBytes, nil
 }
 
+// checkLimit return block response if caller exceed rate limit of action, limiter error is logged and call is allowed, so rate limit store failure will not stop service
+//
+func (dp *Dispatch) checkLimit(ctx context.Context, action interface{}) interface{} {
+	if dp.Limiter == nil {
+		return nil
+	}
+	response, err := dp.Limiter.Check(ctx, action.(Action).XXX_MapName())
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "check rate limit"))
+		return nil
+	}
+	return response
+}
+
+// checkDuplicate return true if action need duplicate check
+//
+func (dp *Dispatch) checkDuplicate(action interface{}) bool {
+	if dp.DuplicateWindow <= 0 {
+		return false
+	}
+	if idempotent, ok := action.(Idempotent); ok && idempotent.Idempotent() {
+		return false
+	}
+	return true
+}
+
+// isDuplicate return true if the same command from the same user and ip is routed in DuplicateWindow
+//
+func (dp *Dispatch) isDuplicate(ctx context.Context, action interface{}, bytes []byte) bool {
+	if !dp.checkDuplicate(action) {
+		return false
+	}
+	dp.guardOnce.Do(func() {
+		dp.guard = newDuplicateGuard()
+	})
+	return dp.guard.check(fingerprint(ctx, bytes), dp.DuplicateWindow)
+}
+
+// forgetDuplicate let command failed with error or error response be sent again
+//
+func (dp *Dispatch) forgetDuplicate(ctx context.Context, action interface{}, bytes []byte) {
+	if !dp.checkDuplicate(action) {
+		return
+	}
+	dp.guard.forget(fingerprint(ctx, bytes))
+}
+
 //betterResponseName return response name but return ok when err=0
 //
 //	result := betterResponseName(errOK.XXX_MapID(), errOK)
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/piyuo/libsrv/env"
+)
+
+// duplicateCleanupCount is how many check before expired fingerprint is removed
+//
+const duplicateCleanupCount = 1000
+
+// duplicateGuard remember fingerprint of request in window, so repeated request like double-clicked payment can be rejected
+//
+type duplicateGuard struct {
+
+	// mutex protect seen
+	//
+	mutex sync.Mutex
+
+	// seen is fingerprint expired time by fingerprint
+	//
+	seen map[[sha256.Size]byte]time.Time
+
+	// checks is check count since last cleanup
+	//
+	checks int
+}
+
+// newDuplicateGuard create duplicate guard
+//
+func newDuplicateGuard() *duplicateGuard {
+	return &duplicateGuard{
+		seen: map[[sha256.Size]byte]time.Time{},
+	}
+}
+
+// fingerprint return fingerprint of command bytes send by current user and ip
+//
+func fingerprint(ctx context.Context, bytes []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(bytes)
+	h.Write([]byte{0})
+	h.Write([]byte(env.GetUserID(ctx)))
+	h.Write([]byte{0})
+	h.Write([]byte(env.GetIP(ctx)))
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// check remember fingerprint for window, return true if the same fingerprint is already seen in window
+//
+func (c *duplicateGuard) check(fp [sha256.Size]byte, window time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	c.checks++
+	if c.checks >= duplicateCleanupCount {
+		c.checks = 0
+		for k, expired := range c.seen {
+			if !now.Before(expired) {
+				delete(c.seen, k)
+			}
+		}
+	}
+	if expired, found := c.seen[fp]; found && now.Before(expired) {
+		return true
+	}
+	c.seen[fp] = now.Add(window)
+	return false
+}
+
+// forget remove fingerprint, so request can be sent again
+//
+func (c *duplicateGuard) forget(fp [sha256.Size]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.seen, fp)
+}
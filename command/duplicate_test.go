@@ -0,0 +1,169 @@
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/command/mock"
+	"github.com/piyuo/libsrv/env"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateRoute(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	dispatch := &Dispatch{
+		Map:             &mock.MapXXX{},
+		DuplicateWindow: 100 * time.Millisecond,
+	}
+	act := &mock.CmdRespond{Text: "Hi"}
+	actBytes, err := dispatch.EncodeCommand(act.XXX_MapID(), act)
+	assert.Nil(err)
+
+	resultBytes, err := dispatch.Route(ctx, actBytes)
+	assert.Nil(err)
+	_, resp, err := dispatch.DecodeCommand(resultBytes)
+	assert.Nil(err)
+	assert.NotNil(resp.(*mock.CmdResponse))
+
+	// same command in window is blocked
+	blockBytes, err := dispatch.EncodeCommand(BlockHighFrequency.XXX_MapID(), BlockHighFrequency)
+	assert.Nil(err)
+	resultBytes, err = dispatch.Route(ctx, actBytes)
+	assert.Nil(err)
+	assert.Equal(blockBytes, resultBytes)
+
+	// different user or command is not blocked
+	resultBytes, err = dispatch.Route(env.SetUserID(ctx, "user1"), actBytes)
+	assert.Nil(err)
+	assert.NotEqual(blockBytes, resultBytes)
+	act2 := &mock.CmdRespond{Text: "Hello"}
+	actBytes2, _ := dispatch.EncodeCommand(act2.XXX_MapID(), act2)
+	resultBytes, err = dispatch.Route(ctx, actBytes2)
+	assert.Nil(err)
+	assert.NotEqual(blockBytes, resultBytes)
+
+	// command can be sent again after window
+	time.Sleep(150 * time.Millisecond)
+	resultBytes, err = dispatch.Route(ctx, actBytes)
+	assert.Nil(err)
+	assert.NotEqual(blockBytes, resultBytes)
+}
+
+func TestDuplicateFailedCommand(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	dispatch := &Dispatch{
+		Map:             &mock.MapXXX{},
+		DuplicateWindow: time.Minute,
+	}
+	act := &mock.CmdNoRespond{Text: "Hi"}
+	actBytes, err := dispatch.EncodeCommand(act.XXX_MapID(), act)
+	assert.Nil(err)
+
+	// failed command can be sent again
+	_, err = dispatch.Route(context.Background(), actBytes)
+	assert.NotNil(err)
+	_, err = dispatch.Route(context.Background(), actBytes)
+	assert.NotNil(err)
+}
+
+func TestDuplicateErrorResponse(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	dispatch := &Dispatch{
+		Map:             &mock.MapXXX{},
+		DuplicateWindow: time.Minute,
+	}
+	act := &mock.CmdSlow{}
+	actBytes, err := dispatch.EncodeCommand(act.XXX_MapID(), act)
+	assert.Nil(err)
+	blockBytes, err := dispatch.EncodeCommand(BlockHighFrequency.XXX_MapID(), BlockHighFrequency)
+	assert.Nil(err)
+
+	// command return error response can be sent again
+	resultBytes, err := dispatch.Route(context.Background(), actBytes)
+	assert.Nil(err)
+	assert.NotEqual(blockBytes, resultBytes)
+	resultBytes, err = dispatch.Route(context.Background(), actBytes)
+	assert.Nil(err)
+	assert.NotEqual(blockBytes, resultBytes)
+}
+
+// testLimiter block action after limit
+//
+type testLimiter struct {
+	calls int
+	limit int
+}
+
+func (c *testLimiter) Check(ctx context.Context, action string) (interface{}, error) {
+	c.calls++
+	if c.calls > c.limit {
+		return BlockShort, nil
+	}
+	return nil, nil
+}
+
+func TestDispatchLimiter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	dispatch := &Dispatch{
+		Map:     &mock.MapXXX{},
+		Limiter: &testLimiter{limit: 1},
+	}
+	act := &mock.CmdRespond{Text: "Hi"}
+	actBytes, err := dispatch.EncodeCommand(act.XXX_MapID(), act)
+	assert.Nil(err)
+	blockBytes, err := dispatch.EncodeCommand(BlockShort.XXX_MapID(), BlockShort)
+	assert.Nil(err)
+
+	resultBytes, err := dispatch.Route(ctx, actBytes)
+	assert.Nil(err)
+	assert.NotEqual(blockBytes, resultBytes)
+
+	// exceed limit
+	resultBytes, err = dispatch.Route(ctx, actBytes)
+	assert.Nil(err)
+	assert.Equal(blockBytes, resultBytes)
+}
+
+func TestDuplicateGuard(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	guard := newDuplicateGuard()
+	fp := fingerprint(context.Background(), []byte("a"))
+	assert.False(guard.check(fp, time.Minute))
+	assert.True(guard.check(fp, time.Minute))
+	guard.forget(fp)
+	assert.False(guard.check(fp, time.Minute))
+
+	ipFP := fingerprint(context.WithValue(context.Background(), env.MockIP, ""), []byte("a"))
+	assert.NotEqual(fp, ipFP)
+	assert.True(IsBlockHighFrequency(BlockHighFrequency))
+}
+
+func TestDuplicateIdempotent(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	dispatch := &Dispatch{
+		Map:             &mock.MapXXX{},
+		DuplicateWindow: time.Minute,
+	}
+	act := &mock.CmdBigData{}
+	actBytes, err := dispatch.EncodeCommand(act.XXX_MapID(), act)
+	assert.Nil(err)
+	blockBytes, err := dispatch.EncodeCommand(BlockHighFrequency.XXX_MapID(), BlockHighFrequency)
+	assert.Nil(err)
+
+	// idempotent action is never blocked
+	for i := 0; i < 2; i++ {
+		resultBytes, err := dispatch.Route(ctx, actBytes)
+		assert.Nil(err)
+		assert.NotEqual(blockBytes, resultBytes)
+	}
+}
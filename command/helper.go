@@ -5,8 +5,9 @@ import (
 )
 
 const (
-	BLOCK_SHORT = "BLOCK_SHORT"
-	BLOCK_LONG  = "BLOCK_LONG"
+	BLOCK_SHORT     = "BLOCK_SHORT"
+	BLOCK_LONG      = "BLOCK_LONG"
+	BLOCK_HIGH_FREQ = "BLOCK_HIGH_FREQ"
 )
 
 // ok instace, it use frequently
@@ -29,6 +30,14 @@ var BlockLong = &simple.Error{
 	Code: BLOCK_LONG,
 }
 
+// BlockHighFrequency return error response with BLOCK_HIGH_FREQ, the same command is sent twice in short time
+//
+//	return BlockHighFrequency
+//
+var BlockHighFrequency = &simple.Error{
+	Code: BLOCK_HIGH_FREQ,
+}
+
 // Error return error response with code
 //
 //	return Error("INVALID_EMAIL")
@@ -84,6 +93,14 @@ func IsBlockLong(obj interface{}) bool {
 	return IsError(obj, BLOCK_LONG)
 }
 
+// IsBlockHighFrequency return true if object is BlockHighFrequency
+//
+//	is := IsBlockHighFrequency(response)
+//
+func IsBlockHighFrequency(obj interface{}) bool {
+	return IsError(obj, BLOCK_HIGH_FREQ)
+}
+
 // GetErrorCode return error code if object is PbError otherwise return empty
 //
 //	code := GetErrorCode(response) // "INVALID_EMAIL"
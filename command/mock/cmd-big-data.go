@@ -12,6 +12,12 @@ func (c *CmdBigData) Do(ctx context.Context) (interface{}, error) {
 	}, nil
 }
 
+// Idempotent return true, big data only return sample text so it is safe to send again
+//
+func (c *CmdBigData) Idempotent() bool {
+	return true
+}
+
 // GetSample return large text sample
 //
 func (c *CmdBigData) GetSample() string {
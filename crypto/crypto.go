@@ -1,118 +1,132 @@
 package crypto
 
 import (
-	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
-	"encoding/base64"
-	"strconv"
+	"strings"
+	"sync"
 
-	"github.com/piyuo/libsrv/file"
 	"github.com/pkg/errors"
 )
 
-var blockSize int
+// keyFiles is key files in /keys used by default keyring, first file is primary key used to encrypt
+//
+var keyFiles = []string{"crypto.key"}
 
-var cachedKey []byte = nil
+// defaultKeyring is keyring used by Encrypt() and Decrypt(), it will be loaded from key files when first used
+//
+var defaultKeyring *Keyring
 
-// getBlock return cipher block from /keys/crypto.key, block will be cached after read from file
+// legacyDisabled is true if default keyring do not decrypt legacy AES-CBC string
 //
-func getBlock() ([]byte, cipher.Block, error) {
-	var err error
-	if cachedKey == nil {
-		cachedKey, err = file.Key("crypto.key") // key will cache key content
-		if err != nil {
-			return nil, nil, errors.Wrap(err, "/keys/crypto.key not found")
-		}
-	}
+var legacyDisabled bool
 
-	block, err := aes.NewCipher(cachedKey)
-	if err != nil {
-		return nil, nil, errors.Wrapf(err, "must use 128bit key in crypto.key, got %d ", len(cachedKey))
-	}
-	if blockSize == 0 {
-		blockSize = block.BlockSize()
-	}
-	return cachedKey, block, nil
+// keyringMutex protect defaultKeyring, keyFiles and legacyDisabled
+//
+var keyringMutex sync.Mutex
+
+// SetKeyFiles set key files in /keys used by Encrypt() and Decrypt(), first file is primary key used to encrypt, other keys only used to decrypt. key id is file name without extension
+//
+//	crypto.SetKeyFiles("crypto-2021.key", "crypto.key")
+//
+func SetKeyFiles(filenames ...string) {
+	keyringMutex.Lock()
+	defer keyringMutex.Unlock()
+	keyFiles = filenames
+	defaultKeyring = nil
 }
 
-// newEncrypter return encrypter
+// SetKeyring set keyring used by Encrypt() and Decrypt(), set nil to load keyring from key files again
 //
-//	encrypter, err := newEncrypter()
+//	crypto.SetKeyring(keyring)
 //
-func newEncrypter() (cipher.BlockMode, error) {
-	cryptoKey, block, err := getBlock()
-	if err != nil {
-		return nil, err
-	}
-	return cipher.NewCBCEncrypter(block, cryptoKey[:blockSize]), nil
+func SetKeyring(keyring *Keyring) {
+	keyringMutex.Lock()
+	defer keyringMutex.Unlock()
+	defaultKeyring = keyring
 }
 
-// newDecrypter return decrypter
+// SetLegacy enable or disable decrypt legacy AES-CBC string in default keyring, disable it once all string are rotated. default is enabled
 //
-//	decrypter, err := newDecrypter()
+//	crypto.SetLegacy(false)
 //
-func newDecrypter() (cipher.BlockMode, error) {
-	cryptoKey, block, err := getBlock()
-	if err != nil {
-		return nil, err
+func SetLegacy(enabled bool) {
+	keyringMutex.Lock()
+	defer keyringMutex.Unlock()
+	legacyDisabled = !enabled
+	defaultKeyring = nil
+}
+
+// getKeyring return default keyring, keyring will be cached after read from key files
+//
+func getKeyring() (*Keyring, error) {
+	keyringMutex.Lock()
+	defer keyringMutex.Unlock()
+	if defaultKeyring == nil {
+		keyring, err := LoadKeyring(keyFiles...)
+		if err != nil {
+			return nil, err
+		}
+		keyring.SetLegacy(!legacyDisabled)
+		defaultKeyring = keyring
 	}
-	return cipher.NewCBCDecrypter(block, cryptoKey[:blockSize]), nil
+	return defaultKeyring, nil
 }
 
-// Encrypt string, if you need encrypt multiple string at the same time using getEncrypter()
+// Encrypt string using primary key in default keyring
 //
 //	crypted1, err := Encrypt("hello1")
 //
 func Encrypt(text string) (string, error) {
-	encrypter, err := newEncrypter()
+	keyring, err := getKeyring()
 	if err != nil {
-		return "", errors.Wrap(err, "new encrypter")
+		return "", errors.Wrap(err, "get keyring")
 	}
-
-	textData := []byte(text)
-	textData = pkcs7Padding(textData, blockSize)
-	cryted := make([]byte, len(textData))
-	encrypter.CryptBlocks(cryted, textData)
-	return base64.RawStdEncoding.EncodeToString(cryted), nil
+	return keyring.Encrypt(text)
 }
 
-// Decrypt string, if you need decrypt multiple string at the same time using getEncrypter()
+// Decrypt string using default keyring, string encrypted by legacy version can still be decrypted
 //
-//	result, err := cDecrypt(crypted)
+//	result, err := Decrypt(crypted)
 //
 func Decrypt(crypted string) (string, error) {
-	decrypter, err := newDecrypter()
+	keyring, err := getKeyring()
 	if err != nil {
-		return "", errors.Wrap(err, "new decrypter")
+		return "", errors.Wrap(err, "get keyring")
 	}
+	return keyring.Decrypt(crypted)
+}
 
-	crytedByte, err := base64.RawStdEncoding.DecodeString(crypted)
+// Rotate re-encrypt string using primary key in default keyring if it is encrypted by legacy version or old key, return true if string changed
+//
+//	crypted, changed, err := Rotate(crypted)
+//
+func Rotate(crypted string) (string, bool, error) {
+	keyring, err := getKeyring()
 	if err != nil {
-		return "", errors.Wrap(err, "decode base64")
-	}
-	lenCryted := len(crytedByte)
-	if lenCryted == 0 {
-		return "", errors.New("input must not empty")
+		return "", false, errors.Wrap(err, "get keyring")
 	}
-	if lenCryted%blockSize != 0 {
-		return "", errors.New("input not full blocks, block size must be " + strconv.Itoa(blockSize))
-	}
-
-	orig := make([]byte, lenCryted)
-	decrypter.CryptBlocks(orig, crytedByte)
-	orig = pkcs7UnPadding(orig)
-	return string(orig), nil
+	return keyring.Rotate(crypted)
 }
 
-func pkcs7Padding(ciphertext []byte, blocksize int) []byte {
-	padding := blocksize - len(ciphertext)%blocksize
-	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
-	return append(ciphertext, padtext...)
+// urlReplacer replace base64 character not safe in url
+//
+var urlReplacer = strings.NewReplacer("+", "-", "/", "_")
+
+// urlRestorer restore base64 character replaced by urlReplacer
+//
+var urlRestorer = strings.NewReplacer("-", "+", "_", "/")
+
+// URLEncode return encrypted string that can be used in url
+//
+//	str := URLEncode(crypted)
+//
+func URLEncode(crypted string) string {
+	return urlReplacer.Replace(crypted)
 }
 
-func pkcs7UnPadding(origData []byte) []byte {
-	length := len(origData)
-	unpadding := int(origData[length-1])
-	return origData[:(length - unpadding)]
+// URLDecode return encrypted string from string return by URLEncode
+//
+//	crypted := URLDecode(str)
+//
+func URLDecode(str string) string {
+	return urlRestorer.Replace(str)
 }
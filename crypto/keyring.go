@@ -0,0 +1,316 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"path"
+	"strings"
+
+	"github.com/piyuo/libsrv/file"
+	"github.com/pkg/errors"
+)
+
+// versionPrefix is prefix of ciphertext string, it define ciphertext format. "." is not in base64 alphabet so legacy string never start with it
+//
+//	"v1." + base64(key id length(1) | key id | nonce(12) | AES-GCM sealed text)
+//
+const versionPrefix = "v1."
+
+// maxKeyIDLength is max length of key id
+//
+const maxKeyIDLength = 255
+
+// key is one key in keyring
+//
+type key struct {
+
+	// raw is key content
+	//
+	raw []byte
+
+	// block is AES block cipher
+	//
+	block cipher.Block
+
+	// aead is AES-GCM on block
+	//
+	aead cipher.AEAD
+}
+
+// Keyring hold keys by id, primary key used to encrypt and all keys used to decrypt, so string encrypted by old key can still be decrypted after key rotation
+//
+//	keyring, err := NewKeyring("2021", map[string][]byte{"2021": key2021, "2020": key2020})
+//	crypted, err := keyring.Encrypt("hello")
+//
+type Keyring struct {
+
+	// primary is id of key used to encrypt
+	//
+	primary string
+
+	// keys is all keys, key is key id
+	//
+	keys map[string]*key
+
+	// ids is key id in order, used to decrypt legacy string
+	//
+	ids []string
+
+	// noLegacy is true if legacy AES-CBC string is not decrypted
+	//
+	noLegacy bool
+}
+
+// NewKeyring create keyring, primary must be one of keys. key must be 16, 24 or 32 bytes to use AES-128, AES-192 or AES-256
+//
+//	keyring, err := NewKeyring("2021", map[string][]byte{"2021": key2021, "2020": key2020})
+//
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, found := keys[primary]; !found {
+		return nil, errors.Errorf("primary key %v not found", primary)
+	}
+	keyring := &Keyring{
+		primary: primary,
+		keys:    map[string]*key{},
+		ids:     []string{primary},
+	}
+	for id, raw := range keys {
+		if id == "" || len(id) > maxKeyIDLength {
+			return nil, errors.Errorf("key id %v must be 1-%v bytes", id, maxKeyIDLength)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "must use 128/192/256 bit key in %v, got %d bytes", id, len(raw))
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrapf(err, "new gcm %v", id)
+		}
+		keyring.keys[id] = &key{raw: raw, block: block, aead: aead}
+		if id != primary {
+			keyring.ids = append(keyring.ids, id)
+		}
+	}
+	return keyring, nil
+}
+
+// LoadKeyring create keyring from key files in /keys, first file is primary key. key id is file name without extension
+//
+//	keyring, err := LoadKeyring("crypto-2021.key", "crypto.key")
+//
+func LoadKeyring(filenames ...string) (*Keyring, error) {
+	if len(filenames) == 0 {
+		return nil, errors.New("key files must not empty")
+	}
+	keys := map[string][]byte{}
+	for _, filename := range filenames {
+		raw, err := file.Key(filename)
+		if err != nil {
+			return nil, errors.Wrapf(err, "/keys/%v not found", filename)
+		}
+		keys[keyID(filename)] = raw
+	}
+	keyring, err := NewKeyring(keyID(filenames[0]), keys)
+	if err != nil {
+		return nil, err
+	}
+	// keep legacy decrypt order same as key files
+	keyring.ids = keyring.ids[:0]
+	for _, filename := range filenames {
+		keyring.ids = append(keyring.ids, keyID(filename))
+	}
+	return keyring, nil
+}
+
+// keyID return key id from key file name
+//
+//	id := keyID("crypto.key") // "crypto"
+//
+func keyID(filename string) string {
+	name := path.Base(filename)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// SetLegacy enable or disable decrypt legacy AES-CBC string, legacy string has no integrity check so disable it once all string are rotated. default is enabled
+//
+//	keyring.SetLegacy(false)
+//
+func (c *Keyring) SetLegacy(enabled bool) {
+	c.noLegacy = !enabled
+}
+
+// Primary return id of key used to encrypt
+//
+//	id := keyring.Primary()
+//
+func (c *Keyring) Primary() string {
+	return c.primary
+}
+
+// Encrypt string using AES-GCM with random nonce and primary key, key id is saved in header so it can be decrypted after key rotation
+//
+//	crypted, err := keyring.Encrypt("hello")
+//
+func (c *Keyring) Encrypt(text string) (string, error) {
+	k := c.keys[c.primary]
+	header := make([]byte, 0, 1+len(c.primary)+k.aead.NonceSize())
+	header = append(header, byte(len(c.primary)))
+	header = append(header, c.primary...)
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "read nonce")
+	}
+	crypted := k.aead.Seal(append(header, nonce...), nonce, []byte(text), additionalData(header))
+	return versionPrefix + base64.RawStdEncoding.EncodeToString(crypted), nil
+}
+
+// Decrypt string encrypted by any key in keyring, string encrypted by legacy AES-CBC version can still be decrypted
+//
+//	text, err := keyring.Decrypt(crypted)
+//
+func (c *Keyring) Decrypt(crypted string) (string, error) {
+	text, _, err := c.decrypt(crypted)
+	return text, err
+}
+
+// Rotate re-encrypt string using primary key if it is encrypted by legacy version or old key, return true if string changed
+//
+//	crypted, changed, err := keyring.Rotate(crypted)
+//
+func (c *Keyring) Rotate(crypted string) (string, bool, error) {
+	text, id, err := c.decrypt(crypted)
+	if err != nil {
+		return "", false, err
+	}
+	if id == c.primary {
+		return crypted, false, nil
+	}
+	result, err := c.Encrypt(text)
+	if err != nil {
+		return "", false, err
+	}
+	return result, true, nil
+}
+
+// decrypt string, return text and id of key used to encrypt, id is empty if string is legacy version. string start with version prefix is never decrypted as legacy, so tampered string can not bypass authentication
+//
+func (c *Keyring) decrypt(crypted string) (string, string, error) {
+	if crypted == "" {
+		return "", "", errors.New("input must not empty")
+	}
+	if strings.HasPrefix(crypted, versionPrefix) {
+		data, err := base64.RawStdEncoding.DecodeString(crypted[len(versionPrefix):])
+		if err != nil {
+			return "", "", errors.Wrap(err, "decode base64")
+		}
+		return c.open(data)
+	}
+	if c.noLegacy {
+		return "", "", errors.New("unknown ciphertext version")
+	}
+	data, err := base64.RawStdEncoding.DecodeString(crypted)
+	if err != nil {
+		return "", "", errors.Wrap(err, "decode base64")
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return "", "", errors.New("unknown ciphertext version")
+	}
+	text, err := c.openLegacy(data)
+	if err != nil {
+		return "", "", err
+	}
+	return text, "", nil
+}
+
+// additionalData return data authenticated with sealed text, it bind version and key id to ciphertext
+//
+func additionalData(header []byte) []byte {
+	return append([]byte(versionPrefix), header...)
+}
+
+// open decrypt data after version prefix
+//
+func (c *Keyring) open(data []byte) (string, string, error) {
+	if len(data) < 1 {
+		return "", "", errors.New("ciphertext too short")
+	}
+	idEnd := 1 + int(data[0])
+	if len(data) < idEnd {
+		return "", "", errors.New("ciphertext too short")
+	}
+	id := string(data[1:idEnd])
+	k, found := c.keys[id]
+	if !found {
+		return "", "", errors.Errorf("key %v not found", id)
+	}
+	nonceEnd := idEnd + k.aead.NonceSize()
+	if len(data) < nonceEnd+k.aead.Overhead() {
+		return "", "", errors.New("ciphertext too short")
+	}
+	text, err := k.aead.Open(nil, data[idEnd:nonceEnd], data[nonceEnd:], additionalData(data[:idEnd]))
+	if err != nil {
+		return "", "", errors.Wrap(err, "open ciphertext")
+	}
+	return string(text), id, nil
+}
+
+// openLegacy decrypt data encrypted by legacy AES-CBC version which use key as iv and pkcs7 padding. legacy string has no key id, so every key is tried and result is rejected if more than one key has valid padding, wrong key can pass padding check by chance
+//
+func (c *Keyring) openLegacy(data []byte) (string, error) {
+	result := ""
+	found := 0
+	for _, id := range c.ids {
+		k := c.keys[id]
+		orig := make([]byte, len(data))
+		cipher.NewCBCDecrypter(k.block, k.raw[:aes.BlockSize]).CryptBlocks(orig, data)
+		if text, ok := pkcs7UnPadding(orig); ok {
+			result = string(text)
+			found++
+		}
+	}
+	switch found {
+	case 0:
+		return "", errors.New("unknown ciphertext version")
+	case 1:
+		return result, nil
+	}
+	return "", errors.Errorf("legacy ciphertext is ambiguous, %v keys can decrypt it", found)
+}
+
+// encryptLegacy encrypt text using legacy AES-CBC version, only used to test legacy decrypt
+//
+func (c *Keyring) encryptLegacy(text string) string {
+	k := c.keys[c.primary]
+	data := pkcs7Padding([]byte(text), aes.BlockSize)
+	crypted := make([]byte, len(data))
+	cipher.NewCBCEncrypter(k.block, k.raw[:aes.BlockSize]).CryptBlocks(crypted, data)
+	return base64.RawStdEncoding.EncodeToString(crypted)
+}
+
+func pkcs7Padding(ciphertext []byte, blocksize int) []byte {
+	padding := blocksize - len(ciphertext)%blocksize
+	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
+	return append(ciphertext, padtext...)
+}
+
+// pkcs7UnPadding remove padding, return false if padding is invalid
+//
+func pkcs7UnPadding(origData []byte) ([]byte, bool) {
+	length := len(origData)
+	if length == 0 {
+		return nil, false
+	}
+	unpadding := int(origData[length-1])
+	if unpadding == 0 || unpadding > aes.BlockSize || unpadding > length {
+		return nil, false
+	}
+	for _, b := range origData[length-unpadding:] {
+		if int(b) != unpadding {
+			return nil, false
+		}
+	}
+	return origData[:(length - unpadding)], true
+}
@@ -0,0 +1,206 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var key2020 = []byte("0123456789abcdef")
+
+var key2021 = []byte("abcdef0123456789abcdef0123456789")
+
+func TestKeyring(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	keyring, err := NewKeyring("2021", map[string][]byte{"2021": key2021, "2020": key2020})
+	assert.Nil(err)
+	assert.Equal("2021", keyring.Primary())
+
+	crypted, err := keyring.Encrypt("hello")
+	assert.Nil(err)
+	crypted2, err := keyring.Encrypt("hello")
+	assert.Nil(err)
+	assert.NotEqual(crypted, crypted2) // random nonce
+
+	text, err := keyring.Decrypt(crypted)
+	assert.Nil(err)
+	assert.Equal("hello", text)
+
+	// empty string
+	crypted, err = keyring.Encrypt("")
+	assert.Nil(err)
+	text, err = keyring.Decrypt(crypted)
+	assert.Nil(err)
+	assert.Equal("", text)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	old, err := NewKeyring("2020", map[string][]byte{"2020": key2020})
+	assert.Nil(err)
+	crypted, err := old.Encrypt("hello")
+	assert.Nil(err)
+
+	keyring, err := NewKeyring("2021", map[string][]byte{"2021": key2021, "2020": key2020})
+	assert.Nil(err)
+	text, err := keyring.Decrypt(crypted)
+	assert.Nil(err)
+	assert.Equal("hello", text)
+
+	// rotate to primary key
+	rotated, changed, err := keyring.Rotate(crypted)
+	assert.Nil(err)
+	assert.True(changed)
+	_, err = old.Decrypt(rotated)
+	assert.NotNil(err)
+	text, err = keyring.Decrypt(rotated)
+	assert.Nil(err)
+	assert.Equal("hello", text)
+
+	same, changed, err := keyring.Rotate(rotated)
+	assert.Nil(err)
+	assert.False(changed)
+	assert.Equal(rotated, same)
+
+	// key removed from keyring
+	removed, err := NewKeyring("2021", map[string][]byte{"2021": key2021})
+	assert.Nil(err)
+	_, err = removed.Decrypt(crypted)
+	assert.NotNil(err)
+}
+
+func TestKeyringLegacy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	old, err := NewKeyring("crypto", map[string][]byte{"crypto": key2020})
+	assert.Nil(err)
+	legacy := old.encryptLegacy("hello legacy")
+
+	keyring, err := NewKeyring("2021", map[string][]byte{"2021": key2021, "crypto": key2020})
+	assert.Nil(err)
+	text, err := keyring.Decrypt(legacy)
+	assert.Nil(err)
+	assert.Equal("hello legacy", text)
+
+	rotated, changed, err := keyring.Rotate(legacy)
+	assert.Nil(err)
+	assert.True(changed)
+	text, err = keyring.Decrypt(rotated)
+	assert.Nil(err)
+	assert.Equal("hello legacy", text)
+
+	// legacy disabled
+	keyring.SetLegacy(false)
+	_, err = keyring.Decrypt(legacy)
+	assert.NotNil(err)
+	text, err = keyring.Decrypt(rotated)
+	assert.Nil(err)
+	assert.Equal("hello legacy", text)
+}
+
+func TestKeyringLegacyVersionByte(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	keyring, err := NewKeyring("crypto", map[string][]byte{"crypto": key2020})
+	assert.Nil(err)
+
+	// legacy string may start with any byte, it must still decrypt as legacy
+	for i := 0; i < 4096; i++ {
+		text := "legacy" + strconv.Itoa(i)
+		legacy := keyring.encryptLegacy(text)
+		data, err := base64.RawStdEncoding.DecodeString(legacy)
+		assert.Nil(err)
+		if data[0] != 1 {
+			continue
+		}
+		result, err := keyring.Decrypt(legacy)
+		assert.Nil(err)
+		assert.Equal(text, result)
+		return
+	}
+	assert.Fail("no legacy string start with byte 1")
+}
+
+func TestKeyringLegacyAmbiguous(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	old, err := NewKeyring("crypto", map[string][]byte{"crypto": key2020})
+	assert.Nil(err)
+	keyring, err := NewKeyring("2021", map[string][]byte{"2021": key2021, "crypto": key2020})
+	assert.Nil(err)
+
+	// wrong key may pass padding check, result must be rejected instead of return wrong text
+	for i := 0; i < 100000; i++ {
+		text := "legacy" + strconv.Itoa(i)
+		legacy := old.encryptLegacy(text)
+		data, err := base64.RawStdEncoding.DecodeString(legacy)
+		assert.Nil(err)
+		orig := make([]byte, len(data))
+		k := keyring.keys["2021"]
+		cipher.NewCBCDecrypter(k.block, k.raw[:aes.BlockSize]).CryptBlocks(orig, data)
+		if _, ok := pkcs7UnPadding(orig); !ok {
+			continue
+		}
+		_, err = keyring.Decrypt(legacy)
+		assert.NotNil(err)
+		assert.Contains(err.Error(), "ambiguous")
+		return
+	}
+	assert.Fail("no legacy string can be decrypted by wrong key")
+}
+
+func TestKeyringTamper(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	keyring, err := NewKeyring("2021", map[string][]byte{"2021": key2021})
+	assert.Nil(err)
+	crypted, err := keyring.Encrypt("hello")
+	assert.Nil(err)
+
+	assert.True(strings.HasPrefix(crypted, versionPrefix))
+	data, err := base64.RawStdEncoding.DecodeString(crypted[len(versionPrefix):])
+	assert.Nil(err)
+	data[len(data)-1] ^= 1
+	_, err = keyring.Decrypt(versionPrefix + base64.RawStdEncoding.EncodeToString(data))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "open ciphertext")
+
+	// key id not in keyring
+	data[len(data)-1] ^= 1
+	data[1] ^= 1
+	_, err = keyring.Decrypt(versionPrefix + base64.RawStdEncoding.EncodeToString(data))
+	assert.NotNil(err)
+
+	_, err = keyring.Decrypt("")
+	assert.NotNil(err)
+	_, err = keyring.Decrypt("something wrong")
+	assert.NotNil(err)
+	_, err = keyring.Decrypt("AQ")
+	assert.NotNil(err)
+	_, err = keyring.Decrypt(versionPrefix)
+	assert.NotNil(err)
+}
+
+func TestKeyringInvalid(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	_, err := NewKeyring("2021", map[string][]byte{"2020": key2020})
+	assert.NotNil(err)
+	_, err = NewKeyring("2021", map[string][]byte{"2021": []byte("short")})
+	assert.NotNil(err)
+	_, err = NewKeyring("", map[string][]byte{"": key2020})
+	assert.NotNil(err)
+	_, err = LoadKeyring()
+	assert.NotNil(err)
+	_, err = LoadKeyring("not-exist.key")
+	assert.NotNil(err)
+	assert.Equal("crypto", keyID("crypto.key"))
+	assert.Equal("crypto-2021", keyID("dir/crypto-2021.key"))
+}
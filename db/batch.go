@@ -46,7 +46,7 @@ type Batch interface {
 	//
 	DeleteList(ctx context.Context, obj Object, list []string)
 
-	// DeleteRef delete object use document ref
+	// DeleteRef permanently delete object use document ref, soft delete is not applied cause ref has no object
 	//
 	//	DeleteRef(ref) // no error in batch mode
 	//
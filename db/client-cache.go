@@ -0,0 +1,482 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/piyuo/libsrv/cache"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	// register type firestore may return in Select, so field value can be cached
+	gob.Register(time.Time{})
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// CacheClient decorate client, cache Get/Exists/Select result of collection in cache package, cached result is removed when object is changed through this client
+// read inside Transaction always bypass cache, write through Query.Delete() or other client is not noticed, use InvalidateCollection() to remove cached result
+//
+//	client := db.NewCacheClient(gdbClient, map[string]time.Duration{
+//		"Country": time.Hour,
+//	})
+//
+type CacheClient struct {
+	Client
+
+	// ttls is cache duration by collection name, collection not in ttls is not cached
+	//
+	ttls map[string]time.Duration
+
+	// fieldsMutex protect fields
+	//
+	fieldsMutex sync.Mutex
+
+	// fields is field ever selected by collection name, cached field need to remove when object change
+	//
+	fields map[string]map[string]bool
+}
+
+// NewCacheClient decorate client with cache, ttls is cache duration by collection name, 0 mean cache's default 20 minutes
+//
+//	client := db.NewCacheClient(gdbClient, map[string]time.Duration{
+//		"Country": time.Hour,
+//	})
+//
+func NewCacheClient(client Client, ttls map[string]time.Duration) *CacheClient {
+	return &CacheClient{
+		Client: client,
+		ttls:   ttls,
+		fields: map[string]map[string]bool{},
+	}
+}
+
+// collectionPrefix return cache key prefix of collection, it is also cache namespace so whole collection can be invalidated
+//
+func collectionPrefix(collection string) string {
+	return "db" + strings.ReplaceAll(collection, "-", "_") + "-"
+}
+
+// cached return cache duration and true if collection is cached
+//
+func (c *CacheClient) cached(obj Object) (time.Duration, bool) {
+	if obj == nil {
+		return 0, false
+	}
+	ttl, found := c.ttls[obj.Collection()]
+	return ttl, found
+}
+
+// Get data object from cache or data store, return nil if object does not exist
+//
+//	object, err := Get(ctx, &Sample{}, "id")
+//
+func (c *CacheClient) Get(ctx context.Context, obj Object, id string) (Object, error) {
+	ttl, ok := c.cached(obj)
+	if !ok || id == "" {
+		return c.Client.Get(ctx, obj, id)
+	}
+	key := collectionPrefix(obj.Collection()) + id
+	var loadedObj Object
+	found, data, err := cache.GetOrLoad(key, ttl, func() ([]byte, error) {
+		o, err := c.Client.Get(ctx, obj, id)
+		if err != nil {
+			return nil, err
+		}
+		if o == nil {
+			return nil, cache.ErrNotFound
+		}
+		loadedObj = o
+		return encodeCache(o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	if loadedObj != nil {
+		return loadedObj, nil
+	}
+	newObj := obj.Factory()
+	if err := decodeCache(data, newObj); err != nil {
+		return c.Client.Get(ctx, obj, id)
+	}
+	newObj.SetID(id)
+	return newObj, nil
+}
+
+// Exists return true if object with id exist, result come from cached object
+//
+//	found,err := Exists(ctx, &Sample{}, "id")
+//
+func (c *CacheClient) Exists(ctx context.Context, obj Object, id string) (bool, error) {
+	if _, ok := c.cached(obj); !ok || id == "" {
+		return c.Client.Exists(ctx, obj, id)
+	}
+	o, err := c.Get(ctx, obj, id)
+	if err != nil {
+		return false, err
+	}
+	return o != nil, nil
+}
+
+// Select return object field from cache or data store, return nil if object does not exist
+//
+//	return Select(ctx, &Sample{}, id, field)
+//
+func (c *CacheClient) Select(ctx context.Context, obj Object, id, field string) (interface{}, error) {
+	ttl, ok := c.cached(obj)
+	if !ok || id == "" {
+		return c.Client.Select(ctx, obj, id, field)
+	}
+	c.fieldsMutex.Lock()
+	if c.fields[obj.Collection()] == nil {
+		c.fields[obj.Collection()] = map[string]bool{}
+	}
+	c.fields[obj.Collection()][field] = true
+	c.fieldsMutex.Unlock()
+
+	key := collectionPrefix(obj.Collection()) + id + "/" + field
+	found, data, err := cache.GetOrLoad(key, ttl, func() ([]byte, error) {
+		value, err := c.Client.Select(ctx, obj, id, field)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, cache.ErrNotFound
+		}
+		return encodeCache(&value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	var value interface{}
+	if err := decodeCache(data, &value); err != nil {
+		return c.Client.Select(ctx, obj, id, field)
+	}
+	return value, nil
+}
+
+// Set object into data store and remove cached object
+//
+//	 err := Set(ctx, object)
+//
+func (c *CacheClient) Set(ctx context.Context, obj Object) error {
+	c.invalidate(obj, "")
+	err := c.Client.Set(ctx, obj)
+	c.invalidate(obj, "")
+	return err
+}
+
+// Update partial object field and remove cached object
+//
+//	err = Update(ctx, Sample, map[string]interface{}{
+//		"desc": "hi",
+//	})
+//
+func (c *CacheClient) Update(ctx context.Context, obj Object, fields map[string]interface{}) error {
+	c.invalidate(obj, "")
+	err := c.Client.Update(ctx, obj, fields)
+	c.invalidate(obj, "")
+	return err
+}
+
+// Increment value on object field and remove cached object
+//
+//	err := Increment(ctx,sample, "Value", 2)
+//
+func (c *CacheClient) Increment(ctx context.Context, obj Object, field string, value int) error {
+	c.invalidate(obj, "")
+	err := c.Client.Increment(ctx, obj, field, value)
+	c.invalidate(obj, "")
+	return err
+}
+
+// Delete object and remove cached object
+//
+//	Delete(ctx, sample)
+//
+func (c *CacheClient) Delete(ctx context.Context, obj Object) error {
+	id := ""
+	if obj != nil {
+		id = obj.ID() // delete will clear object id
+	}
+	c.invalidate(obj, id)
+	err := c.Client.Delete(ctx, obj)
+	c.invalidate(obj, id)
+	return err
+}
+
+// Restore undelete soft deleted object and remove cached object
+//
+//	err := Restore(ctx, sample)
+//
+func (c *CacheClient) Restore(ctx context.Context, obj Object) error {
+	c.invalidate(obj, "")
+	err := c.Client.Restore(ctx, obj)
+	c.invalidate(obj, "")
+	return err
+}
+
+// Purge permanently delete soft deleted object and remove cached collection
+//
+//	count, err := Purge(ctx, &Sample{}, 30*24*time.Hour)
+//
+func (c *CacheClient) Purge(ctx context.Context, obj Object, retention time.Duration) (int, error) {
+	count, err := c.Client.Purge(ctx, obj, retention)
+	c.InvalidateCollection(obj)
+	return count, err
+}
+
+// Truncate delete all document in collection and remove cached collection
+// ! only use truncate in test
+//	err := Truncate(ctx, "Sample")
+//
+func (c *CacheClient) Truncate(ctx context.Context, collectionName string) error {
+	err := c.Client.Truncate(ctx, collectionName)
+	c.invalidateCollection(collectionName)
+	return err
+}
+
+// Transaction start a transaction operation, read in transaction bypass cache, object changed in transaction is removed from cache when transaction end
+//
+//	err := Transaction(ctx, func(ctx context.Context,tx db.Transaction) error {
+//		return nil
+//	})
+//
+func (c *CacheClient) Transaction(ctx context.Context, f TransactionFunc) error {
+	recorder := &cacheRecorder{client: c}
+	defer recorder.invalidate()
+	return c.Client.Transaction(ctx, func(ctx context.Context, tx Transaction) error {
+		return f(ctx, &cacheTransaction{Transaction: tx, recorder: recorder})
+	})
+}
+
+// Batch start a batch operation, object changed in batch is removed from cache when batch end
+//
+//	err := Batch(ctx, func(ctx context.Context,bc db.Batch) error {
+//		return nil
+//	})
+//
+func (c *CacheClient) Batch(ctx context.Context, f BatchFunc) error {
+	recorder := &cacheRecorder{client: c}
+	defer recorder.invalidate()
+	return c.Client.Batch(ctx, func(ctx context.Context, bc Batch) error {
+		return f(ctx, &cacheBatch{Batch: bc, recorder: recorder})
+	})
+}
+
+// InvalidateCollection remove all cached object of collection
+//
+//	client.InvalidateCollection(&Sample{})
+//
+func (c *CacheClient) InvalidateCollection(obj Object) {
+	if _, ok := c.cached(obj); ok {
+		c.invalidateCollection(obj.Collection())
+	}
+}
+
+// invalidateCollection remove all cached object of collection name
+//
+func (c *CacheClient) invalidateCollection(collection string) {
+	if _, ok := c.ttls[collection]; ok {
+		cache.InvalidateNamespace(collectionPrefix(collection))
+	}
+}
+
+// invalidate remove cached object and selected field, use object id if id is empty.
+// write call it before and after write, so object loaded by concurrent read during write will not stay in cache
+//
+func (c *CacheClient) invalidate(obj Object, id string) {
+	if _, ok := c.cached(obj); !ok {
+		return
+	}
+	if id == "" {
+		id = obj.ID()
+	}
+	c.invalidateID(obj.Collection(), id)
+}
+
+// invalidateID remove cached object and selected field by collection name and id
+//
+func (c *CacheClient) invalidateID(collection, id string) {
+	if _, ok := c.ttls[collection]; !ok || id == "" {
+		return
+	}
+	key := collectionPrefix(collection) + id
+	cache.Delete(key)
+	c.fieldsMutex.Lock()
+	defer c.fieldsMutex.Unlock()
+	for field := range c.fields[collection] {
+		cache.Delete(key + "/" + field)
+	}
+}
+
+// encodeCache encode value to bytes using gob
+//
+func encodeCache(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, errors.Wrap(err, "encode cache")
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeCache decode bytes to value using gob
+//
+func decodeCache(data []byte, value interface{}) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(value); err != nil {
+		return errors.Wrap(err, "decode cache")
+	}
+	return nil
+}
+
+// cacheRecorder record object changed in transaction or batch, remove them from cache when operation end
+//
+type cacheRecorder struct {
+
+	// client is cache client
+	//
+	client *CacheClient
+
+	// mutex protect keys
+	//
+	mutex sync.Mutex
+
+	// keys is changed object collection name and id
+	//
+	keys [][2]string
+}
+
+// record changed object
+//
+func (c *cacheRecorder) record(collection, id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.keys = append(c.keys, [2]string{collection, id})
+}
+
+// recordObject record changed object, must call before operation cause delete will clear object id
+//
+func (c *cacheRecorder) recordObject(obj Object) {
+	if obj != nil {
+		c.record(obj.Collection(), obj.ID())
+	}
+}
+
+// invalidate remove recorded object from cache
+//
+func (c *cacheRecorder) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, key := range c.keys {
+		c.client.invalidateID(key[0], key[1])
+	}
+}
+
+// cacheTransaction is transaction record changed object, read is not cached
+//
+type cacheTransaction struct {
+	Transaction
+
+	// recorder record changed object
+	//
+	recorder *cacheRecorder
+}
+
+// Set object into data store and record it
+//
+func (c *cacheTransaction) Set(ctx context.Context, obj Object) error {
+	err := c.Transaction.Set(ctx, obj)
+	c.recorder.recordObject(obj)
+	return err
+}
+
+// Update partial object field and record it
+//
+func (c *cacheTransaction) Update(ctx context.Context, obj Object, fields map[string]interface{}) error {
+	c.recorder.recordObject(obj)
+	return c.Transaction.Update(ctx, obj, fields)
+}
+
+// Increment value on object field and record it
+//
+func (c *cacheTransaction) Increment(ctx context.Context, obj Object, field string, value int) error {
+	c.recorder.recordObject(obj)
+	return c.Transaction.Increment(ctx, obj, field, value)
+}
+
+// Delete object and record it
+//
+func (c *cacheTransaction) Delete(ctx context.Context, obj Object) error {
+	c.recorder.recordObject(obj)
+	return c.Transaction.Delete(ctx, obj)
+}
+
+// cacheBatch is batch record changed object
+//
+type cacheBatch struct {
+	Batch
+
+	// recorder record changed object
+	//
+	recorder *cacheRecorder
+}
+
+// Set object into data store and record it
+//
+func (c *cacheBatch) Set(ctx context.Context, obj Object) {
+	c.Batch.Set(ctx, obj)
+	c.recorder.recordObject(obj)
+}
+
+// Update partial object field and record it
+//
+func (c *cacheBatch) Update(ctx context.Context, obj Object, fields map[string]interface{}) {
+	c.recorder.recordObject(obj)
+	c.Batch.Update(ctx, obj, fields)
+}
+
+// Increment value on object field and record it
+//
+func (c *cacheBatch) Increment(ctx context.Context, obj Object, field string, value int) {
+	c.recorder.recordObject(obj)
+	c.Batch.Increment(ctx, obj, field, value)
+}
+
+// Delete object and record it
+//
+func (c *cacheBatch) Delete(ctx context.Context, obj Object) {
+	c.recorder.recordObject(obj)
+	c.Batch.Delete(ctx, obj)
+}
+
+// DeleteList delete object by id list and record them
+//
+func (c *cacheBatch) DeleteList(ctx context.Context, obj Object, list []string) {
+	if obj != nil {
+		for _, id := range list {
+			c.recorder.record(obj.Collection(), id)
+		}
+	}
+	c.Batch.DeleteList(ctx, obj, list)
+}
+
+// DeleteRef delete object by reference and record it
+//
+func (c *cacheBatch) DeleteRef(ref *firestore.DocumentRef) {
+	if ref != nil && ref.Parent != nil {
+		c.recorder.record(ref.Parent.ID, ref.ID)
+	}
+	c.Batch.DeleteRef(ref)
+}
@@ -30,6 +30,15 @@ type Client interface {
 	//
 	Get(ctx context.Context, obj Object, id string) (Object, error)
 
+	// Watch return watcher deliver change of object with id, first change is added if object exist
+	//
+	//	watcher := Watch(ctx, &Sample{}, "id")
+	//	defer watcher.Close()
+	//	for change := range watcher.Changes() {
+	//	}
+	//
+	Watch(ctx context.Context, obj Object, id string) Watcher
+
 	// Exists return true if object with id exist
 	//
 	//	found,err := Exists(ctx, &Sample{}, "id")
@@ -54,14 +63,20 @@ type Client interface {
 	//
 	Query(obj Object) Query
 
+	// QueryWithDeleted create query include soft deleted object and object has no delete time field, use it to migrate or backfill collection
+	//
+	//	c.QueryWithDeleted(&Sample{}).Return(ctx)
+	//
+	QueryWithDeleted(obj Object) Query
+
 	// Set object into data store, If the document does not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data,
-	// if object does not have id, it will created using UUID
+	// if object does not have id, it will created using UUID. versioned object return ErrConflict if stored version has moved
 	//
 	//	 err := Set(ctx, object)
 	//
 	Set(ctx context.Context, obj Object) error
 
-	// Update partial object field, create new one if object does not exist, this function is significant slow than Set()
+	// Update partial object field, create new one if object does not exist, this function is significant slow than Set(). versioned object return ErrConflict if stored version has moved
 	//
 	//	err = Update(ctx, Sample, map[string]interface{}{
 	//		"desc": "hi",
@@ -81,6 +96,18 @@ type Client interface {
 	//
 	Delete(ctx context.Context, obj Object) error
 
+	// Restore undelete soft deleted object, return error if object does not exist or not use soft delete
+	//
+	//	err := Restore(ctx, sample)
+	//
+	Restore(ctx context.Context, obj Object) error
+
+	// Purge permanently delete soft deleted object which deleted before retention, return number of object deleted
+	//
+	//	count, err := Purge(ctx, &Sample{}, 30*24*time.Hour)
+	//
+	Purge(ctx context.Context, obj Object, retention time.Duration) (int, error)
+
 	// Truncate delete all document in collection. max 100 documents.
 	// ! only use truncate in test
 	//	done,numDeleted, err := Truncate(ctx, "Sample")
@@ -156,7 +183,7 @@ type BaseClient struct {
 	Client
 }
 
-// BeforeSet add create/update time, accountID, userID before set to database
+// BeforeSet add create/update time, accountID, userID and delete time of soft delete object before set to database
 //
 func (c *BaseClient) BeforeSet(ctx context.Context, obj Object) {
 	if obj.ID() == "" {
@@ -167,6 +194,11 @@ func (c *BaseClient) BeforeSet(ctx context.Context, obj Object) {
 	obj.SetCreateTime(t)
 	obj.SetUpdateTime(t)
 
+	// object use soft delete always save delete time, so query can exclude deleted object
+	if obj.SoftDelete() {
+		obj.SetDeleteTime(obj.DeleteTime())
+	}
+
 	accountID := env.GetAccountID(ctx)
 	if accountID != "" {
 		obj.SetAccountID(accountID)
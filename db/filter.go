@@ -0,0 +1,252 @@
+package db
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// MaxFilterValues is max number of value in "in", "not-in" and "array-contains-any" filter
+//
+const MaxFilterValues = 10
+
+// MaxDisjunctions is max number of and-group after expand or filter, each group is a query to database
+//
+const MaxDisjunctions = 10
+
+// composite define how sub filters combine
+//
+type composite int
+
+const (
+	// none mean filter is a field filter
+	//
+	none composite = iota
+
+	// and mean all sub filters must match
+	//
+	and
+
+	// or mean at least one sub filter must match
+	//
+	or
+)
+
+// Filter is query condition, use Field() to create field filter, And() and Or() to combine filters
+//
+//	filter := db.Or(
+//		db.Field("Tag", "==", "a"),
+//		db.And(db.Field("Tag", "==", "b"), db.Field("Value", ">", 1)),
+//	)
+//	list, err := Query(&Sample{}).WhereFilter(filter).Return(ctx)
+//
+type Filter struct {
+
+	// Path is field path, "ID" mean document id
+	//
+	Path string
+
+	// Op is operator like "==", "in", "array-contains"
+	//
+	Op string
+
+	// Value is value to compare with field
+	//
+	Value interface{}
+
+	// composite is none for field filter, and/or for composite filter
+	//
+	composite composite
+
+	// filters is sub filters of composite filter
+	//
+	filters []*Filter
+}
+
+// Field create field filter, if path == "ID" mean using document id in as filter
+//
+//	filter := db.Field("Tag", "in", []string{"a", "b"})
+//
+func Field(path, op string, value interface{}) *Filter {
+	return &Filter{Path: path, Op: op, Value: value}
+}
+
+// And create filter match when all filters match
+//
+//	filter := db.And(db.Field("Tag", "==", "a"), db.Field("Value", ">", 1))
+//
+func And(filters ...*Filter) *Filter {
+	return &Filter{composite: and, filters: filters}
+}
+
+// Or create filter match when any filter match
+//
+//	filter := db.Or(db.Field("Tag", "==", "a"), db.Field("Tag", "==", "b"))
+//
+func Or(filters ...*Filter) *Filter {
+	return &Filter{composite: or, filters: filters}
+}
+
+// AssertOp return error if op is not supported
+//
+//	err := AssertOp("array-contains")
+//
+func AssertOp(op string) error {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "in", "not-in", "array-contains", "array-contains-any":
+		return nil
+	}
+	return errors.Errorf("unsupported operator %q", op)
+}
+
+// IsInequality return true if op is inequality operator, firestore only allow inequality on single field
+//
+//	inequality := IsInequality(">")
+//
+func IsInequality(op string) bool {
+	switch op {
+	case "!=", "<", "<=", ">", ">=", "not-in":
+		return true
+	}
+	return false
+}
+
+// isListOp return true if op need list value
+//
+func isListOp(op string) bool {
+	switch op {
+	case "in", "not-in", "array-contains-any":
+		return true
+	}
+	return false
+}
+
+// assertField return error if field filter is not valid
+//
+func (f *Filter) assertField() error {
+	if f.Path == "" {
+		return errors.New("filter path must not empty")
+	}
+	if err := AssertOp(f.Op); err != nil {
+		return errors.Wrapf(err, "filter %v", f.Path)
+	}
+	if !isListOp(f.Op) {
+		return nil
+	}
+	v := reflect.ValueOf(f.Value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return errors.Errorf("filter %v %v value must be list, got %T", f.Path, f.Op, f.Value)
+	}
+	if v.Len() == 0 || v.Len() > MaxFilterValues {
+		return errors.Errorf("filter %v %v must have 1 to %v values, got %v", f.Path, f.Op, MaxFilterValues, v.Len())
+	}
+	return nil
+}
+
+// Disjunctions expand filter into or of and-groups, each group is list of field filter must all match. return error if filter is invalid or combination is not supported by firestore
+//
+//	groups, err := filter.Disjunctions()
+//
+func (f *Filter) Disjunctions() ([][]*Filter, error) {
+	groups, err := f.expand()
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) > MaxDisjunctions {
+		return nil, errors.Errorf("filter expand to %v queries, max is %v", len(groups), MaxDisjunctions)
+	}
+	for _, group := range groups {
+		if err := assertGroup(group); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// expand filter into or of and-groups
+//
+func (f *Filter) expand() ([][]*Filter, error) {
+	if f == nil {
+		return nil, errors.New("filter must not nil")
+	}
+	switch f.composite {
+	case none:
+		if err := f.assertField(); err != nil {
+			return nil, err
+		}
+		return [][]*Filter{{f}}, nil
+	case or:
+		if len(f.filters) == 0 {
+			return nil, errors.New("or filter must has sub filter")
+		}
+		result := [][]*Filter{}
+		for _, sub := range f.filters {
+			groups, err := sub.expand()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, groups...)
+		}
+		return result, nil
+	}
+
+	if len(f.filters) == 0 {
+		return nil, errors.New("and filter must has sub filter")
+	}
+	result := [][]*Filter{{}}
+	for _, sub := range f.filters {
+		groups, err := sub.expand()
+		if err != nil {
+			return nil, err
+		}
+		product := [][]*Filter{}
+		for _, left := range result {
+			for _, right := range groups {
+				group := append(append([]*Filter{}, left...), right...)
+				product = append(product, group)
+			}
+		}
+		if len(product) > MaxDisjunctions {
+			return nil, errors.Errorf("filter expand to more than %v queries", MaxDisjunctions)
+		}
+		result = product
+	}
+	return result, nil
+}
+
+// assertGroup return error if and-group use combination not supported by firestore
+//
+func assertGroup(group []*Filter) error {
+	listOp := ""
+	arrayContains := false
+	notEqual := false
+	inequalityPath := ""
+	for _, f := range group {
+		switch f.Op {
+		case "in", "not-in", "array-contains-any":
+			if listOp != "" {
+				return errors.Errorf("can not combine %q with %q in one query", listOp, f.Op)
+			}
+			listOp = f.Op
+		}
+		switch f.Op {
+		case "array-contains", "array-contains-any":
+			if arrayContains {
+				return errors.New("only one array-contains or array-contains-any allowed in one query")
+			}
+			arrayContains = true
+		case "!=":
+			notEqual = true
+		}
+		if IsInequality(f.Op) {
+			if inequalityPath != "" && inequalityPath != f.Path {
+				return errors.Errorf("inequality filter on %v and %v, only one field allowed in one query", inequalityPath, f.Path)
+			}
+			inequalityPath = f.Path
+		}
+	}
+	if notEqual && listOp == "not-in" {
+		return errors.New("can not combine \"not-in\" with \"!=\" in one query")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package db
+
+// Iterator walk through query result page by page, only one page of object is keep in memory
+//
+//	iter := Query(&Sample{}).OrderBy("Name").Iterate(ctx, 100)
+//	defer iter.Close()
+//	for iter.Next() {
+//		sample := iter.Object().(*Sample)
+//	}
+//	if err := iter.Err(); err != nil {
+//		return err
+//	}
+//
+type Iterator interface {
+
+	// Next move to next object, return false when no more object or error happen, use Err() to check error
+	//
+	//	for iter.Next() {
+	//		sample := iter.Object().(*Sample)
+	//	}
+	//
+	Next() bool
+
+	// Object return current object, return nil if Next() never been called or return false
+	//
+	//	sample := iter.Object().(*Sample)
+	//
+	Object() Object
+
+	// Err return error happen in Next(), return ctx.Err() if context is canceled
+	//
+	//	err := iter.Err()
+	//
+	Err() error
+
+	// Close release resource used by iterator, it is safe to call Close() multiple times
+	//
+	//	defer iter.Close()
+	//
+	Close()
+}
+
+// DefaultPageSize is page size used by Iterate() when page size is not specific
+//
+const DefaultPageSize = 100
@@ -0,0 +1,341 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// MigrationCollection is collection name of migration record
+//
+const MigrationCollection = "Migration"
+
+// MigrationPageSize is object count read and rewrite in one batch, keep it under firestore 500 writes per batch limit
+//
+const MigrationPageSize = 100
+
+// MigrateFunc rewrite object, return true if object changed and need to save. function must be idempotent, the same object may pass in again when migration resume
+//
+//	func(ctx context.Context, obj db.Object) (bool, error) {
+//		sample := obj.(*Sample)
+//		if sample.Tag != "" {
+//			return false, nil
+//		}
+//		sample.Tag = "default"
+//		return true, nil
+//	}
+//
+type MigrateFunc func(ctx context.Context, obj Object) (bool, error)
+
+// Migration is versioned, named rewrite on all object in collection
+//
+type Migration struct {
+
+	// Version is migration version, migration run in version order
+	//
+	Version int
+
+	// Name is migration name, it must not change after migration applied
+	//
+	Name string
+
+	// Object is object used to query collection to migrate
+	//
+	Object Object
+
+	// Migrate rewrite object
+	//
+	Migrate MigrateFunc
+}
+
+// MigrationRecord record migration progress in migration collection, document id is migration version
+//
+type MigrationRecord struct {
+	Entity
+
+	// Name is migration name
+	//
+	Name string `firestore:"Name"`
+
+	// Token is page token to resume migration, empty mean start from first object
+	//
+	Token string `firestore:"Token"`
+
+	// Scanned is number of object read by migration
+	//
+	Scanned int `firestore:"Scanned"`
+
+	// Changed is number of object rewritten by migration
+	//
+	Changed int `firestore:"Changed"`
+
+	// Done is true if migration applied to all object
+	//
+	Done bool `firestore:"Done"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *MigrationRecord) Factory() Object {
+	return &MigrationRecord{}
+}
+
+// Collection return migration collection name
+//
+func (c *MigrationRecord) Collection() string {
+	return MigrationCollection
+}
+
+// MigrationResult is result of running one migration
+//
+type MigrationResult struct {
+
+	// Version is migration version
+	//
+	Version int
+
+	// Name is migration name
+	//
+	Name string
+
+	// Scanned is number of object read in this run
+	//
+	Scanned int
+
+	// Changed is number of object rewritten in this run, in dry run it is number of object would be rewritten
+	//
+	Changed int
+}
+
+// Migrator run registered migration and record applied version in migration collection, migration interrupted will resume from last saved page.
+// soft deleted object is migrated too, versioned object is saved with version check and page is migrated again if object changed by others
+//
+//	migrator := db.NewMigrator(client)
+//	migrator.Register(1, "default-tag", &Sample{}, func(ctx context.Context, obj db.Object) (bool, error) {
+//		return true, nil
+//	})
+//	results, err := migrator.Run(ctx, false)
+//
+type Migrator struct {
+
+	// client is database client
+	//
+	client Client
+
+	// migrations is registered migration, key is version
+	//
+	migrations map[int]*Migration
+
+	// err is error happen in Register(), it will be returned by Run()
+	//
+	err error
+}
+
+// NewMigrator create migrator using client
+//
+//	migrator := db.NewMigrator(client)
+//
+func NewMigrator(client Client) *Migrator {
+	return &Migrator{
+		client:     client,
+		migrations: map[int]*Migration{},
+	}
+}
+
+// Register add migration, version must be positive and unique. error will be returned by Run()
+//
+//	migrator.Register(1, "default-tag", &Sample{}, migrateFunc)
+//
+func (c *Migrator) Register(version int, name string, obj Object, f MigrateFunc) *Migrator {
+	if c.err != nil {
+		return c
+	}
+	if version <= 0 {
+		c.err = errors.Errorf("migration version %v must be positive", version)
+		return c
+	}
+	if _, found := c.migrations[version]; found {
+		c.err = errors.Errorf("migration version %v already registered", version)
+		return c
+	}
+	if name == "" || obj == nil || f == nil {
+		c.err = errors.Errorf("migration %v must have name, object and migrate function", version)
+		return c
+	}
+	c.migrations[version] = &Migration{Version: version, Name: name, Object: obj, Migrate: f}
+	return c
+}
+
+// Migrations return registered migration in version order
+//
+//	migrations := migrator.Migrations()
+//
+func (c *Migrator) Migrations() []*Migration {
+	list := make([]*Migration, 0, len(c.migrations))
+	for _, m := range c.migrations {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list
+}
+
+// Applied return version of migration applied to all object
+//
+//	versions, err := migrator.Applied(ctx)
+//
+func (c *Migrator) Applied(ctx context.Context) ([]int, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	versions := []int{}
+	for _, m := range c.Migrations() {
+		record, err := c.record(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		if record.Done {
+			versions = append(versions, m.Version)
+		}
+	}
+	return versions, nil
+}
+
+// Run apply pending migration in version order, migration already applied will be skipped. dry run only count object would be changed without any write
+//
+//	results, err := migrator.Run(ctx, true)
+//
+func (c *Migrator) Run(ctx context.Context, dryRun bool) ([]*MigrationResult, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	results := []*MigrationResult{}
+	for _, m := range c.Migrations() {
+		record, err := c.record(ctx, m)
+		if err != nil {
+			return results, err
+		}
+		if record.Done {
+			continue
+		}
+		result, err := c.migrate(ctx, m, record, dryRun)
+		results = append(results, result)
+		if err != nil {
+			return results, errors.Wrapf(err, "migrate %v-%v", m.Version, m.Name)
+		}
+	}
+	return results, nil
+}
+
+// record return migration record, return new record if migration never run
+//
+func (c *Migrator) record(ctx context.Context, m *Migration) (*MigrationRecord, error) {
+	id := strconv.Itoa(m.Version)
+	obj, err := c.client.Get(ctx, &MigrationRecord{}, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get migration record %v", id)
+	}
+	if obj == nil {
+		record := &MigrationRecord{Name: m.Name}
+		record.SetID(id)
+		return record, nil
+	}
+	record := obj.(*MigrationRecord)
+	if record.Name != m.Name {
+		return nil, errors.Errorf("migration %v is %v in record, but registered as %v", id, record.Name, m.Name)
+	}
+	return record, nil
+}
+
+// migrationConflictRetry is how many time a page is read and migrated again when versioned object changed by others during migration
+//
+const migrationConflictRetry = 3
+
+// migrate rewrite object page by page, soft deleted object is included. migration progress is saved after each page so migration can resume from last saved page
+//
+func (c *Migrator) migrate(ctx context.Context, m *Migration, record *MigrationRecord, dryRun bool) (*MigrationResult, error) {
+	result := &MigrationResult{Version: m.Version, Name: m.Name}
+	token := record.Token
+	for {
+		scanned, changed, next, err := c.migratePage(ctx, m, record, token, dryRun)
+		if err != nil {
+			return result, err
+		}
+		result.Scanned += scanned
+		result.Changed += changed
+		if next == "" {
+			return result, nil
+		}
+		token = next
+	}
+}
+
+// migratePage read and rewrite one page, page is read again if versioned object changed by others. return scanned and changed count and next page token
+//
+func (c *Migrator) migratePage(ctx context.Context, m *Migration, record *MigrationRecord, token string, dryRun bool) (int, int, string, error) {
+	// written remember object saved in failed attempt, it is unchanged when read again
+	written := map[string]bool{}
+	for retry := 0; ; retry++ {
+		list, next, err := c.client.QueryWithDeleted(m.Object).Limit(MigrationPageSize).Paginate(token, false).ReturnPage(ctx)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		changed := []Object{}
+		for _, obj := range list {
+			ok, err := m.Migrate(ctx, obj)
+			if err != nil {
+				return 0, 0, "", errors.Wrapf(err, "migrate object %v", obj.ID())
+			}
+			if ok {
+				changed = append(changed, obj)
+			}
+		}
+		if dryRun {
+			return len(list), len(changed), next, nil
+		}
+
+		err = c.savePage(ctx, m, record, changed, written, func() {
+			record.Token = next
+			record.Scanned += len(list)
+			record.Changed += len(written)
+			record.Done = next == ""
+		})
+		if errors.Is(err, ErrConflict) && retry < migrationConflictRetry {
+			continue
+		}
+		if err != nil {
+			return 0, 0, "", errors.Wrap(err, "save page")
+		}
+		return len(list), len(written), next, nil
+	}
+}
+
+// savePage save changed object and migration progress. versioned object is saved one by one with version check then progress is saved, others are saved in one batch with progress
+//
+func (c *Migrator) savePage(ctx context.Context, m *Migration, record *MigrationRecord, changed []Object, written map[string]bool, progress func()) error {
+	if !m.Object.Versioned() {
+		for _, obj := range changed {
+			written[obj.ID()] = true
+		}
+		progress()
+		return c.client.Batch(ctx, func(ctx context.Context, bc Batch) error {
+			for _, obj := range changed {
+				bc.Set(ctx, obj)
+			}
+			bc.Set(ctx, record)
+			return nil
+		})
+	}
+
+	for _, obj := range changed {
+		if err := c.client.Set(ctx, obj); err != nil {
+			return errors.Wrapf(err, "set %v", obj.ID())
+		}
+		written[obj.ID()] = true
+	}
+	progress()
+	return c.client.Set(ctx, record)
+}
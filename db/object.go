@@ -1,6 +1,11 @@
 package db
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 // Object is any defined object in a database that is used to store or reference data
 //
@@ -89,6 +94,122 @@ type Object interface {
 	//	sample.SetUserID(userID)
 	//
 	SetUserID(userID string)
+
+	// SoftDelete return true if object use soft delete, Delete() only set object delete time and deleted object will not return by Get/List/Query, use Restore() to undelete and Purge() to remove permanently.
+	// Query.Delete(), Query.Cleanup() and Batch.DeleteRef() always delete permanently.
+	// object saved before soft delete enabled has no delete time field and is excluded by Query, run BackfillDeleteTime() once when enable soft delete on existing collection.
+	// query filter on delete time, so query use OrderBy() or inequality filter need composite index include DeleteTime
+	//
+	//	func (c *Sample) SoftDelete() bool {
+	//		return true
+	//	}
+	//
+	SoftDelete() bool
+
+	// DeleteTime return object delete time, zero mean object is not deleted
+	//
+	//	t := sample.DeleteTime()
+	//
+	DeleteTime() time.Time
+
+	// SetDeleteTime set object delete time
+	//
+	//	sample.SetDeleteTime(time.Now().UTC())
+	//
+	SetDeleteTime(t time.Time)
+
+	// Versioned return true if object use optimistic concurrency, Client.Set/Update and Transaction.Set/Update will check stored version equal to object version then increment it, return ErrConflict if stored version has moved. batch will not check version
+	//
+	//	func (c *Sample) Versioned() bool {
+	//		return true
+	//	}
+	//
+	Versioned() bool
+
+	// Version return object version, it is 0 for object never been saved
+	//
+	//	v := sample.Version()
+	//
+	Version() int64
+
+	// SetVersion set object version
+	//
+	//	sample.SetVersion(2)
+	//
+	SetVersion(v int64)
+}
+
+// DeleteTimeField is field name of object delete time
+//
+const DeleteTimeField = "DeleteTime"
+
+// VersionField is field name of object version
+//
+const VersionField = "Version"
+
+// ErrConflict return when save versioned object but stored version has moved, it mean object has been changed by others since it was read
+//
+//	if errors.Is(err, db.ErrConflict) {
+//		// reload object and try again
+//	}
+//
+var ErrConflict = errors.New("version conflict")
+
+// ExcludeDeleted add filter to query to exclude soft deleted object, return query unchanged if object not use soft delete.
+// filter is DeleteTime == zero time, object without DeleteTime field is excluded too, see BackfillDeleteTime()
+//
+//	query = ExcludeDeleted(query, obj)
+//
+func ExcludeDeleted(query Query, obj Object) Query {
+	if obj != nil && obj.SoftDelete() {
+		return query.Where(DeleteTimeField, "==", time.Time{})
+	}
+	return query
+}
+
+// BackfillDeleteTime write zero delete time to object which is not deleted, so object saved before soft delete enabled will not be excluded by query. it rewrite every object not deleted, run it once after enable soft delete on existing collection. return number of object updated
+//
+//	count, err := BackfillDeleteTime(ctx, client, &Sample{})
+//
+func BackfillDeleteTime(ctx context.Context, client Client, obj Object) (int, error) {
+	if !obj.SoftDelete() {
+		return 0, errors.New(obj.Collection() + " not use soft delete")
+	}
+	iter := client.QueryWithDeleted(obj).Iterate(ctx, MigrationPageSize)
+	defer iter.Close()
+	count := 0
+	page := []Object{}
+	save := func() error {
+		if len(page) == 0 {
+			return nil
+		}
+		err := client.Batch(ctx, func(ctx context.Context, bc Batch) error {
+			for _, o := range page {
+				bc.Update(ctx, o, map[string]interface{}{DeleteTimeField: time.Time{}})
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "backfill delete time")
+		}
+		count += len(page)
+		page = page[:0]
+		return nil
+	}
+	for iter.Next() {
+		if o := iter.Object(); o.DeleteTime().IsZero() {
+			page = append(page, o)
+		}
+		if len(page) >= MigrationPageSize {
+			if err := save(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return count, err
+	}
+	return count, save()
 }
 
 // Entity a class that has id
@@ -108,6 +229,15 @@ type Entity struct {
 	// We keep our own create time, cause database provide create time like "snapshot.CreateTime" may not use in query
 	//
 	Createtime time.Time `firestore:"CreateTime"`
+
+	// Deletetime is object delete time when object use soft delete, you should use DeleteTime() SetDeleteTime() to access this field
+	// it is only saved by object use soft delete so query can exclude deleted object, document without this field will not return by query when soft delete enabled
+	//
+	Deletetime *time.Time `firestore:"DeleteTime,omitempty"`
+
+	// Versionnum is object version when object use optimistic concurrency, you should use Version() SetVersion() to access this field
+	//
+	Versionnum int64 `firestore:"Version,omitempty"`
 }
 
 // ID return object unique identifier
@@ -162,6 +292,57 @@ func (c *Entity) SetCreateTime(value time.Time) {
 	}
 }
 
+// SoftDelete return false, override it to return true to enable soft delete
+//
+//	softDelete := d.SoftDelete()
+//
+func (c *Entity) SoftDelete() bool {
+	return false
+}
+
+// DeleteTime return object delete time, zero mean object is not deleted
+//
+//	t := d.DeleteTime()
+//
+func (c *Entity) DeleteTime() time.Time {
+	if c.Deletetime == nil {
+		return time.Time{}
+	}
+	return *c.Deletetime
+}
+
+// SetDeleteTime set object delete time
+//
+//	d.SetDeleteTime(time.Now().UTC())
+//
+func (c *Entity) SetDeleteTime(t time.Time) {
+	c.Deletetime = &t
+}
+
+// Versioned return false, override it to return true to enable optimistic concurrency
+//
+//	versioned := d.Versioned()
+//
+func (c *Entity) Versioned() bool {
+	return false
+}
+
+// Version return object version, it is 0 for object never been saved
+//
+//	v := d.Version()
+//
+func (c *Entity) Version() int64 {
+	return c.Versionnum
+}
+
+// SetVersion set object version
+//
+//	d.SetVersion(2)
+//
+func (c *Entity) SetVersion(v int64) {
+	c.Versionnum = v
+}
+
 // UserID return owner's user id
 //
 func (c *Entity) UserID() string {
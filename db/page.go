@@ -0,0 +1,179 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/piyuo/libsrv/crypto"
+	"github.com/pkg/errors"
+)
+
+// plainTokenPrefix is prefix of page token not encrypted
+//
+const plainTokenPrefix = "p"
+
+// encryptedTokenPrefix is prefix of page token encrypted by crypto package
+//
+const encryptedTokenPrefix = "e"
+
+// pageToken is position of last object in page, query resume after it
+//
+type pageToken struct {
+
+	// Values is last object values on query order fields
+	//
+	Values []pageValue `json:"v"`
+
+	// ID is last object id
+	//
+	ID string `json:"i"`
+}
+
+// pageValue is typed value in page token, type is keep so value can compare with field in database
+//
+type pageValue struct {
+
+	// Type is value type, n=nil b=bool i=int f=float s=string t=time y=bytes
+	//
+	Type string `json:"t"`
+
+	// Value is value in string
+	//
+	Value string `json:"v,omitempty"`
+}
+
+// EncodePageToken encode order field values and id of last object in page into url-safe token, token is encrypted using crypto package if encrypt is true
+//
+//	token, err := EncodePageToken([]interface{}{"b city"}, "id", false)
+//
+func EncodePageToken(values []interface{}, id string, encrypt bool) (string, error) {
+	if err := AssertID(id); err != nil {
+		return "", errors.Wrap(err, "page token")
+	}
+	token := pageToken{ID: id, Values: []pageValue{}}
+	for _, value := range values {
+		v, err := toPageValue(value)
+		if err != nil {
+			return "", err
+		}
+		token.Values = append(token.Values, v)
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal page token")
+	}
+	if !encrypt {
+		return plainTokenPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+	}
+
+	crypted, err := crypto.Encrypt(string(data))
+	if err != nil {
+		return "", errors.Wrap(err, "encrypt page token")
+	}
+	return encryptedTokenPrefix + crypto.URLEncode(crypted), nil
+}
+
+// DecodePageToken decode token return by EncodePageToken, return order field values and id of last object in page. encrypt must be the same as encode
+//
+//	values, id, err := DecodePageToken(token, false)
+//
+func DecodePageToken(token string, encrypt bool) ([]interface{}, string, error) {
+	prefix := plainTokenPrefix
+	if encrypt {
+		prefix = encryptedTokenPrefix
+	}
+	if !strings.HasPrefix(token, prefix) {
+		return nil, "", errors.New("invalid page token")
+	}
+	var data []byte
+	if encrypt {
+		text, err := crypto.Decrypt(crypto.URLDecode(token[len(prefix):]))
+		if err != nil {
+			return nil, "", errors.Wrap(err, "decrypt page token")
+		}
+		data = []byte(text)
+	} else {
+		raw, err := base64.RawURLEncoding.DecodeString(token[len(prefix):])
+		if err != nil {
+			return nil, "", errors.Wrap(err, "decode page token")
+		}
+		data = raw
+	}
+
+	var t pageToken
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, "", errors.Wrap(err, "unmarshal page token")
+	}
+	if err := AssertID(t.ID); err != nil {
+		return nil, "", errors.Wrap(err, "page token")
+	}
+	values := []interface{}{}
+	for _, v := range t.Values {
+		value, err := v.value()
+		if err != nil {
+			return nil, "", err
+		}
+		values = append(values, value)
+	}
+	return values, t.ID, nil
+}
+
+// toPageValue convert field value to page value, only nil, bool, number, string, time and bytes can be used in page token
+//
+func toPageValue(value interface{}) (pageValue, error) {
+	switch v := value.(type) {
+	case nil:
+		return pageValue{Type: "n"}, nil
+	case bool:
+		return pageValue{Type: "b", Value: strconv.FormatBool(v)}, nil
+	case int:
+		return pageValue{Type: "i", Value: strconv.FormatInt(int64(v), 10)}, nil
+	case int32:
+		return pageValue{Type: "i", Value: strconv.FormatInt(int64(v), 10)}, nil
+	case int64:
+		return pageValue{Type: "i", Value: strconv.FormatInt(v, 10)}, nil
+	case float32:
+		return pageValue{Type: "f", Value: strconv.FormatFloat(float64(v), 'g', -1, 64)}, nil
+	case float64:
+		return pageValue{Type: "f", Value: strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	case string:
+		return pageValue{Type: "s", Value: v}, nil
+	case time.Time:
+		return pageValue{Type: "t", Value: v.UTC().Format(time.RFC3339Nano)}, nil
+	case []byte:
+		return pageValue{Type: "y", Value: base64.RawURLEncoding.EncodeToString(v)}, nil
+	}
+	return pageValue{}, errors.Errorf("page token not support order field value %T", value)
+}
+
+// value return field value
+//
+func (c pageValue) value() (interface{}, error) {
+	var value interface{}
+	var err error
+	switch c.Type {
+	case "n":
+		return nil, nil
+	case "b":
+		value, err = strconv.ParseBool(c.Value)
+	case "i":
+		value, err = strconv.ParseInt(c.Value, 10, 64)
+	case "f":
+		value, err = strconv.ParseFloat(c.Value, 64)
+	case "s":
+		return c.Value, nil
+	case "t":
+		value, err = time.Parse(time.RFC3339Nano, c.Value)
+	case "y":
+		value, err = base64.RawURLEncoding.DecodeString(c.Value)
+	default:
+		return nil, errors.Errorf("invalid page token value type %q", c.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse page token value %q", c.Value)
+	}
+	return value, nil
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageToken(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	now := time.Now().UTC()
+	values := []interface{}{nil, true, int64(-3), 1.5, "a/b+c", now, []byte{1, 2}}
+
+	token, err := EncodePageToken(values, "id1", false)
+	assert.Nil(err)
+	result, id, err := DecodePageToken(token, false)
+	assert.Nil(err)
+	assert.Equal("id1", id)
+	assert.Equal(values, result)
+
+	// int become int64
+	token, err = EncodePageToken([]interface{}{1}, "id1", false)
+	assert.Nil(err)
+	result, _, err = DecodePageToken(token, false)
+	assert.Nil(err)
+	assert.Equal([]interface{}{int64(1)}, result)
+
+	// unsupported value
+	_, err = EncodePageToken([]interface{}{map[string]interface{}{}}, "id1", false)
+	assert.NotNil(err)
+
+	// id must not empty
+	_, err = EncodePageToken(values, "", false)
+	assert.NotNil(err)
+
+	// invalid token
+	_, _, err = DecodePageToken("", false)
+	assert.NotNil(err)
+	_, _, err = DecodePageToken(token, true)
+	assert.NotNil(err)
+	_, _, err = DecodePageToken(token+"!", false)
+	assert.NotNil(err)
+}
@@ -26,6 +26,15 @@ type Query interface {
 	//
 	Where(path, op string, value interface{}) Query
 
+	// WhereFilter set composite filter, it combine with other filter using and. or filter is run as one query per and-group and result is merged, so it can not use with OrderBy() and cursor
+	//
+	//	list, err := Query(&Sample{}).WhereFilter(db.Or(
+	//		db.Field("Tag", "in", []string{"a", "b"}),
+	//		db.Field("Array", "array-contains", "x"),
+	//	)).Return(ctx)
+	//
+	WhereFilter(filter *Filter) Query
+
 	// OrderBy set query order by asc
 	//
 	//	list, err = Query(&Sample{}).OrderBy("Name").Execute(ctx)
@@ -69,13 +78,19 @@ type Query interface {
 	//
 	EndBefore(docSnapshotOrFieldValues ...interface{}) Query
 
-	// Delete delete all document return from query. delete max doc count. return is done,delete count, error
+	// Paginate resume query after page token return by ReturnPage(), empty token mean first page. token is encrypted using crypto package if encrypt is true, use the same encrypt on every page
+	//
+	//	list, next, err := Query(&Sample{}).OrderBy("Name").Paginate(token, true).ReturnPage(ctx)
+	//
+	Paginate(token string, encrypt bool) Query
+
+	// Delete permanently delete all document return from query even object use soft delete. delete max doc count. return is done,delete count, error
 	//
 	//	done, count, err := client.Query(&Sample{}).Where("Name", "==", name).Delete(ctx, 100)
 	//
 	Delete(ctx context.Context, max int) (bool, int, error)
 
-	// Cleanup delete 25 document a time, max 1000 object. return true if no object left in collection
+	// Cleanup permanently delete 25 document a time even object use soft delete, max 1000 object. return true if no object left in collection
 	//
 	//	done,  err := client.Query(&Sample{}).Where("Name", "==", name).Cleanup(ctx)
 	//
@@ -93,12 +108,30 @@ type Query interface {
 	//
 	ReturnID(ctx context.Context) ([]string, error)
 
-	// ReturnCount return object count with default limit to 20 object, use Limit() to override default limit
+	// ReturnCount return object count with default limit to 20 object, use Limit() to override default limit, use Count() to get exact count
 	//
 	//	count, err := Query(&Sample{}).Where("Name", "==", "sample1").ReturnCount(ctx)
 	//
 	ReturnCount(ctx context.Context) (int, error)
 
+	// Count return exact count of object match query, Limit() is ignored and object content will not be read, it can be used in transaction
+	//
+	//	count, err := Query(&Sample{}).Where("Name", "==", "sample1").Count(ctx)
+	//
+	Count(ctx context.Context) (int, error)
+
+	// Sum return sum of numeric field on all object match query, Limit() is ignored, non-numeric value is ignored, it can be used in transaction
+	//
+	//	total, err := Query(&Sample{}).Where("Tag", "==", "a").Sum(ctx, "Value")
+	//
+	Sum(ctx context.Context, field string) (float64, error)
+
+	// Avg return average of numeric field on all object match query, Limit() is ignored, non-numeric value is ignored, return 0 if no numeric value, it can be used in transaction
+	//
+	//	avg, err := Query(&Sample{}).Where("Tag", "==", "a").Avg(ctx, "Value")
+	//
+	Avg(ctx context.Context, field string) (float64, error)
+
 	// ReturnEmpty return true if no object exist
 	//
 	//	isEmpty, err := Query(&Sample{}).Where("Name", "==", "sample1").ReturnEmpty(ctx)
@@ -123,6 +156,33 @@ type Query interface {
 	//	id, err := Query(&Sample{}).OrderBy("From").Limit(1).StartAt("b city").ReturnFirstID(ctx)
 	//
 	ReturnFirstID(ctx context.Context) (string, error)
+
+	// ReturnPage return one page of object with default limit to 20 object, use Limit() to set page size. return url-safe token to next page, token is empty if no more object. not support with or filter
+	//
+	//	list, next, err := Query(&Sample{}).OrderBy("Name").Paginate(token, false).ReturnPage(ctx)
+	//
+	ReturnPage(ctx context.Context) ([]Object, string, error)
+
+	// Iterate return iterator to walk through all object page by page, use it to read large collection in bounded memory. Limit() is ignored, pageSize is object count read from database at a time, not support in transaction
+	//
+	//	iter := Query(&Sample{}).OrderBy("Name").Iterate(ctx, 100)
+	//	defer iter.Close()
+	//	for iter.Next() {
+	//		sample := iter.Object().(*Sample)
+	//	}
+	//	err := iter.Err()
+	//
+	Iterate(ctx context.Context, pageSize int) Iterator
+
+	// Watch return watcher deliver change of all object match query, first change are all object currently match query. default limit is ignored, only limit set by Limit() is kept. not support in transaction and with or filter
+	//
+	//	watcher := Query(&Sample{}).Where("Tag", "==", "a").Watch(ctx)
+	//	defer watcher.Close()
+	//	for change := range watcher.Changes() {
+	//		sample := change.Object.(*Sample)
+	//	}
+	//
+	Watch(ctx context.Context) Watcher
 }
 
 // BaseQuery represent a query in document database
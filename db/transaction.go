@@ -42,13 +42,15 @@ type Transaction interface {
 	//
 	Query(obj Object) Query
 
-	// Set object into table, If the document not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data, if object does not have id, it will created using UUID
+	// Set object into table, If the document not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data, if object does not have id, it will created using UUID.
+	// versioned object need read stored version, so it must be set before any write in transaction, return ErrConflict if stored version has moved, object version is increment after transaction commit
 	//
 	//	 err := Set(ctx, object)
 	//
 	Set(ctx context.Context, obj Object) error
 
-	// Update partial object field, create new one if object does not exist, this function is significant slow than Set()
+	// Update partial object field, create new one if object does not exist, this function is significant slow than Set().
+	// versioned object need read stored version, so it must be update before any write in transaction, return ErrConflict if stored version has moved, object version is increment after transaction commit
 	//
 	//	err = Update(ctx, sample, map[string]interface{}{
 	//		"desc": "hi",
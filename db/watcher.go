@@ -0,0 +1,69 @@
+package db
+
+// ChangeType define how object changed
+//
+type ChangeType int
+
+const (
+	// ChangeAdded mean object added to result, all object in first result are added
+	//
+	ChangeAdded ChangeType = iota
+
+	// ChangeModified mean object in result has been modified
+	//
+	ChangeModified
+
+	// ChangeRemoved mean object removed from result, like object deleted or no longer match query
+	//
+	ChangeRemoved
+)
+
+// Change is object change deliver by watcher
+//
+type Change struct {
+
+	// Type is how object changed
+	//
+	Type ChangeType
+
+	// ID is changed object id
+	//
+	ID string
+
+	// Object is object after change, it is nil when object removed
+	//
+	Object Object
+}
+
+// Watcher deliver object change until context canceled or Close() been called, watcher automatically resume when connection to database is interrupted
+//
+//	watcher := Query(&Sample{}).Where("Tag", "==", "a").Watch(ctx)
+//	defer watcher.Close()
+//	for change := range watcher.Changes() {
+//		if change.Type == db.ChangeRemoved {
+//			...
+//		}
+//	}
+//	err := watcher.Err()
+//
+type Watcher interface {
+
+	// Changes return channel deliver object change, channel will be closed when watcher stop
+	//
+	//	for change := range watcher.Changes() {
+	//	}
+	//
+	Changes() <-chan *Change
+
+	// Err return error which stop watcher, return nil if watcher stop by Close() or context canceled. only valid after Changes() channel closed
+	//
+	//	err := watcher.Err()
+	//
+	Err() error
+
+	// Close stop watcher, it is safe to call Close() multiple times
+	//
+	//	defer watcher.Close()
+	//
+	Close()
+}
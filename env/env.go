@@ -34,6 +34,14 @@ const (
 	// KeyContextLocale used in i18n to mock locale
 	//
 	KeyContextLocale
+
+	// KeyContextCommand is context key name for command name
+	//
+	KeyContextCommand
+
+	// KeyContextTrace is context key name for trace id
+	//
+	KeyContextTrace
 )
 
 // Mock define key test flag
@@ -161,3 +169,23 @@ func GetAccountID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// SetCommand set command name into ctx, this may used in log
+//
+//	ctx = SetCommand(ctx,"CmdLogin")
+//
+func SetCommand(ctx context.Context, command string) context.Context {
+	return context.WithValue(ctx, KeyContextCommand, command)
+}
+
+// GetCommand return current command name from context
+//
+//	command := GetCommand(ctx)
+//
+func GetCommand(ctx context.Context) string {
+	iCommand := ctx.Value(KeyContextCommand)
+	if iCommand != nil {
+		return iCommand.(string)
+	}
+	return ""
+}
@@ -90,3 +90,14 @@ func TestRegion(t *testing.T) {
 	assert := assert.New(t)
 	assert.NotEmpty(Region)
 }
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	command := GetCommand(ctx)
+	assert.Empty(command)
+	ctx = SetCommand(ctx, "CmdLogin")
+	command = GetCommand(ctx)
+	assert.Equal("CmdLogin", command)
+}
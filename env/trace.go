@@ -0,0 +1,105 @@
+package env
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceHeader is http header google cloud use to send trace context, format is "TRACE_ID/SPAN_ID;o=TRACE_TRUE"
+//
+const TraceHeader = "X-Cloud-Trace-Context"
+
+// TraceParentHeader is w3c trace context header, format is "00-TRACE_ID-PARENT_ID-FLAGS"
+//
+const TraceParentHeader = "traceparent"
+
+// SetTrace set trace id into ctx, it is used to correlate log, error report and task of the same request
+//
+//	ctx = SetTrace(ctx, NewTrace())
+//
+func SetTrace(ctx context.Context, trace string) context.Context {
+	return context.WithValue(ctx, KeyContextTrace, trace)
+}
+
+// GetTrace return trace id from context, return trace id in request header if trace id not set, return empty if not found
+//
+//	trace := GetTrace(ctx)
+//
+func GetTrace(ctx context.Context) string {
+	if iTrace := ctx.Value(KeyContextTrace); iTrace != nil {
+		return iTrace.(string)
+	}
+	if req := GetRequest(ctx); req != nil {
+		return TraceFromRequest(req)
+	}
+	return ""
+}
+
+// NewTrace return new random trace id, it is 32 hex characters
+//
+//	trace := NewTrace() // "105445aa7843bc8bf206b12000100000"
+//
+func NewTrace() string {
+	return randomHex(16)
+}
+
+// IsTraceID return true if trace is 32 hex characters and not all zero
+//
+//	ok := IsTraceID("105445aa7843bc8bf206b12000100000") // true
+//
+func IsTraceID(trace string) bool {
+	if len(trace) != 32 || trace == strings.Repeat("0", 32) {
+		return false
+	}
+	_, err := hex.DecodeString(trace)
+	return err == nil
+}
+
+// TraceFromRequest return trace id from X-Cloud-Trace-Context or traceparent header, return empty if not found or trace id is invalid
+//
+//	trace := TraceFromRequest(r)
+//
+func TraceFromRequest(r *http.Request) string {
+	if header := r.Header.Get(TraceHeader); header != "" {
+		if i := strings.IndexAny(header, "/;"); i >= 0 {
+			header = header[:i]
+		}
+		if IsTraceID(header) {
+			return header
+		}
+	}
+	// version-trace_id-parent_id-flags
+	parts := strings.Split(r.Header.Get(TraceParentHeader), "-")
+	if len(parts) >= 4 && IsTraceID(parts[1]) {
+		return parts[1]
+	}
+	return ""
+}
+
+// TraceHeaders return headers to propagate trace id of ctx to other service, return nil if ctx has no valid trace id
+//
+//	for key, value := range TraceHeaders(ctx) {
+//		req.Header.Set(key, value)
+//	}
+//
+func TraceHeaders(ctx context.Context) map[string]string {
+	trace := GetTrace(ctx)
+	if !IsTraceID(trace) {
+		return nil
+	}
+	return map[string]string{
+		TraceHeader:       trace,
+		TraceParentHeader: "00-" + trace + "-" + randomHex(8) + "-01",
+	}
+}
+
+// randomHex return n random bytes in hex
+//
+func randomHex(n int) string {
+	bytes := make([]byte, n)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,70 @@
+package env
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceFromRequest(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	req, _ := http.NewRequest("GET", "/", nil)
+	assert.Empty(TraceFromRequest(req))
+
+	req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", TraceFromRequest(req))
+
+	// cloud trace context take precedence
+	req.Header.Set(TraceHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+	assert.Equal("105445aa7843bc8bf206b12000100000", TraceFromRequest(req))
+
+	// invalid traceparent
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.Header.Set(TraceParentHeader, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	assert.Empty(TraceFromRequest(req))
+	req.Header.Set(TraceParentHeader, "invalid")
+	assert.Empty(TraceFromRequest(req))
+
+	// invalid cloud trace id is not trusted
+	req.Header.Set(TraceParentHeader, "")
+	for _, header := range []string{"abc/1;o=1", "105445aa7843bc8bf206b1200010000z/1", strings.Repeat("0", 32), "105445aa7843bc8bf206b120001000001"} {
+		req.Header.Set(TraceHeader, header)
+		assert.Empty(TraceFromRequest(req), header)
+	}
+	req.Header.Set(TraceHeader, "abc")
+	req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", TraceFromRequest(req))
+}
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	assert.Empty(GetTrace(ctx))
+	assert.Nil(TraceHeaders(ctx))
+
+	// trace from request
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(TraceHeader, "105445aa7843bc8bf206b12000100000")
+	assert.Equal("105445aa7843bc8bf206b12000100000", GetTrace(SetRequest(ctx, req)))
+
+	trace := NewTrace()
+	assert.Len(trace, 32)
+	assert.NotEqual(trace, NewTrace())
+	ctx = SetTrace(ctx, trace)
+	assert.Equal(trace, GetTrace(ctx))
+
+	headers := TraceHeaders(ctx)
+	assert.Equal(trace, headers[TraceHeader])
+	req.Header.Set(TraceHeader, "")
+	req.Header.Set(TraceParentHeader, headers[TraceParentHeader])
+	assert.Equal(trace, TraceFromRequest(req))
+
+	// trace not in hex is not propagated as traceparent
+	// invalid trace id is not forwarded
+	assert.Nil(TraceHeaders(SetTrace(context.Background(), "abc")))
+}
@@ -0,0 +1,123 @@
+package firewall
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Algorithm decide whether a call is allowed, state is kept in store so limiter can be shared between instances
+//
+//	rule.Algorithm = &TokenBucket{Capacity: 10, Per: time.Minute}
+//
+type Algorithm interface {
+
+	// Allow return true if call at now is allowed, state is algorithm state from store, nil if not exist, return new state to save
+	//
+	Allow(state []byte, now time.Time) (bool, []byte)
+
+	// TTL return how long state need to keep in store
+	//
+	TTL() time.Duration
+}
+
+// TokenBucket allow burst up to capacity, token refill steadily so Capacity calls allowed in every Per
+//
+//	&TokenBucket{Capacity: 10, Per: time.Minute} // 10 calls per minute, burst up to 10
+//
+type TokenBucket struct {
+	Algorithm
+
+	// Capacity is max token in bucket, every call take one token
+	//
+	Capacity int
+
+	// Per is how long empty bucket refill to full
+	//
+	Per time.Duration
+}
+
+// Allow take one token from bucket, state is token count and last refill time
+//
+func (c *TokenBucket) Allow(state []byte, now time.Time) (bool, []byte) {
+	capacity := float64(c.Capacity)
+	tokens := capacity
+	if len(state) == 16 {
+		tokens = math.Float64frombits(binary.LittleEndian.Uint64(state[0:8]))
+		last := time.Unix(0, int64(binary.LittleEndian.Uint64(state[8:16])))
+		if elapsed := now.Sub(last); elapsed > 0 && c.Per > 0 {
+			tokens += capacity * float64(elapsed) / float64(c.Per)
+		}
+		if tokens > capacity {
+			tokens = capacity
+		}
+	}
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	newState := make([]byte, 16)
+	binary.LittleEndian.PutUint64(newState[0:8], math.Float64bits(tokens))
+	binary.LittleEndian.PutUint64(newState[8:16], uint64(now.UnixNano()))
+	return allowed, newState
+}
+
+// TTL is time to refill bucket, state expired mean bucket is full
+//
+func (c *TokenBucket) TTL() time.Duration {
+	return c.Per
+}
+
+// SlidingWindow allow Limit calls in any Window, count is estimated from current and previous fixed window so state size is constant
+//
+//	&SlidingWindow{Limit: 100, Window: time.Hour} // 100 calls per hour
+//
+type SlidingWindow struct {
+	Algorithm
+
+	// Limit is max calls in window
+	//
+	Limit int
+
+	// Window is length of window
+	//
+	Window time.Duration
+}
+
+// Allow count call in window, state is current window start, current window count and previous window count
+//
+func (c *SlidingWindow) Allow(state []byte, now time.Time) (bool, []byte) {
+	if c.Window <= 0 {
+		return true, state
+	}
+	start := now.Truncate(c.Window)
+	var current, previous int64
+	if len(state) == 24 {
+		stateStart := time.Unix(0, int64(binary.LittleEndian.Uint64(state[0:8])))
+		stateCurrent := int64(binary.LittleEndian.Uint64(state[8:16]))
+		switch {
+		case stateStart.Equal(start):
+			current = stateCurrent
+			previous = int64(binary.LittleEndian.Uint64(state[16:24]))
+		case stateStart.Equal(start.Add(-c.Window)):
+			previous = stateCurrent
+		}
+	}
+	weight := 1 - float64(now.Sub(start))/float64(c.Window)
+	estimated := float64(previous)*weight + float64(current)
+	allowed := estimated < float64(c.Limit)
+	if allowed {
+		current++
+	}
+	newState := make([]byte, 24)
+	binary.LittleEndian.PutUint64(newState[0:8], uint64(start.UnixNano()))
+	binary.LittleEndian.PutUint64(newState[8:16], uint64(current))
+	binary.LittleEndian.PutUint64(newState[16:24], uint64(previous))
+	return allowed, newState
+}
+
+// TTL is two window, previous window is needed to estimate count
+//
+func (c *SlidingWindow) TTL() time.Duration {
+	return 2 * c.Window
+}
@@ -0,0 +1,68 @@
+package firewall
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	bucket := &TokenBucket{Capacity: 2, Per: time.Minute}
+	now := time.Now()
+	allowed, state := bucket.Allow(nil, now)
+	assert.True(allowed)
+	allowed, state = bucket.Allow(state, now)
+	assert.True(allowed)
+	allowed, state = bucket.Allow(state, now)
+	assert.False(allowed)
+
+	// one token refill in 30 seconds
+	allowed, state = bucket.Allow(state, now.Add(30*time.Second))
+	assert.True(allowed)
+	allowed, state = bucket.Allow(state, now.Add(30*time.Second))
+	assert.False(allowed)
+
+	// bucket never exceed capacity
+	later := now.Add(time.Hour)
+	allowed, state = bucket.Allow(state, later)
+	assert.True(allowed)
+	allowed, state = bucket.Allow(state, later)
+	assert.True(allowed)
+	allowed, _ = bucket.Allow(state, later)
+	assert.False(allowed)
+	assert.Equal(time.Minute, bucket.TTL())
+}
+
+func TestSlidingWindow(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	window := &SlidingWindow{Limit: 2, Window: time.Minute}
+	start := time.Now().Truncate(time.Minute)
+	allowed, state := window.Allow(nil, start)
+	assert.True(allowed)
+	allowed, state = window.Allow(state, start.Add(time.Second))
+	assert.True(allowed)
+	allowed, state = window.Allow(state, start.Add(2*time.Second))
+	assert.False(allowed)
+
+	// previous window still count at start of next window
+	allowed, state = window.Allow(state, start.Add(time.Minute))
+	assert.False(allowed)
+
+	// previous window weight half at middle of next window
+	allowed, state = window.Allow(state, start.Add(90*time.Second))
+	assert.True(allowed)
+	allowed, state = window.Allow(state, start.Add(90*time.Second))
+	assert.False(allowed)
+
+	// state older than previous window is ignored
+	later := start.Add(time.Hour)
+	allowed, state = window.Allow(state, later)
+	assert.True(allowed)
+	allowed, _ = window.Allow(state, later)
+	assert.True(allowed)
+	assert.Equal(2*time.Minute, window.TTL())
+}
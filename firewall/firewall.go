@@ -1,4 +1,6 @@
-package file
+package firewall
+
+import "time"
 
 const (
 	// BlockShort block short period of time, usually 5 minutes
@@ -13,3 +15,11 @@ const (
 	// Block second call, this happen when same call happen twice in 10 seconds
 	BlockHighFrequency = "BLOCK_HIGH_FREQ"
 )
+
+// BlockShortDuration is how long BlockShort block
+//
+const BlockShortDuration = 5 * time.Minute
+
+// BlockLongDuration is how long BlockLong block
+//
+const BlockLongDuration = time.Hour
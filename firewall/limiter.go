@@ -0,0 +1,213 @@
+package firewall
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/piyuo/libsrv/command"
+	"github.com/piyuo/libsrv/env"
+	"github.com/pkg/errors"
+)
+
+// Scope define what rate limit is counted by, combine scope to count on both like ScopeIP|ScopeUser
+//
+type Scope int
+
+const (
+	// ScopeIP count call by client ip
+	ScopeIP Scope = 1 << iota
+
+	// ScopeUser count call by user id
+	ScopeUser
+)
+
+// Store keep rate limit state, use MemoryStore for single instance and DBStore to share state between instances
+//
+type Store interface {
+
+	// Update read state of key and call f to get new state, state expire after ttl. update must be atomic, f may be called more than once
+	//
+	//	err := store.Update(ctx, "key", time.Minute, func(state []byte) ([]byte, error) {
+	//		return state, nil
+	//	})
+	//
+	Update(ctx context.Context, key string, ttl time.Duration, f func(state []byte) ([]byte, error)) error
+}
+
+// Rule is rate limit of action
+//
+//	rule := &Rule{
+//		Action:    "login",
+//		Scope:     ScopeIP,
+//		Algorithm: &SlidingWindow{Limit: 10, Window: time.Minute},
+//		Block:     BlockLong,
+//	}
+//
+type Rule struct {
+
+	// Action is action name, like command map name
+	//
+	Action string
+
+	// Scope is what call is counted by, call without ip or user id of scope is not limited
+	//
+	Scope Scope
+
+	// Algorithm decide whether call is allowed
+	//
+	Algorithm Algorithm
+
+	// Block is BlockShort or BlockLong, caller exceed limit will be blocked for BlockShortDuration or BlockLongDuration, default is BlockShort
+	//
+	Block string
+}
+
+// blockDuration return how long caller exceed limit is blocked
+//
+func (c *Rule) blockDuration() time.Duration {
+	if c.Block == BlockLong {
+		return BlockLongDuration
+	}
+	return BlockShortDuration
+}
+
+// blockResponse return command response of block
+//
+func (c *Rule) blockResponse() interface{} {
+	if c.Block == BlockLong {
+		return command.BlockLong
+	}
+	return command.BlockShort
+}
+
+// key return store key of call, return empty if call can not be identified
+//
+func (c *Rule) key(ctx context.Context) string {
+	key := "RL-" + c.Action
+	if c.Scope&ScopeIP != 0 {
+		ip := env.GetIP(ctx)
+		if ip == "" {
+			return ""
+		}
+		key += "-" + ip
+	}
+	if c.Scope&ScopeUser != 0 {
+		userID := env.GetUserID(ctx)
+		if userID == "" {
+			return ""
+		}
+		key += "-" + userID
+	}
+	return key
+}
+
+// Limiter enforce rate limit rule, caller exceed limit get command.BlockShort or command.BlockLong
+//
+//	limiter := NewLimiter(NewMemoryStore(), &Rule{
+//		Action:    "login",
+//		Scope:     ScopeIP,
+//		Algorithm: &TokenBucket{Capacity: 5, Per: time.Minute},
+//	})
+//	server := &server.Server{Limiter: limiter} // check every command by command map name
+//
+type Limiter struct {
+
+	// store keep rate limit state
+	//
+	store Store
+
+	// rules is rules by action name
+	//
+	rules map[string][]*Rule
+}
+
+// NewLimiter create limiter enforce rules, state is kept in store
+//
+//	limiter := NewLimiter(NewDBStore(client), rules...)
+//
+func NewLimiter(store Store, rules ...*Rule) *Limiter {
+	limiter := &Limiter{
+		store: store,
+		rules: map[string][]*Rule{},
+	}
+	for _, rule := range rules {
+		limiter.rules[rule.Action] = append(limiter.rules[rule.Action], rule)
+	}
+	return limiter
+}
+
+// Check count call of action, return nil if call is allowed or block response if any rule of action is exceeded. action without rule is always allowed
+//
+//	response, err := limiter.Check(ctx, "login")
+//	if response != nil {
+//		return response, nil // command.BlockShort or command.BlockLong
+//	}
+//
+func (c *Limiter) Check(ctx context.Context, action string) (interface{}, error) {
+	for _, rule := range c.rules[action] {
+		allowed, err := c.allow(ctx, rule)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return rule.blockResponse(), nil
+		}
+	}
+	return nil, nil
+}
+
+// allow count call in rule, return false if caller is blocked or exceed limit
+//
+func (c *Limiter) allow(ctx context.Context, rule *Rule) (bool, error) {
+	key := rule.key(ctx)
+	if key == "" || rule.Algorithm == nil {
+		return true, nil
+	}
+	block := rule.blockDuration()
+	ttl := rule.Algorithm.TTL()
+	if ttl < block {
+		ttl = block
+	}
+	var allowed bool
+	err := c.store.Update(ctx, key, ttl, func(state []byte) ([]byte, error) {
+		now := time.Now()
+		blocked, algorithmState := decodeState(state)
+		if now.Before(blocked) {
+			allowed = false
+			return state, nil
+		}
+		allowed, algorithmState = rule.Algorithm.Allow(algorithmState, now)
+		if !allowed {
+			blocked = now.Add(block)
+		}
+		return encodeState(blocked, algorithmState), nil
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "update rate limit "+key)
+	}
+	return allowed, nil
+}
+
+// decodeState decode block time and algorithm state
+//
+func decodeState(state []byte) (time.Time, []byte) {
+	if len(state) < 8 {
+		return time.Time{}, nil
+	}
+	nano := int64(binary.LittleEndian.Uint64(state[0:8]))
+	if nano == 0 {
+		return time.Time{}, state[8:]
+	}
+	return time.Unix(0, nano), state[8:]
+}
+
+// encodeState encode block time and algorithm state
+//
+func encodeState(blocked time.Time, algorithmState []byte) []byte {
+	state := make([]byte, 8, 8+len(algorithmState))
+	if !blocked.IsZero() {
+		binary.LittleEndian.PutUint64(state, uint64(blocked.UnixNano()))
+	}
+	return append(state, algorithmState...)
+}
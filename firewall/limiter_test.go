@@ -0,0 +1,157 @@
+package firewall
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/command"
+	"github.com/piyuo/libsrv/command/mock"
+	"github.com/piyuo/libsrv/env"
+	"github.com/piyuo/libsrv/identifier"
+	"github.com/piyuo/libsrv/mdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLimiter test limiter block caller exceed limit
+//
+func testLimiter(t *testing.T, store Store) {
+	assert := assert.New(t)
+	action := "login-" + identifier.UUID()
+	limiter := NewLimiter(store, &Rule{
+		Action:    action,
+		Scope:     ScopeIP,
+		Algorithm: &TokenBucket{Capacity: 2, Per: time.Hour},
+	}, &Rule{
+		Action:    action,
+		Scope:     ScopeUser,
+		Algorithm: &SlidingWindow{Limit: 3, Window: time.Hour},
+		Block:     BlockLong,
+	})
+	ctx := context.WithValue(context.Background(), env.MockIP, "")
+	userCtx := env.SetUserID(context.Background(), "user1")
+
+	for i := 0; i < 2; i++ {
+		response, err := limiter.Check(ctx, action)
+		assert.Nil(err)
+		assert.Nil(response)
+	}
+	response, err := limiter.Check(ctx, action)
+	assert.Nil(err)
+	assert.True(command.IsBlockShort(response))
+
+	// user rule count on user id
+	for i := 0; i < 3; i++ {
+		response, err := limiter.Check(userCtx, action)
+		assert.Nil(err)
+		assert.Nil(response)
+	}
+	response, err = limiter.Check(userCtx, action)
+	assert.Nil(err)
+	assert.True(command.IsBlockLong(response))
+
+	// action without rule is allowed
+	response, err = limiter.Check(ctx, "no-rule")
+	assert.Nil(err)
+	assert.Nil(response)
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	t.Parallel()
+	testLimiter(t, NewMemoryStore())
+}
+
+func TestDBLimiter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client, err := mdb.NewClient(ctx)
+	assert.Nil(err)
+	store := NewDBStore(client)
+	testLimiter(t, store)
+
+	done, err := store.Cleanup(ctx, 100)
+	assert.Nil(err)
+	assert.True(done)
+	count, err := client.Query(&Limit{}).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(2, count)
+}
+
+func TestLimiterBlock(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	store := NewMemoryStore()
+	rule := &Rule{
+		Action:    "block",
+		Scope:     ScopeIP,
+		Algorithm: &TokenBucket{Capacity: 1, Per: time.Millisecond},
+	}
+	limiter := NewLimiter(store, rule)
+	ctx := context.WithValue(context.Background(), env.MockIP, "")
+	response, _ := limiter.Check(ctx, "block")
+	assert.Nil(response)
+	response, _ = limiter.Check(ctx, "block")
+	assert.True(command.IsBlockShort(response))
+
+	// still blocked after bucket refill
+	time.Sleep(10 * time.Millisecond)
+	response, _ = limiter.Check(ctx, "block")
+	assert.True(command.IsBlockShort(response))
+
+	// block end
+	store.Update(ctx, rule.key(ctx), time.Minute, func(state []byte) ([]byte, error) {
+		_, algorithmState := decodeState(state)
+		return encodeState(time.Time{}, algorithmState), nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	response, _ = limiter.Check(ctx, "block")
+	assert.Nil(response)
+
+	// call can not be identified is not limited
+	response, err := limiter.Check(context.Background(), "block")
+	assert.Nil(err)
+	assert.Nil(response)
+}
+
+func TestMemoryStoreExpired(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	store := NewMemoryStore()
+	ctx := context.Background()
+	store.Update(ctx, "a", time.Millisecond, func(state []byte) ([]byte, error) {
+		return []byte("1"), nil
+	})
+	time.Sleep(5 * time.Millisecond)
+	store.Update(ctx, "a", time.Minute, func(state []byte) ([]byte, error) {
+		assert.Nil(state)
+		return state, nil
+	})
+}
+
+func TestLimiterDispatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := env.SetUserID(context.Background(), "user-"+identifier.UUID())
+	act := &mock.CmdRespond{Text: "Hi"}
+	dispatch := &command.Dispatch{
+		Map: &mock.MapXXX{},
+		Limiter: NewLimiter(NewMemoryStore(), &Rule{
+			Action:    act.XXX_MapName(),
+			Scope:     ScopeUser,
+			Algorithm: &TokenBucket{Capacity: 1, Per: time.Hour},
+			Block:     BlockLong,
+		}),
+	}
+	actBytes, err := dispatch.EncodeCommand(act.XXX_MapID(), act)
+	assert.Nil(err)
+	blockBytes, err := dispatch.EncodeCommand(command.BlockLong.XXX_MapID(), command.BlockLong)
+	assert.Nil(err)
+
+	resultBytes, err := dispatch.Route(ctx, actBytes)
+	assert.Nil(err)
+	assert.NotEqual(blockBytes, resultBytes)
+	resultBytes, err = dispatch.Route(ctx, actBytes)
+	assert.Nil(err)
+	assert.Equal(blockBytes, resultBytes)
+}
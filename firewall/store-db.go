@@ -0,0 +1,98 @@
+package firewall
+
+import (
+	"context"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+)
+
+// LimitCollection is collection name of rate limit state
+//
+const LimitCollection = "RateLimit"
+
+// Limit is rate limit state stored in database, id is rate limit key
+//
+type Limit struct {
+	db.Entity
+
+	// State is limiter state
+	//
+	State []byte `firestore:"State"`
+
+	// Until is time state no longer needed
+	//
+	Until time.Time `firestore:"Until"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *Limit) Factory() db.Object {
+	return &Limit{}
+}
+
+// Collection return rate limit collection name
+//
+func (c *Limit) Collection() string {
+	return LimitCollection
+}
+
+// DBStore keep rate limit state in database, state is shared between instances
+//
+type DBStore struct {
+	Store
+
+	// client is db client to store state
+	//
+	client db.Client
+}
+
+// NewDBStore create store keep state in database
+//
+//	store := NewDBStore(client)
+//
+func NewDBStore(client db.Client) *DBStore {
+	return &DBStore{
+		client: client,
+	}
+}
+
+// Update read state of key and call f to get new state in transaction, state expire after ttl
+//
+//	err := store.Update(ctx, "key", time.Minute, func(state []byte) ([]byte, error) {
+//		return state, nil
+//	})
+//
+func (c *DBStore) Update(ctx context.Context, key string, ttl time.Duration, f func(state []byte) ([]byte, error)) error {
+	return c.client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		now := time.Now().UTC()
+		var state []byte
+		obj, err := tx.Get(ctx, &Limit{}, key)
+		if err != nil {
+			return errors.Wrap(err, "get limit "+key)
+		}
+		if obj != nil && now.Before(obj.(*Limit).Until) {
+			state = obj.(*Limit).State
+		}
+		newState, err := f(state)
+		if err != nil {
+			return err
+		}
+		limit := &Limit{State: newState, Until: now.Add(ttl)}
+		limit.SetID(key)
+		return tx.Set(ctx, limit)
+	})
+}
+
+// Cleanup delete expired state, max is max state to delete, return true if all expired state deleted
+//
+//	done, err := store.Cleanup(ctx, 100)
+//
+func (c *DBStore) Cleanup(ctx context.Context, max int) (bool, error) {
+	done, _, err := c.client.Query(&Limit{}).Where("Until", "<", time.Now().UTC()).Delete(ctx, max)
+	if err != nil {
+		return false, errors.Wrap(err, "delete limit")
+	}
+	return done, nil
+}
@@ -0,0 +1,78 @@
+package firewall
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryCleanupCount is how many update before expired state is removed
+//
+const memoryCleanupCount = 1000
+
+// memoryState is state kept in memory
+//
+type memoryState struct {
+	state   []byte
+	expired time.Time
+}
+
+// MemoryStore keep rate limit state in memory, state is not shared between instances
+//
+type MemoryStore struct {
+	Store
+
+	// mutex protect states
+	//
+	mutex sync.Mutex
+
+	// states is state by key
+	//
+	states map[string]*memoryState
+
+	// updates is update count since last cleanup
+	//
+	updates int
+}
+
+// NewMemoryStore create memory store
+//
+//	store := NewMemoryStore()
+//
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: map[string]*memoryState{},
+	}
+}
+
+// Update read state of key and call f to get new state, state expire after ttl
+//
+//	err := store.Update(ctx, "key", time.Minute, func(state []byte) ([]byte, error) {
+//		return state, nil
+//	})
+//
+func (c *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, f func(state []byte) ([]byte, error)) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	c.updates++
+	if c.updates >= memoryCleanupCount {
+		c.updates = 0
+		for k, s := range c.states {
+			if !now.Before(s.expired) {
+				delete(c.states, k)
+			}
+		}
+	}
+
+	var state []byte
+	if s, found := c.states[key]; found && now.Before(s.expired) {
+		state = s.state
+	}
+	newState, err := f(state)
+	if err != nil {
+		return err
+	}
+	c.states[key] = &memoryState{state: newState, expired: now.Add(ttl)}
+	return nil
+}
@@ -2,12 +2,15 @@ package gdb
 
 import (
 	"context"
+	"reflect"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
 )
 
-// BatchFirestore implement firestore batch
+// BatchFirestore implement firestore batch, write operation will be applied when batch commit
 //
 type BatchFirestore struct {
 	db.Batch
@@ -16,24 +19,160 @@ type BatchFirestore struct {
 	//
 	client *ClientFirestore
 
-	// batch is curenet batch, it is nil if not in batch
+	// writes is write operation to apply when batch commit
 	//
-	batch *firestore.WriteBatch
+	writes []*batchWrite
 
 	// hasSomethingToCommit set to true when batch operation has been called like set/update/delete
 	//
 	hasSomethingToCommit bool
 }
 
+// batchWrite is write operation in batch
+//
+type batchWrite struct {
+
+	// ref is document to write
+	//
+	ref *firestore.DocumentRef
+
+	// data is data to set when write is set
+	//
+	data interface{}
+
+	// merge is true if data merge into document
+	//
+	merge bool
+
+	// updates is fields to update when write is update, document must exist
+	//
+	updates []firestore.Update
+
+	// delete is true if write is delete
+	//
+	delete bool
+
+	// softDelete is true if write is on object use soft delete, it need to know document exist so batch will commit in transaction.
+	// update is skipped if document not exist, merge add zero delete time if document not exist
+	//
+	softDelete bool
+}
+
+// snapshotData return shallow copy of object or fields, so change after batch operation will not be committed, the same as firestore batch encode data when called
+//
+func snapshotData(data interface{}) interface{} {
+	if fields, ok := data.(map[string]interface{}); ok {
+		result := make(map[string]interface{}, len(fields))
+		for key, value := range fields {
+			result[key] = value
+		}
+		return result
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return data
+	}
+	result := reflect.New(v.Elem().Type())
+	result.Elem().Set(v.Elem())
+	return result.Interface()
+}
+
+// add write operation to batch
+//
+func (c *BatchFirestore) add(w *batchWrite) {
+	c.writes = append(c.writes, w)
+	c.hasSomethingToCommit = true
+}
+
+// commit apply all write operation, batch commit in transaction if any write is on object use soft delete, because firestore batch can not read document
+//
+func (c *BatchFirestore) commit(ctx context.Context) error {
+	refs := []*firestore.DocumentRef{}
+	seen := map[string]bool{}
+	for _, w := range c.writes {
+		if w.softDelete && !seen[w.ref.Path] {
+			seen[w.ref.Path] = true
+			refs = append(refs, w.ref)
+		}
+	}
+	if len(refs) == 0 {
+		native := c.client.native.Batch()
+		for _, w := range c.writes {
+			w.toBatch(native)
+		}
+		_, err := native.Commit(ctx)
+		return err
+	}
+
+	return c.client.native.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snapshots, err := tx.GetAll(refs)
+		if err != nil {
+			return errors.Wrap(err, "read documents")
+		}
+		exists := map[string]bool{}
+		for _, snapshot := range snapshots {
+			exists[snapshot.Ref.Path] = snapshot.Exists()
+		}
+		for _, w := range c.writes {
+			if err := w.toTransaction(tx, exists); err != nil {
+				return errors.Wrapf(err, "write %v", w.ref.Path)
+			}
+		}
+		return nil
+	})
+}
+
+// toBatch add write to firestore batch
+//
+func (w *batchWrite) toBatch(batch *firestore.WriteBatch) {
+	switch {
+	case w.delete:
+		batch.Delete(w.ref)
+	case w.updates != nil:
+		batch.Update(w.ref, w.updates)
+	case w.merge:
+		batch.Set(w.ref, w.data, firestore.MergeAll)
+	default:
+		batch.Set(w.ref, w.data)
+	}
+}
+
+// toTransaction add write to firestore transaction, exists keep document exist state and it will be changed by write so later write in batch see it
+//
+func (w *batchWrite) toTransaction(tx *firestore.Transaction, exists map[string]bool) error {
+	path := w.ref.Path
+	switch {
+	case w.delete:
+		exists[path] = false
+		return tx.Delete(w.ref)
+	case w.updates != nil:
+		if w.softDelete && !exists[path] {
+			return nil
+		}
+		return tx.Update(w.ref, w.updates)
+	case w.merge:
+		data := w.data
+		if w.softDelete && !exists[path] {
+			created := map[string]interface{}{db.DeleteTimeField: time.Time{}}
+			for key, value := range w.data.(map[string]interface{}) {
+				created[key] = value
+			}
+			data = created
+		}
+		exists[path] = true
+		return tx.Set(w.ref, data, firestore.MergeAll)
+	}
+	exists[path] = true
+	return tx.Set(w.ref, w.data)
+}
+
 // Set object into table, If the document not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data, if object does not have id, it will created using UUID
 //
 //	 Set(ctx, object)
 //
 func (c *BatchFirestore) Set(ctx context.Context, obj db.Object) {
 	c.client.BaseClient.BeforeSet(ctx, obj)
-	docRef := c.client.refFromObj(ctx, obj)
-	c.batch.Set(docRef, obj)
-	c.hasSomethingToCommit = true
+	c.add(&batchWrite{ref: c.client.refFromObj(ctx, obj), data: snapshotData(obj)})
 }
 
 // Update partial object field, create new one if object does not exist, this function is significant slow than Set()
@@ -43,9 +182,12 @@ func (c *BatchFirestore) Set(ctx context.Context, obj db.Object) {
 //	})
 //
 func (c *BatchFirestore) Update(ctx context.Context, obj db.Object, fields map[string]interface{}) {
-	docRef := c.client.getDocRef(obj.Collection(), obj.ID())
-	c.batch.Set(docRef, fields, firestore.MergeAll)
-	c.hasSomethingToCommit = true
+	c.add(&batchWrite{
+		ref:        c.client.getDocRef(obj.Collection(), obj.ID()),
+		data:       snapshotData(fields),
+		merge:      true,
+		softDelete: obj.SoftDelete(),
+	})
 }
 
 // Increment value on object field, return error if object does not exist
@@ -53,21 +195,23 @@ func (c *BatchFirestore) Update(ctx context.Context, obj db.Object, fields map[s
 //	Increment(ctx,sample, "Value", 2)
 //
 func (c *BatchFirestore) Increment(ctx context.Context, obj db.Object, field string, value int) {
-	docRef := c.client.getDocRef(obj.Collection(), obj.ID())
-	c.batch.Update(docRef, []firestore.Update{
-		{Path: field, Value: firestore.Increment(value)},
+	c.add(&batchWrite{
+		ref:     c.client.getDocRef(obj.Collection(), obj.ID()),
+		updates: []firestore.Update{{Path: field, Value: firestore.Increment(value)}},
 	})
-	c.hasSomethingToCommit = true
 }
 
-// Delete object, no error if id not exist
+// Delete object, no error if id not exist. object use soft delete will only set delete time, batch will commit in transaction to skip document not exist
 //
 //	Delete(ctx, sample)
 //
 func (c *BatchFirestore) Delete(ctx context.Context, obj db.Object) {
 	docRef := c.client.objDeleteRef(obj)
-	c.batch.Delete(docRef)
-	c.hasSomethingToCommit = true
+	if obj.SoftDelete() {
+		c.add(&batchWrite{ref: docRef, updates: softDeleteUpdates(), softDelete: true})
+		return
+	}
+	c.add(&batchWrite{ref: docRef, delete: true})
 }
 
 // DeleteList delete object use list of id, no error if id not exist
@@ -80,9 +224,6 @@ func (c *BatchFirestore) DeleteList(ctx context.Context, obj db.Object, list []s
 		obj.SetID(id)
 		c.Delete(ctx, obj)
 	}
-	if len(list) > 0 {
-		c.hasSomethingToCommit = true
-	}
 	obj.SetID("")
 }
 
@@ -91,6 +232,5 @@ func (c *BatchFirestore) DeleteList(ctx context.Context, obj db.Object, list []s
 //	DeleteRef(ref)
 //
 func (c *BatchFirestore) DeleteRef(ref *firestore.DocumentRef) {
-	c.batch.Delete(ref)
-	c.hasSomethingToCommit = true
+	c.add(&batchWrite{ref: ref, delete: true})
 }
@@ -3,12 +3,15 @@ package gdb
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/piyuo/libsrv/db"
 	"github.com/piyuo/libsrv/mapping"
 	"github.com/pkg/errors"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // ClientFirestore implement firestore connection
@@ -52,10 +55,8 @@ func (c *ClientFirestore) Batch(ctx context.Context, f db.BatchFunc) error {
 		return ctx.Err()
 	}
 
-	native := c.native.Batch()
 	batch := &BatchFirestore{
 		client: c,
-		batch:  native,
 	}
 
 	err := f(ctx, batch)
@@ -64,7 +65,7 @@ func (c *ClientFirestore) Batch(ctx context.Context, f db.BatchFunc) error {
 	}
 
 	if batch.hasSomethingToCommit {
-		_, err = native.Commit(ctx)
+		err = batch.commit(ctx)
 		if err != nil {
 			return errors.Wrapf(err, "commit batch")
 		}
@@ -79,13 +80,21 @@ func (c *ClientFirestore) Batch(ctx context.Context, f db.BatchFunc) error {
 //	})
 //
 func (c *ClientFirestore) Transaction(ctx context.Context, f db.TransactionFunc) error {
-	return c.native.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		trans := &TransactionFirestore{
+	var trans *TransactionFirestore
+	err := c.native.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		trans = &TransactionFirestore{
 			client: c,
 			tx:     tx,
 		}
 		return f(ctx, trans)
 	})
+	if err != nil {
+		return err
+	}
+	for _, committed := range trans.committed {
+		committed()
+	}
+	return nil
 }
 
 // getCollectionRef return collection reference in table
@@ -104,10 +113,36 @@ func (c *ClientFirestore) getDocRef(tablename, id string) *firestore.DocumentRef
 	return c.getCollectionRef(tablename).Doc(id)
 }
 
+// isDeleted return true if object use soft delete and snapshot has been deleted
+//
+func isDeleted(obj db.Object, snapshot *firestore.DocumentSnapshot) bool {
+	if snapshot == nil || !snapshot.Exists() || !obj.SoftDelete() {
+		return false
+	}
+	value, err := snapshot.DataAt(db.DeleteTimeField)
+	if err != nil {
+		return false
+	}
+	t, ok := value.(time.Time)
+	return ok && !t.IsZero()
+}
+
+// softDeleteUpdates return updates to set delete time when soft delete object, update fail if document not exist so missing document is never created
+//
+func softDeleteUpdates() []firestore.Update {
+	return []firestore.Update{{Path: db.DeleteTimeField, Value: time.Now().UTC()}}
+}
+
+// isNotFound return true if error is document not found
+//
+func isNotFound(err error) bool {
+	return status.Code(errors.Cause(err)) == codes.NotFound
+}
+
 // snapshotToObject convert snap shot to object
 //
 func snapshotToObject(obj db.Object, docRef *firestore.DocumentRef, snapshot *firestore.DocumentSnapshot, err error) (db.Object, error) {
-	if snapshot != nil && !snapshot.Exists() {
+	if snapshot != nil && (!snapshot.Exists() || isDeleted(obj, snapshot)) {
 		return nil, nil
 	}
 	if err != nil {
@@ -125,7 +160,7 @@ func snapshotToObject(obj db.Object, docRef *firestore.DocumentRef, snapshot *fi
 // snapshotExists return true if snapshot exists
 //
 func snapshotExists(obj db.Object, id string, snapshot *firestore.DocumentSnapshot, err error) (bool, error) {
-	if snapshot != nil && !snapshot.Exists() {
+	if snapshot != nil && (!snapshot.Exists() || isDeleted(obj, snapshot)) {
 		return false, nil
 	}
 	if err != nil {
@@ -137,7 +172,7 @@ func snapshotExists(obj db.Object, id string, snapshot *firestore.DocumentSnapsh
 // select return object field from data store, return nil if object does not exist
 //
 func snapshotToField(obj db.Object, id, field string, snapshot *firestore.DocumentSnapshot, err error) (interface{}, error) {
-	if snapshot != nil && !snapshot.Exists() {
+	if snapshot != nil && (!snapshot.Exists() || isDeleted(obj, snapshot)) {
 		return nil, nil
 	}
 	if err != nil {
@@ -152,7 +187,7 @@ func snapshotToField(obj db.Object, id, field string, snapshot *firestore.Docume
 
 // iterObjects convert list of snapshot to list of object
 //
-func iterObjects(obj db.Object, iter *firestore.DocumentIterator) ([]db.Object, error) {
+func iterObjects(obj db.Object, iter documentIterator) ([]db.Object, error) {
 	list := []db.Object{}
 	for {
 		snapshot, err := iter.Next()
@@ -219,6 +254,27 @@ func (c *ClientFirestore) Get(ctx context.Context, obj db.Object, id string) (db
 	return snapshotToObject(obj, docRef, snapshot, err)
 }
 
+// Watch return watcher deliver change of object with id, first change is added if object exist
+//
+//	watcher := Watch(ctx, &Sample{}, "id")
+//	defer watcher.Close()
+//	for change := range watcher.Changes() {
+//	}
+//
+func (c *ClientFirestore) Watch(ctx context.Context, obj db.Object, id string) db.Watcher {
+	err := db.AssertObject(ctx, obj, false)
+	if err == nil {
+		err = db.AssertID(id)
+	}
+	if err != nil {
+		return newWatcher(ctx, obj, nil, err)
+	}
+	docRef := c.getDocRef(obj.Collection(), id)
+	return newWatcher(ctx, obj, func(ctx context.Context) listener {
+		return &docListener{obj: obj, iter: docRef.Snapshots(ctx)}
+	}, nil)
+}
+
 // Exists return true if object with id exist
 //
 //	found,err := Exists(ctx, &Sample{}, "id")
@@ -243,10 +299,7 @@ func (c *ClientFirestore) List(ctx context.Context, obj db.Object, max int) ([]d
 	if err := db.AssertObject(ctx, obj, false); err != nil {
 		return nil, err
 	}
-	collectionRef := c.getCollectionRef(obj.Collection())
-	iter := collectionRef.Limit(max).Documents(ctx)
-	defer iter.Stop()
-	return iterObjects(obj, iter)
+	return c.Query(obj).Limit(max).Return(ctx)
 }
 
 // Select return object field from data store, return nil if object does not exist
@@ -270,6 +323,14 @@ func (c *ClientFirestore) Select(ctx context.Context, obj db.Object, id, field s
 //	c.Query(ctx, &Sample{}).Return(ctx)
 //
 func (c *ClientFirestore) Query(obj db.Object) db.Query {
+	return db.ExcludeDeleted(c.QueryWithDeleted(obj), obj)
+}
+
+// QueryWithDeleted create query include soft deleted object and object has no delete time field
+//
+//	c.QueryWithDeleted(&Sample{}).Return(ctx)
+//
+func (c *ClientFirestore) QueryWithDeleted(obj db.Object) db.Query {
 	var query firestore.Query
 	if obj != nil {
 		query = c.getCollectionRef(obj.Collection()).Query
@@ -281,7 +342,7 @@ func (c *ClientFirestore) Query(obj db.Object) db.Query {
 		},
 		query:  query,
 		client: c,
-	}).Limit(20)
+	}).defaultLimit(20)
 }
 
 // Set object into table, If the document not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data, if object does not have id, it will created using UUID
@@ -292,6 +353,11 @@ func (c *ClientFirestore) Set(ctx context.Context, obj db.Object) error {
 	if err := db.AssertObject(ctx, obj, false); err != nil {
 		return err
 	}
+	if obj.Versioned() {
+		return c.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+			return tx.Set(ctx, obj)
+		})
+	}
 	c.BaseClient.BeforeSet(ctx, obj)
 	docRef := c.refFromObj(ctx, obj)
 	_, err := docRef.Set(ctx, obj)
@@ -314,6 +380,12 @@ func (c *ClientFirestore) Update(ctx context.Context, obj db.Object, fields map[
 	if len(fields) == 0 {
 		return nil
 	}
+	// versioned object need check version, soft delete object need know if document will be created
+	if obj.Versioned() || obj.SoftDelete() {
+		return c.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+			return tx.Update(ctx, obj, fields)
+		})
+	}
 	docRef := c.getDocRef(obj.Collection(), obj.ID())
 	_, err := docRef.Set(ctx, fields, firestore.MergeAll)
 	if err != nil {
@@ -341,7 +413,7 @@ func (c *ClientFirestore) Increment(ctx context.Context, obj db.Object, field st
 	return nil
 }
 
-// Delete object, no error if id not exist
+// Delete object, no error if id not exist. object use soft delete will only set delete time
 //
 //	Delete(ctx, sample)
 //
@@ -349,19 +421,85 @@ func (c *ClientFirestore) Delete(ctx context.Context, obj db.Object) error {
 	if err := db.AssertObject(ctx, obj, true); err != nil {
 		return err
 	}
+	collection, id := obj.Collection(), obj.ID()
 	docRef := c.objDeleteRef(obj)
-	_, err := docRef.Delete(ctx)
+	var err error
+	if obj.SoftDelete() {
+		_, err = docRef.Update(ctx, softDeleteUpdates())
+		if isNotFound(err) {
+			err = nil
+		}
+	} else {
+		_, err = docRef.Delete(ctx)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "delete %v-%v", collection, id)
+	}
+	return nil
+}
+
+// Restore undelete soft deleted object, return error if object does not exist or not use soft delete
+//
+//	err := Restore(ctx, sample)
+//
+func (c *ClientFirestore) Restore(ctx context.Context, obj db.Object) error {
+	if err := db.AssertObject(ctx, obj, true); err != nil {
+		return err
+	}
+	if !obj.SoftDelete() {
+		return errors.New(obj.Collection() + " not use soft delete")
+	}
+	docRef := c.getDocRef(obj.Collection(), obj.ID())
+	_, err := docRef.Update(ctx, []firestore.Update{
+		{Path: db.DeleteTimeField, Value: time.Time{}},
+	})
 	if err != nil {
-		return errors.Wrapf(err, "delete %v-%v", obj.Collection(), obj.ID())
+		return errors.Wrapf(err, "restore %v-%v", obj.Collection(), obj.ID())
 	}
+	obj.SetDeleteTime(time.Time{})
 	return nil
 }
 
+// Purge permanently delete soft deleted object which deleted before retention, return number of object deleted
+//
+//	count, err := Purge(ctx, &Sample{}, 30*24*time.Hour)
+//
+func (c *ClientFirestore) Purge(ctx context.Context, obj db.Object, retention time.Duration) (int, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return 0, err
+	}
+	if !obj.SoftDelete() {
+		return 0, errors.New(obj.Collection() + " not use soft delete")
+	}
+	max := 100
+	query := c.getCollectionRef(obj.Collection()).
+		Where(db.DeleteTimeField, ">", time.Time{}).
+		Where(db.DeleteTimeField, "<=", time.Now().UTC().Add(-retention)).
+		Limit(max)
+
+	numDeleted := 0
+	for {
+		if ctx.Err() != nil {
+			return numDeleted, ctx.Err()
+		}
+		iter := query.Documents(ctx)
+		done, count, err := c.deleteByIterator(ctx, max, iter)
+		iter.Stop()
+		numDeleted += count
+		if err != nil {
+			return numDeleted, errors.Wrap(err, "purge "+obj.Collection())
+		}
+		if done {
+			return numDeleted, nil
+		}
+	}
+}
+
 // deleteByIterator delete document using collection document iterator. delete max doc count. return true if no doc left in collection
 //
 //	done,delCount, err := deleteByIterator(ctx, 50, iter)
 //
-func (c *ClientFirestore) deleteByIterator(ctx context.Context, max int, iter *firestore.DocumentIterator) (bool, int, error) {
+func (c *ClientFirestore) deleteByIterator(ctx context.Context, max int, iter documentIterator) (bool, int, error) {
 	numDeleted := 0
 	// Iterate through the documents, adding a delete operation for each one to a WriteBatch.
 	err := c.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
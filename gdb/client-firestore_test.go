@@ -5,9 +5,11 @@ import (
 	"strconv"
 	"testing"
 
+	"github.com/piyuo/libsrv/db"
 	"github.com/piyuo/libsrv/gaccount"
 	"github.com/piyuo/libsrv/identifier"
 	"github.com/piyuo/libsrv/test"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -321,3 +323,166 @@ func BenchmarkClientUpdate(b *testing.B) {
 	}
 	client.Delete(ctx, sample)
 }
+
+func TestClientSoftDelete(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	name := "test-soft-delete-" + identifier.RandomString(8)
+
+	sample := &SampleSoftDelete{Name: name}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	id := sample.ID()
+
+	err = client.Delete(ctx, sample)
+	assert.Nil(err)
+	sample.SetID(id)
+
+	// deleted object is hidden
+	obj, err := client.Get(ctx, &SampleSoftDelete{}, id)
+	assert.Nil(err)
+	assert.Nil(obj)
+	found, err := client.Exists(ctx, &SampleSoftDelete{}, id)
+	assert.Nil(err)
+	assert.False(found)
+	count, err := client.Query(&SampleSoftDelete{}).Where("Name", "==", name).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+
+	// restore
+	err = client.Restore(ctx, sample)
+	assert.Nil(err)
+	obj, err = client.Get(ctx, &SampleSoftDelete{}, id)
+	assert.Nil(err)
+	assert.NotNil(obj)
+	count, err = client.Query(&SampleSoftDelete{}).Where("Name", "==", name).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(1, count)
+
+	// purge
+	err = client.Delete(ctx, obj)
+	assert.Nil(err)
+	_, err = client.Purge(ctx, &SampleSoftDelete{}, 0)
+	assert.Nil(err)
+	err = client.Restore(ctx, sample)
+	assert.NotNil(err)
+
+	// object not use soft delete
+	_, err = client.Purge(ctx, &Sample{}, 0)
+	assert.NotNil(err)
+}
+
+func TestClientSoftDeleteMissing(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	missing := func() *SampleSoftDelete {
+		obj := &SampleSoftDelete{}
+		obj.SetID("missing-" + identifier.RandomString(8))
+		return obj
+	}
+	obj1, obj2, obj3 := missing(), missing(), missing()
+	ids := []string{obj1.ID(), obj2.ID(), obj3.ID()}
+	err := client.Delete(ctx, obj1)
+	assert.Nil(err)
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return tx.Delete(ctx, obj2)
+	})
+	assert.Nil(err)
+	err = client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Delete(ctx, obj3)
+		return nil
+	})
+	assert.Nil(err)
+
+	// nothing written
+	native := client.(*ClientFirestore)
+	for _, id := range ids {
+		snapshot, _ := native.getDocRef((&SampleSoftDelete{}).Collection(), id).Get(ctx)
+		assert.False(snapshot != nil && snapshot.Exists())
+		obj := &SampleSoftDelete{}
+		obj.SetID(id)
+		err = client.Restore(ctx, obj)
+		assert.NotNil(err)
+	}
+}
+
+func TestClientDeleteTimeField(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	native := client.(*ClientFirestore)
+	name := "test-delete-time-" + identifier.RandomString(8)
+
+	// only object use soft delete save delete time
+	sample := &Sample{Name: name}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	defer client.Delete(ctx, sample)
+	snapshot, err := native.getDocRef(sample.Collection(), sample.ID()).Get(ctx)
+	assert.Nil(err)
+	_, err = snapshot.DataAt(db.DeleteTimeField)
+	assert.NotNil(err)
+
+	// update create soft delete object with delete time, so query can find it
+	created := func() *SampleSoftDelete {
+		obj := &SampleSoftDelete{}
+		obj.SetID("created-" + identifier.RandomString(8))
+		return obj
+	}
+	obj1, obj2, obj3 := created(), created(), created()
+	err = client.Update(ctx, obj1, map[string]interface{}{"Name": name})
+	assert.Nil(err)
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return tx.Update(ctx, obj2, map[string]interface{}{"Name": name})
+	})
+	assert.Nil(err)
+	err = client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Update(ctx, obj3, map[string]interface{}{"Name": name})
+		return nil
+	})
+	assert.Nil(err)
+	count, err := client.Query(&SampleSoftDelete{}).Where("Name", "==", name).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(3, count)
+
+	for _, obj := range []*SampleSoftDelete{obj1, obj2, obj3} {
+		native.getDocRef(obj.Collection(), obj.ID()).Delete(ctx)
+	}
+}
+
+func TestClientVersion(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	sample := &SampleVersioned{Name: "v1"}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	defer client.Delete(ctx, sample)
+	assert.Equal(int64(1), sample.Version())
+
+	obj1, err := client.Get(ctx, &SampleVersioned{}, sample.ID())
+	assert.Nil(err)
+	obj2, err := client.Get(ctx, &SampleVersioned{}, sample.ID())
+	assert.Nil(err)
+
+	err = client.Update(ctx, obj1, map[string]interface{}{"Name": "v2"})
+	assert.Nil(err)
+	assert.Equal(int64(2), obj1.Version())
+
+	// lost update detected
+	err = client.Set(ctx, obj2)
+	assert.True(errors.Is(err, db.ErrConflict))
+	assert.Equal(int64(1), obj2.Version())
+
+	err = client.Set(ctx, obj1)
+	assert.Nil(err)
+	assert.Equal(int64(3), obj1.Version())
+}
@@ -2,6 +2,7 @@ package gdb
 
 import (
 	"context"
+	"os"
 
 	"cloud.google.com/go/firestore"
 	"github.com/piyuo/libsrv/db"
@@ -10,7 +11,23 @@ import (
 	"google.golang.org/api/option"
 )
 
-// NewClient create google db client
+// EmulatorHostEnv is environment variable name of firestore emulator host, e.g. FIRESTORE_EMULATOR_HOST=localhost:8080
+//
+const EmulatorHostEnv = "FIRESTORE_EMULATOR_HOST"
+
+// EmulatorProjectID is synthetic project id used when connect to firestore emulator
+//
+const EmulatorProjectID = "libsrv-emulator"
+
+// EmulatorHost return firestore emulator host from environment variable, return empty if emulator is not used
+//
+//	host := EmulatorHost() // "localhost:8080"
+//
+func EmulatorHost() string {
+	return os.Getenv(EmulatorHostEnv)
+}
+
+// NewClient create google db client, connect to firestore emulator if FIRESTORE_EMULATOR_HOST is set, cred can be nil in this case
 //
 //	cred, err := gaccount.GlobalCredential(ctx)
 //	return NewClient(ctx, cred)
@@ -19,6 +36,9 @@ func NewClient(ctx context.Context, cred *google.Credentials) (db.Client, error)
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
+	if EmulatorHost() != "" {
+		return NewEmulatorClient(ctx)
+	}
 	if cred == nil {
 		return nil, errors.New("cred must no nil")
 	}
@@ -32,3 +52,25 @@ func NewClient(ctx context.Context, cred *google.Credentials) (db.Client, error)
 	}
 	return client, nil
 }
+
+// NewEmulatorClient create google db client connect to firestore emulator on FIRESTORE_EMULATOR_HOST, firestore sdk connect emulator without credentials and project id is EmulatorProjectID
+//
+//	client, err := NewEmulatorClient(ctx)
+//
+func NewEmulatorClient(ctx context.Context) (db.Client, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if EmulatorHost() == "" {
+		return nil, errors.New(EmulatorHostEnv + " must no empty")
+	}
+	firestoreClient, err := firestore.NewClient(ctx, EmulatorProjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &ClientFirestore{
+		native: firestoreClient,
+	}
+	return client, nil
+}
@@ -40,3 +40,34 @@ func TestNewClient(t *testing.T) {
 	assert.NotNil(err)
 	assert.Nil(client)
 }
+
+func TestNewEmulatorClient(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	t.Setenv(EmulatorHostEnv, "")
+	client, err := NewEmulatorClient(ctx)
+	assert.NotNil(err)
+	assert.Nil(client)
+
+	t.Setenv(EmulatorHostEnv, "localhost:8080")
+	client, err = NewEmulatorClient(test.CanceledContext())
+	assert.NotNil(err)
+	assert.Nil(client)
+
+	client, err = NewEmulatorClient(ctx)
+	assert.Nil(err)
+	assert.NotNil(client)
+	client.Close()
+}
+
+func TestNewClientUseEmulator(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	t.Setenv(EmulatorHostEnv, "localhost:8080")
+
+	// no cred needed when using emulator
+	client, err := NewClient(ctx, nil)
+	assert.Nil(err)
+	assert.NotNil(client)
+	client.Close()
+}
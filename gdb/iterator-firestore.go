@@ -0,0 +1,207 @@
+package gdb
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// IteratorFirestore walk through firestore query result page by page, every page is a new query start after last document of previous page
+//
+type IteratorFirestore struct {
+	db.Iterator
+
+	// ctx is context used to read document
+	//
+	ctx context.Context
+
+	// query is firestore query
+	//
+	query firestore.Query
+
+	// obj is query object used to create object
+	//
+	obj db.Object
+
+	// pageSize is document count read in one page
+	//
+	pageSize int
+
+	// iter is current page iterator, it is nil when page is not start
+	//
+	iter *firestore.DocumentIterator
+
+	// countInPage is document count read in current page
+	//
+	countInPage int
+
+	// last is last document read, next page will start after it
+	//
+	last *firestore.DocumentSnapshot
+
+	// current is current object
+	//
+	current db.Object
+
+	// err is error happen in Next()
+	//
+	err error
+
+	// done is true if no more document
+	//
+	done bool
+}
+
+// Next move to next object, return false when no more object or error happen, use Err() to check error
+//
+//	for iter.Next() {
+//		sample := iter.Object().(*Sample)
+//	}
+//
+func (c *IteratorFirestore) Next() bool {
+	c.current = nil
+	if c.err != nil || c.done {
+		return false
+	}
+	for {
+		if c.ctx.Err() != nil {
+			c.err = c.ctx.Err()
+			c.Close()
+			return false
+		}
+		if c.iter == nil {
+			query := c.query.Limit(c.pageSize)
+			if c.last != nil {
+				query = query.StartAfter(c.last)
+			}
+			c.iter = query.Documents(c.ctx)
+			c.countInPage = 0
+		}
+
+		snapshot, err := c.iter.Next()
+		if err == iterator.Done {
+			c.iter.Stop()
+			c.iter = nil
+			if c.countInPage < c.pageSize {
+				c.done = true
+				return false
+			}
+			continue
+		}
+		if err != nil {
+			c.err = errors.Wrapf(err, "iter next %v", c.obj.Collection())
+			c.Close()
+			return false
+		}
+		c.countInPage++
+		c.last = snapshot
+
+		obj := c.obj.Factory()
+		if obj == nil {
+			c.err = errors.New(c.obj.Collection() + " not implement Factory()")
+			c.Close()
+			return false
+		}
+		if _, err := snapshotToObject(obj, snapshot.Ref, snapshot, nil); err != nil {
+			c.err = err
+			c.Close()
+			return false
+		}
+		c.current = obj
+		return true
+	}
+}
+
+// Object return current object, return nil if Next() never been called or return false
+//
+//	sample := iter.Object().(*Sample)
+//
+func (c *IteratorFirestore) Object() db.Object {
+	return c.current
+}
+
+// Err return error happen in Next(), return ctx.Err() if context is canceled
+//
+//	err := iter.Err()
+//
+func (c *IteratorFirestore) Err() error {
+	return c.err
+}
+
+// Close release resource used by iterator, it is safe to call Close() multiple times
+//
+//	defer iter.Close()
+//
+func (c *IteratorFirestore) Close() {
+	if c.iter != nil {
+		c.iter.Stop()
+		c.iter = nil
+	}
+	c.done = true
+}
+
+// documentIterator is iterator on document snapshot, it is implement by firestore.DocumentIterator and unionIterator
+//
+type documentIterator interface {
+	Next() (*firestore.DocumentSnapshot, error)
+	Stop()
+}
+
+// unionIterator merge documents from iterators one after another, document already return by previous iterator will be skipped, it is used to run or filter
+//
+type unionIterator struct {
+
+	// iters is iterators to merge
+	//
+	iters []*firestore.DocumentIterator
+
+	// limit is max document to return, 0 mean no limit
+	//
+	limit int
+
+	// count is document count already return
+	//
+	count int
+
+	// seen keep document path already return
+	//
+	seen map[string]bool
+}
+
+// Next return next document, return iterator.Done when no more document
+//
+func (c *unionIterator) Next() (*firestore.DocumentSnapshot, error) {
+	for len(c.iters) > 0 {
+		if c.limit > 0 && c.count >= c.limit {
+			return nil, iterator.Done
+		}
+		snapshot, err := c.iters[0].Next()
+		if err == iterator.Done {
+			c.iters[0].Stop()
+			c.iters = c.iters[1:]
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.seen[snapshot.Ref.Path] {
+			continue
+		}
+		c.seen[snapshot.Ref.Path] = true
+		c.count++
+		return snapshot, nil
+	}
+	return nil, iterator.Done
+}
+
+// Stop release all iterators
+//
+func (c *unionIterator) Stop() {
+	for _, iter := range c.iters {
+		iter.Stop()
+	}
+	c.iters = nil
+}
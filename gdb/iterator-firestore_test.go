@@ -0,0 +1,65 @@
+package gdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/identifier"
+	"github.com/piyuo/libsrv/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterator(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	rand := identifier.RandomString(8)
+	for i := 0; i < 7; i++ {
+		sample := &Sample{
+			Name:  "test-iterator-" + rand,
+			Value: i%2 + 1,
+			Tag:   rand,
+		}
+		err := client.Set(ctx, sample)
+		assert.Nil(err)
+	}
+	defer client.Query(&Sample{}).Where("Tag", "==", rand).Delete(ctx, 100)
+
+	iter := client.Query(&Sample{}).Where("Tag", "==", rand).OrderBy("Value").Iterate(ctx, 2)
+	defer iter.Close()
+	ids := map[string]bool{}
+	last := -1
+	for iter.Next() {
+		sample := iter.Object().(*Sample)
+		assert.True(sample.Value >= last)
+		last = sample.Value
+		ids[sample.ID()] = true
+	}
+	assert.Nil(iter.Err())
+	assert.Len(ids, 7)
+	assert.False(iter.Next())
+}
+
+func TestIteratorError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	iter := client.Query(nil).Iterate(ctx, 10)
+	assert.False(iter.Next())
+	assert.NotNil(iter.Err())
+
+	iter = client.Query(&Sample{}).Iterate(test.CanceledContext(), 10)
+	assert.False(iter.Next())
+	assert.NotNil(iter.Err())
+
+	client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		iter := tx.Query(&Sample{}).Iterate(ctx, 10)
+		assert.False(iter.Next())
+		assert.NotNil(iter.Err())
+		return nil
+	})
+}
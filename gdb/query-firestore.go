@@ -2,14 +2,24 @@ package gdb
 
 import (
 	"context"
+	"math"
 
 	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
 	"github.com/piyuo/libsrv/db"
 	"github.com/piyuo/libsrv/log"
+	"github.com/piyuo/libsrv/util"
 	"github.com/pkg/errors"
 	"google.golang.org/api/iterator"
 )
 
+// order is query order by field
+//
+type order struct {
+	path string
+	desc bool
+}
+
 // QueryFirestore implement google firestore
 type QueryFirestore struct {
 	db.BaseQuery
@@ -21,6 +31,70 @@ type QueryFirestore struct {
 	// query is firestore query
 	//
 	query firestore.Query
+
+	// disjunctions is and-groups from or filter, each group run as a query and result will be merged, nil mean no or filter
+	//
+	disjunctions [][]*db.Filter
+
+	// limit is query limit, it is used to limit merged result of or filter
+	//
+	limit int
+
+	// limited is true if limit set by Limit(), default limit set when query created is not counted
+	//
+	limited bool
+
+	// ordered is true if query has order by or cursor, or filter can not use with them
+	//
+	ordered bool
+
+	// orders is order by fields, it is used to create page token
+	//
+	orders []order
+
+	// inequality is first field use inequality filter, firestore order by it when there is no order by
+	//
+	inequality string
+
+	// pageValues is order field values of last object in previous page
+	//
+	pageValues []interface{}
+
+	// pageID is id of last object in previous page, empty mean first page
+	//
+	pageID string
+
+	// encrypt is true if page token need encrypt
+	//
+	encrypt bool
+
+	// err keep error happen when build query, it will be return when query execute
+	//
+	err error
+}
+
+// where return query with filter, if path == "ID" mean using document id in as filter
+//
+func (c *QueryFirestore) where(query firestore.Query, path, op string, value interface{}) (firestore.Query, error) {
+	if err := db.AssertOp(op); err != nil {
+		return query, err
+	}
+	if c.QueryObject != nil && path == "ID" {
+		path = firestore.DocumentID
+		switch v := value.(type) {
+		case string:
+			value = c.client.getDocRef(c.QueryObject.Collection(), v)
+		case []string:
+			refs := make([]*firestore.DocumentRef, len(v))
+			for i, id := range v {
+				refs[i] = c.client.getDocRef(c.QueryObject.Collection(), id)
+			}
+			value = refs
+		default:
+			return query, errors.Errorf("ID filter value must be string or []string, got %T", value)
+		}
+	}
+	return query.Where(path, op, value), nil
 }
 
 // Where set filter, if path == "ID" mean using document id in as filter
@@ -28,11 +102,52 @@ type QueryFirestore struct {
 //	list, err := Query(&Sample{}).Where("ID", "==", "sample1").Return(ctx)
 //
 func (c *QueryFirestore) Where(path, op string, value interface{}) db.Query {
-	if c.QueryObject != nil && path == "ID" {
-		path = firestore.DocumentID
-		value = c.client.getDocRef(c.QueryObject.Collection(), value.(string))
+	query, err := c.where(c.query, path, op, value)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	if c.inequality == "" && path != "ID" && db.IsInequality(op) {
+		c.inequality = path
+	}
+	c.query = query
+	return c
+}
+
+// WhereFilter set composite filter, it combine with other filter using and. or filter is run as one query per and-group and result is merged, so it can not use with OrderBy() and cursor
+//
+//	list, err := Query(&Sample{}).WhereFilter(db.Or(
+//		db.Field("Tag", "in", []string{"a", "b"}),
+//		db.Field("Array", "array-contains", "x"),
+//	)).Return(ctx)
+//
+func (c *QueryFirestore) WhereFilter(filter *db.Filter) db.Query {
+	groups, err := filter.Disjunctions()
+	if err != nil {
+		c.err = err
+		return c
+	}
+	if len(groups) == 1 {
+		for _, f := range groups[0] {
+			c.Where(f.Path, f.Op, f.Value)
+		}
+		return c
+	}
+	if c.disjunctions == nil {
+		c.disjunctions = groups
+		return c
+	}
+	product := [][]*db.Filter{}
+	for _, left := range c.disjunctions {
+		for _, right := range groups {
+			product = append(product, append(append([]*db.Filter{}, left...), right...))
+		}
+	}
+	if len(product) > db.MaxDisjunctions {
+		c.err = errors.Errorf("filter expand to %v queries, max is %v", len(product), db.MaxDisjunctions)
+		return c
 	}
-	c.query = c.query.Where(path, op, value)
+	c.disjunctions = product
 	return c
 }
 
@@ -41,6 +156,8 @@ func (c *QueryFirestore) Where(path, op string, value interface{}) db.Query {
 //	list, err = Query(&Sample{}).OrderBy("Name").Return(ctx)
 //
 func (c *QueryFirestore) OrderBy(path string) db.Query {
+	c.ordered = true
+	c.orders = append(c.orders, order{path: path})
 	c.query = c.query.OrderBy(path, firestore.Asc)
 	return c
 }
@@ -50,6 +167,8 @@ func (c *QueryFirestore) OrderBy(path string) db.Query {
 //	list, err = Query(&Sample{}).OrderByDesc("Name").Limit(1).Return(ctx)
 //
 func (c *QueryFirestore) OrderByDesc(path string) db.Query {
+	c.ordered = true
+	c.orders = append(c.orders, order{path: path, desc: true})
 	c.query = c.query.OrderBy(path, firestore.Desc)
 	return c
 }
@@ -59,15 +178,26 @@ func (c *QueryFirestore) OrderByDesc(path string) db.Query {
 //	list, err = Query(&Sample{}).OrderBy("Name").Limit(1).Return(ctx)
 //
 func (c *QueryFirestore) Limit(n int) db.Query {
+	c.limit = n
+	c.limited = true
 	c.query = c.query.Limit(n)
 	return c
 }
 
+// defaultLimit set limit used when Limit() not called, Watch() ignore it
+//
+func (c *QueryFirestore) defaultLimit(n int) *QueryFirestore {
+	c.Limit(n)
+	c.limited = false
+	return c
+}
+
 // StartAt implement Paginate on firestore, please be aware not use index but fieldValue to do the trick, see sample
 //
 //	list, err = Query(&Sample{}).OrderBy("Name").StartAt("irvine city").Return(ctx)
 //
 func (c *QueryFirestore) StartAt(docSnapshotOrFieldValues ...interface{}) db.Query {
+	c.ordered = true
 	c.query = c.query.StartAt(docSnapshotOrFieldValues...)
 	return c
 }
@@ -77,6 +207,7 @@ func (c *QueryFirestore) StartAt(docSnapshotOrFieldValues ...interface{}) db.Que
 //	list, err = Query(&Sample{}).OrderBy("Name").StartAfter("santa ana city").Return(ctx)
 //
 func (c *QueryFirestore) StartAfter(docSnapshotOrFieldValues ...interface{}) db.Query {
+	c.ordered = true
 	c.query = c.query.StartAfter(docSnapshotOrFieldValues...)
 	return c
 }
@@ -86,6 +217,7 @@ func (c *QueryFirestore) StartAfter(docSnapshotOrFieldValues ...interface{}) db.
 //	list, err = Query(&Sample{}).OrderBy("Name").EndAt("irvine city").Return(ctx)
 //
 func (c *QueryFirestore) EndAt(docSnapshotOrFieldValues ...interface{}) db.Query {
+	c.ordered = true
 	c.query = c.query.EndAt(docSnapshotOrFieldValues...)
 	return c
 }
@@ -95,23 +227,90 @@ func (c *QueryFirestore) EndAt(docSnapshotOrFieldValues ...interface{}) db.Query
 //	list, err = Query(&Sample{}).OrderBy("Name").EndBefore("irvine city").Return(ctx)
 //
 func (c *QueryFirestore) EndBefore(docSnapshotOrFieldValues ...interface{}) db.Query {
+	c.ordered = true
 	c.query = c.query.EndBefore(docSnapshotOrFieldValues...)
 	return c
 }
 
-func (c *QueryFirestore) returnIter(ctx context.Context) (*firestore.DocumentIterator, error) {
+// Paginate resume query after page token return by ReturnPage(), empty token mean first page. token is encrypted using crypto package if encrypt is true, use the same encrypt on every page
+//
+//	list, next, err := Query(&Sample{}).OrderBy("Name").Paginate(token, true).ReturnPage(ctx)
+//
+func (c *QueryFirestore) Paginate(token string, encrypt bool) db.Query {
+	c.encrypt = encrypt
+	if token == "" {
+		return c
+	}
+	values, id, err := db.DecodePageToken(token, encrypt)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.pageValues = values
+	c.pageID = id
+	return c
+}
+
+// queries return firestore queries to run, there is one query for each and-group if query has or filter
+//
+func (c *QueryFirestore) queries() ([]firestore.Query, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.disjunctions == nil {
+		return []firestore.Query{c.query}, nil
+	}
+	if c.ordered {
+		return nil, errors.New("or filter can not use with order by and cursor")
+	}
+	queries := []firestore.Query{}
+	for _, group := range c.disjunctions {
+		query := c.query
+		for _, f := range group {
+			var err error
+			query, err = c.where(query, f.Path, f.Op, f.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		queries = append(queries, query)
+	}
+	return queries, nil
+}
+
+// documents return iterator on documents match query, use selector to change query before run, limit is max document to return from merged result of or filter, 0 mean no limit
+//
+func (c *QueryFirestore) documents(ctx context.Context, selector func(firestore.Query) firestore.Query, limit int) (documentIterator, error) {
 	if err := db.AssertObject(ctx, c.QueryObject, false); err != nil {
 		return nil, err
 	}
+	queries, err := c.queries()
+	if err != nil {
+		return nil, err
+	}
 
-	var iter *firestore.DocumentIterator
-	if c.QueryTransaction != nil {
-		trans := c.QueryTransaction.(*TransactionFirestore)
-		iter = trans.tx.Documents(c.query)
-	} else {
-		iter = c.query.Documents(ctx)
+	iters := []*firestore.DocumentIterator{}
+	for _, query := range queries {
+		if selector != nil {
+			query = selector(query)
+		}
+		if c.QueryTransaction != nil {
+			trans := c.QueryTransaction.(*TransactionFirestore)
+			iters = append(iters, trans.tx.Documents(query))
+		} else {
+			iters = append(iters, query.Documents(ctx))
+		}
+	}
+	if len(iters) == 1 {
+		return iters[0], nil
 	}
-	return iter, nil
+	return &unionIterator{iters: iters, limit: limit, seen: map[string]bool{}}, nil
+}
+
+// returnIter return iterator on documents match query
+//
+func (c *QueryFirestore) returnIter(ctx context.Context) (documentIterator, error) {
+	return c.documents(ctx, nil, c.limit)
 }
 
 // Return query result with default limit to 20 object, use Limit() to override default limit, return nil if anything wrong
@@ -152,7 +351,7 @@ func (c *QueryFirestore) ReturnID(ctx context.Context) ([]string, error) {
 	return result, nil
 }
 
-// ReturnCount return object count with default limit to 20 object, use Limit() to override default limit
+// ReturnCount return object count with default limit to 20 object, use Limit() to override default limit, use Count() to get exact count
 //
 //	count, err := Query(&Sample{}).Where("Name", "==", "sample1").ReturnCount(ctx)
 //
@@ -177,6 +376,160 @@ func (c *QueryFirestore) ReturnCount(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// aggregateQuery return firestore aggregation query on all document match query, Limit() is ignored. it run in transaction if query is in transaction. return nil if query has or filter, firestore can not aggregate merged result so it need scan by aggregateIter
+//
+func (c *QueryFirestore) aggregateQuery(ctx context.Context) (*firestore.AggregationQuery, error) {
+	if err := db.AssertObject(ctx, c.QueryObject, false); err != nil {
+		return nil, err
+	}
+	queries, err := c.queries()
+	if err != nil {
+		return nil, err
+	}
+	if len(queries) > 1 {
+		return nil, nil
+	}
+	query := queries[0].Limit(math.MaxInt32)
+	aggregation := query.NewAggregationQuery()
+	if c.QueryTransaction != nil {
+		trans := c.QueryTransaction.(*TransactionFirestore)
+		aggregation = aggregation.Transaction(trans.tx)
+	}
+	return aggregation, nil
+}
+
+// aggregateResult run aggregation query and return value of alias as float64, null value mean nothing to aggregate and return 0
+//
+func (c *QueryFirestore) aggregateResult(ctx context.Context, aggregation *firestore.AggregationQuery, alias string) (float64, error) {
+	result, err := aggregation.Get(ctx)
+	if err != nil {
+		return 0, errors.Wrapf(err, "aggregate %v", c.QueryObject.Collection())
+	}
+	value, ok := result[alias].(*firestorepb.Value)
+	if !ok {
+		return 0, errors.Errorf("aggregate %v return no %v", c.QueryObject.Collection(), alias)
+	}
+	switch v := value.ValueType.(type) {
+	case *firestorepb.Value_IntegerValue:
+		return float64(v.IntegerValue), nil
+	case *firestorepb.Value_DoubleValue:
+		return v.DoubleValue, nil
+	case *firestorepb.Value_NullValue:
+		return 0, nil
+	}
+	return 0, errors.Errorf("aggregate %v return unexpected %v %v", c.QueryObject.Collection(), alias, value)
+}
+
+// aggregateIter return iterator on all document match query, Limit() is ignored and only given fields will be read, no field mean only read document reference. it is used by or filter which firestore can not aggregate
+//
+func (c *QueryFirestore) aggregateIter(ctx context.Context, fields ...string) (documentIterator, error) {
+	return c.documents(ctx, func(query firestore.Query) firestore.Query {
+		return query.Select(fields...).Limit(math.MaxInt32)
+	}, 0)
+}
+
+// aggregate scan field on all document match query, return sum and count of numeric value
+//
+func (c *QueryFirestore) aggregate(ctx context.Context, field string) (float64, int, error) {
+	iter, err := c.aggregateIter(ctx, field)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer iter.Stop()
+
+	var sum float64
+	count := 0
+	for {
+		snapshot, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "iter next %v", c.QueryObject.Collection())
+		}
+		value, err := snapshot.DataAt(field)
+		if err != nil {
+			continue // field not exist
+		}
+		number, err := util.ToFloat64(value)
+		if err != nil {
+			continue // not numeric
+		}
+		sum += number
+		count++
+	}
+	return sum, count, nil
+}
+
+// Count return exact count of object match query, Limit() is ignored and object content will not be read. it use firestore aggregation query and run in transaction if query is in transaction, or filter will scan document reference to merge result
+//
+//	count, err := Query(&Sample{}).Where("Name", "==", "sample1").Count(ctx)
+//
+func (c *QueryFirestore) Count(ctx context.Context) (int, error) {
+	aggregation, err := c.aggregateQuery(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if aggregation != nil {
+		count, err := c.aggregateResult(ctx, aggregation.WithCount("count"), "count")
+		return int(count), err
+	}
+
+	iter, err := c.aggregateIter(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Stop()
+
+	count := 0
+	for {
+		_, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "iter next %v", c.QueryObject.Collection())
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Sum return sum of numeric field on all object match query, Limit() is ignored and non-numeric value is ignored. it use firestore aggregation query and run in transaction if query is in transaction, or filter will scan the field to merge result
+//
+//	total, err := Query(&Sample{}).Where("Tag", "==", "a").Sum(ctx, "Value")
+//
+func (c *QueryFirestore) Sum(ctx context.Context, field string) (float64, error) {
+	aggregation, err := c.aggregateQuery(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if aggregation != nil {
+		return c.aggregateResult(ctx, aggregation.WithSum(field, "sum"), "sum")
+	}
+	sum, _, err := c.aggregate(ctx, field)
+	return sum, err
+}
+
+// Avg return average of numeric field on all object match query, Limit() is ignored and non-numeric value is ignored, return 0 if no numeric value. it use firestore aggregation query and run in transaction if query is in transaction, or filter will scan the field to merge result
+//
+//	avg, err := Query(&Sample{}).Where("Tag", "==", "a").Avg(ctx, "Value")
+//
+func (c *QueryFirestore) Avg(ctx context.Context, field string) (float64, error) {
+	aggregation, err := c.aggregateQuery(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if aggregation != nil {
+		return c.aggregateResult(ctx, aggregation.WithAvg(field, "avg"), "avg")
+	}
+	sum, count, err := c.aggregate(ctx, field)
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	return sum / float64(count), nil
+}
+
 // ReturnEmpty return true if no object exist
 //
 //	isEmpty, err := Query(&Sample{}).Where("Name", "==", "sample1").ReturnEmpty(ctx)
@@ -245,6 +598,149 @@ func (c *QueryFirestore) ReturnFirstID(ctx context.Context) (string, error) {
 	return list[0].ID(), nil
 }
 
+// ReturnPage return one page of object with default limit to 20 object, use Limit() to set page size. return url-safe token to next page, token is empty if no more object. not support with or filter
+//
+//	list, next, err := Query(&Sample{}).OrderBy("Name").Paginate(token, false).ReturnPage(ctx)
+//
+func (c *QueryFirestore) ReturnPage(ctx context.Context) ([]db.Object, string, error) {
+	if err := db.AssertObject(ctx, c.QueryObject, false); err != nil {
+		return nil, "", err
+	}
+	if c.err != nil {
+		return nil, "", c.err
+	}
+	if c.disjunctions != nil {
+		return nil, "", errors.New("page token is not support with or filter")
+	}
+
+	// order by document id after all order, so object with the same order values has stable position
+	orders := c.orders
+	query := c.query
+	if len(orders) == 0 && c.inequality != "" {
+		orders = []order{{path: c.inequality}}
+		query = query.OrderBy(c.inequality, firestore.Asc)
+	}
+	direction := firestore.Asc
+	if len(orders) > 0 && orders[len(orders)-1].desc {
+		direction = firestore.Desc
+	}
+	query = query.OrderBy(firestore.DocumentID, direction)
+	if c.pageID != "" {
+		if len(c.pageValues) != len(orders) {
+			return nil, "", errors.New("page token not match query order")
+		}
+		values := append(append([]interface{}{}, c.pageValues...), c.client.getDocRef(c.QueryObject.Collection(), c.pageID))
+		query = query.StartAfter(values...)
+	}
+	if c.limit > 0 {
+		query = query.Limit(c.limit + 1) // read one more to know if there is next page
+	}
+
+	var iter *firestore.DocumentIterator
+	if c.QueryTransaction != nil {
+		trans := c.QueryTransaction.(*TransactionFirestore)
+		iter = trans.tx.Documents(query)
+	} else {
+		iter = query.Documents(ctx)
+	}
+	defer iter.Stop()
+	snapshots, err := iter.GetAll()
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "page %v", c.QueryObject.Collection())
+	}
+
+	next := ""
+	if c.limit > 0 && len(snapshots) > c.limit {
+		snapshots = snapshots[:c.limit]
+		last := snapshots[len(snapshots)-1]
+		values := []interface{}{}
+		for _, o := range orders {
+			value, err := last.DataAt(o.path)
+			if err != nil {
+				return nil, "", errors.Wrapf(err, "page token value %v", o.path)
+			}
+			values = append(values, value)
+		}
+		next, err = db.EncodePageToken(values, last.Ref.ID, c.encrypt)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	list := []db.Object{}
+	for _, snapshot := range snapshots {
+		obj := c.QueryObject.Factory()
+		if obj == nil {
+			return nil, "", errors.New(c.QueryObject.Collection() + " not implement Factory()")
+		}
+		if _, err := snapshotToObject(obj, snapshot.Ref, snapshot, nil); err != nil {
+			return nil, "", errors.Wrapf(err, "page snapshot to object %v-%v", c.QueryObject.Collection(), snapshot.Ref.ID)
+		}
+		list = append(list, obj)
+	}
+	return list, next, nil
+}
+
+// Iterate return iterator to walk through all object page by page, use it to read large collection in bounded memory. Limit() is ignored, pageSize is object count read from database at a time, not support in transaction
+//
+//	iter := Query(&Sample{}).OrderBy("Name").Iterate(ctx, 100)
+//	defer iter.Close()
+//	for iter.Next() {
+//		sample := iter.Object().(*Sample)
+//	}
+//	err := iter.Err()
+//
+func (c *QueryFirestore) Iterate(ctx context.Context, pageSize int) db.Iterator {
+	if pageSize <= 0 {
+		pageSize = db.DefaultPageSize
+	}
+	iter := &IteratorFirestore{
+		ctx:      ctx,
+		query:    c.query,
+		obj:      c.QueryObject,
+		pageSize: pageSize,
+	}
+	if err := db.AssertObject(ctx, c.QueryObject, false); err != nil {
+		iter.err = err
+	} else if c.QueryTransaction != nil {
+		iter.err = errors.New("iterate query is not support in transaction")
+	} else if c.err != nil {
+		iter.err = c.err
+	} else if c.disjunctions != nil {
+		iter.err = errors.New("iterate query is not support with or filter")
+	}
+	return iter
+}
+
+// Watch return watcher deliver change of all object match query, first change are all object currently match query. default limit is ignored, only limit set by Limit() is kept. not support in transaction and with or filter
+//
+//	watcher := Query(&Sample{}).Where("Tag", "==", "a").Watch(ctx)
+//	defer watcher.Close()
+//	for change := range watcher.Changes() {
+//		sample := change.Object.(*Sample)
+//	}
+//
+func (c *QueryFirestore) Watch(ctx context.Context) db.Watcher {
+	err := db.AssertObject(ctx, c.QueryObject, false)
+	if err == nil && c.QueryTransaction != nil {
+		err = errors.New("watch query is not support in transaction")
+	} else if err == nil && c.disjunctions != nil {
+		err = errors.New("watch query is not support with or filter")
+	} else if err == nil && c.err != nil {
+		err = c.err
+	}
+	if err != nil {
+		return newWatcher(ctx, c.QueryObject, nil, err)
+	}
+	query := c.query
+	if !c.limited {
+		query = query.Limit(math.MaxInt32)
+	}
+	return newWatcher(ctx, c.QueryObject, func(ctx context.Context) listener {
+		return &queryListener{iter: query.Snapshots(ctx)}
+	}, nil)
+}
+
 // Delete delete all document return from query. delete max doc count. return is done,delete count, error
 //
 //	done, count, err := client.Query(&Sample{}).Where("Name", "==", name).Delete(ctx, 100)
@@ -268,7 +764,10 @@ func (c *QueryFirestore) Delete(ctx context.Context, max int) (bool, int, error)
 	}
 
 	c.Limit(max)
-	iter := c.query.Documents(ctx)
+	iter, err := c.returnIter(ctx)
+	if err != nil {
+		return false, 0, errors.Wrap(err, "delete query")
+	}
 	defer iter.Stop()
 	complete, numDeletd, err := c.client.deleteByIterator(ctx, max, iter)
 	if err != nil {
@@ -277,7 +776,7 @@ func (c *QueryFirestore) Delete(ctx context.Context, max int) (bool, int, error)
 	return complete, numDeletd, nil
 }
 
-// Cleanup delete 25 document a time, max 60,000 object. return true if no object left in collection
+// Cleanup delete 25 document a time, at most 60,000 object in one call. return true if no object left in collection
 //
 //	done,  err := client.Query(&Sample{}).Where("Name", "==", name).Cleanup(ctx)
 //
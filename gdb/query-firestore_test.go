@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"cloud.google.com/go/firestore"
 	"github.com/piyuo/libsrv/db"
 	"github.com/piyuo/libsrv/identifier"
 	"github.com/stretchr/testify/assert"
@@ -283,3 +284,173 @@ func BenchmarkCleanup(b *testing.B) {
 	name := "test-query-benchmark"
 	client.Query(&Sample{}).Where("Name", "==", name).Cleanup(ctx)
 }
+
+func TestQueryAggregate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	rand := identifier.RandomString(8)
+
+	for i := 0; i < 22; i++ {
+		sample := &Sample{Name: "test-query-aggregate", Value: i%2 + 1, Tag: rand}
+		err := client.Set(ctx, sample)
+		assert.Nil(err)
+		defer client.Delete(ctx, sample)
+	}
+
+	// ReturnCount is bounded by default limit, Count is not
+	count, err := client.Query(&Sample{}).Where("Tag", "==", rand).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(20, count)
+
+	count, err = client.Query(&Sample{}).Where("Tag", "==", rand).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(22, count)
+
+	sum, err := client.Query(&Sample{}).Where("Tag", "==", rand).Sum(ctx, "Value")
+	assert.Nil(err)
+	assert.Equal(float64(33), sum)
+
+	avg, err := client.Query(&Sample{}).Where("Tag", "==", rand).Avg(ctx, "Value")
+	assert.Nil(err)
+	assert.Equal(1.5, avg)
+
+	avg, err = client.Query(&Sample{}).Where("Tag", "==", "not exist"+rand).Avg(ctx, "Value")
+	assert.Nil(err)
+	assert.Equal(float64(0), avg)
+
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		count, err := tx.Query(&Sample{}).Where("Tag", "==", rand).Count(ctx)
+		assert.Nil(err)
+		assert.Equal(22, count)
+		sum, err := tx.Query(&Sample{}).Where("Tag", "==", rand).Sum(ctx, "Value")
+		assert.Nil(err)
+		assert.Equal(float64(33), sum)
+		avg, err := tx.Query(&Sample{}).Where("Tag", "==", rand).Avg(ctx, "Value")
+		assert.Nil(err)
+		assert.Equal(1.5, avg)
+		return nil
+	})
+	assert.Nil(err)
+
+	// no obj will result error
+	_, err = client.Query(nil).Count(ctx)
+	assert.NotNil(err)
+}
+
+func TestQueryWhereFilter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	rand := identifier.RandomString(8)
+
+	samples := []*Sample{
+		{Name: "a city", Value: 1, Tag: rand, Array: []string{"x", "y"}},
+		{Name: "b city", Value: 2, Tag: rand, Array: []string{"y"}},
+		{Name: "c city", Value: 3, Tag: rand},
+	}
+	for _, sample := range samples {
+		err := client.Set(ctx, sample)
+		assert.Nil(err)
+		defer client.Delete(ctx, sample)
+	}
+
+	// or, document match more than one group only return once
+	list, err := client.Query(&Sample{}).Where("Tag", "==", rand).WhereFilter(db.Or(
+		db.Field("Name", "==", "a city"),
+		db.Field("Array", "array-contains", "y"),
+	)).Return(ctx)
+	assert.Nil(err)
+	assert.Len(list, 2)
+
+	count, err := client.Query(&Sample{}).Where("Tag", "==", rand).WhereFilter(db.Or(
+		db.Field("Value", "==", 1),
+		db.Field("Value", ">", 1),
+	)).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(3, count)
+
+	// in, not-in, array-contains-any
+	count, err = client.Query(&Sample{}).Where("Tag", "==", rand).WhereFilter(db.Field("Value", "in", []int{1, 3})).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(2, count)
+
+	idList, err := client.Query(&Sample{}).Where("Tag", "==", rand).WhereFilter(db.Field("ID", "in", []string{samples[0].ID(), samples[1].ID()})).ReturnID(ctx)
+	assert.Nil(err)
+	assert.ElementsMatch([]string{samples[0].ID(), samples[1].ID()}, idList)
+
+	count, err = client.Query(&Sample{}).Where("Tag", "==", rand).WhereFilter(db.Field("Value", "not-in", []int{1, 3})).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(1, count)
+
+	count, err = client.Query(&Sample{}).Where("Tag", "==", rand).WhereFilter(db.Field("Array", "array-contains-any", []string{"x", "z"})).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(1, count)
+
+	// limit on merged result
+	list, err = client.Query(&Sample{}).Where("Tag", "==", rand).WhereFilter(db.Or(
+		db.Field("Value", "==", 1),
+		db.Field("Value", "==", 2),
+	)).Limit(1).Return(ctx)
+	assert.Nil(err)
+	assert.Len(list, 1)
+
+	// invalid op will not hit backend
+	_, err = client.Query(&Sample{}).Where("Name", "=", "a city").Return(ctx)
+	assert.NotNil(err)
+
+	// unsupported combination
+	_, err = client.Query(&Sample{}).WhereFilter(db.And(
+		db.Field("Value", "in", []int{1, 2}),
+		db.Field("Tag", "not-in", []string{rand}),
+	)).Return(ctx)
+	assert.NotNil(err)
+
+	// or with order by
+	_, err = client.Query(&Sample{}).WhereFilter(db.Or(
+		db.Field("Value", "==", 1),
+		db.Field("Value", "==", 2),
+	)).OrderBy("Name").Return(ctx)
+	assert.NotNil(err)
+}
+
+func TestQueryReturnPage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	rand := identifier.RandomString(8)
+
+	for i := 0; i < 7; i++ {
+		sample := &Sample{Name: "test-query-page", Value: i%2 + 1, Tag: rand}
+		err := client.Set(ctx, sample)
+		assert.Nil(err)
+		defer client.Delete(ctx, sample)
+	}
+
+	for _, encrypt := range []bool{false, true} {
+		ids := []string{}
+		token := ""
+		for i := 0; i < 5; i++ {
+			list, next, err := client.Query(&Sample{}).Where("Tag", "==", rand).OrderBy("Value").Limit(3).Paginate(token, encrypt).ReturnPage(ctx)
+			assert.Nil(err)
+			for _, obj := range list {
+				ids = append(ids, obj.ID())
+			}
+			if next == "" {
+				break
+			}
+			token = next
+		}
+		assert.Len(ids, 7)
+		all, err := client.Query(&Sample{}).Where("Tag", "==", rand).OrderBy("Value").OrderBy(firestore.DocumentID).ReturnID(ctx)
+		assert.Nil(err)
+		assert.Equal(all, ids)
+	}
+
+	// invalid token
+	_, _, err := client.Query(&Sample{}).Paginate("invalid", false).ReturnPage(ctx)
+	assert.NotNil(err)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/piyuo/libsrv/db"
 	"github.com/piyuo/libsrv/gaccount"
+	"golang.org/x/oauth2/google"
 )
 
 type PlainObject struct {
@@ -61,6 +62,56 @@ func (c *SampleDeleteAll) Collection() string {
 	return "SampleDeleteAll"
 }
 
+// SampleSoftDelete use soft delete
+//
+type SampleSoftDelete struct {
+	db.Model
+	Name string `firestore:"Name,omitempty"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *SampleSoftDelete) Factory() db.Object {
+	return &SampleSoftDelete{}
+}
+
+// Collection is name in the database
+//
+func (c *SampleSoftDelete) Collection() string {
+	return "SampleSoftDelete"
+}
+
+// SoftDelete enable soft delete
+//
+func (c *SampleSoftDelete) SoftDelete() bool {
+	return true
+}
+
+// SampleVersioned use optimistic concurrency
+//
+type SampleVersioned struct {
+	db.Model
+	Name string `firestore:"Name,omitempty"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *SampleVersioned) Factory() db.Object {
+	return &SampleVersioned{}
+}
+
+// Collection is name in the database
+//
+func (c *SampleVersioned) Collection() string {
+	return "SampleVersioned"
+}
+
+// Versioned enable optimistic concurrency
+//
+func (c *SampleVersioned) Versioned() bool {
+	return true
+}
+
 // Sample for test
 //
 type Sample struct {
@@ -93,16 +144,20 @@ func (c *Sample) Collection() string {
 
 var sampleClientInstance *ClientFirestore
 
-// sample client create db client use for test, it will keep client instance to resuse, recreate new instance if client is close
+// sample client create db client use for test, it will keep client instance to resuse, recreate new instance if client is close. client connect to emulator if FIRESTORE_EMULATOR_HOST is set
 //
 func sampleClient() db.Client {
 	if sampleClientInstance != nil && !sampleClientInstance.IsClose() {
 		return sampleClientInstance
 	}
 	ctx := context.Background()
-	cred, err := gaccount.GlobalCredential(ctx)
-	if err != nil {
-		return nil
+	var cred *google.Credentials
+	if EmulatorHost() == "" {
+		var err error
+		cred, err = gaccount.GlobalCredential(ctx)
+		if err != nil {
+			return nil
+		}
 	}
 	client, err := NewClient(ctx, cred)
 	if err != nil {
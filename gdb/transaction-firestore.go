@@ -3,6 +3,7 @@ package gdb
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/piyuo/libsrv/db"
@@ -23,6 +24,52 @@ type TransactionFirestore struct {
 	//tx is curenet transacton, it is nil if not in transaction
 	//
 	tx *firestore.Transaction
+
+	// committed is function to run after transaction commit, like increment object version
+	//
+	committed []func()
+}
+
+// nextVersion read stored version and return next version, return db.ErrConflict if stored version not equal to object version
+//
+func (c *TransactionFirestore) nextVersion(docRef *firestore.DocumentRef, obj db.Object) (int64, error) {
+	snapshot, err := c.tx.Get(docRef)
+	stored := int64(0)
+	if snapshot != nil && snapshot.Exists() {
+		if value, err := snapshot.DataAt(db.VersionField); err == nil {
+			stored, _ = value.(int64)
+		}
+	} else if snapshot == nil && err != nil {
+		return 0, errors.Wrap(err, "read version")
+	}
+	version := obj.Version()
+	if stored != version {
+		return 0, errors.Wrapf(db.ErrConflict, "%v-%v version %v, stored %v", obj.Collection(), docRef.ID, version, stored)
+	}
+	c.committed = append(c.committed, func() {
+		obj.SetVersion(version + 1)
+	})
+	return version + 1, nil
+}
+
+// createFields return fields to update, document created for object use soft delete will have zero delete time, so query can find it
+//
+func (c *TransactionFirestore) createFields(docRef *firestore.DocumentRef, obj db.Object, fields map[string]interface{}) (map[string]interface{}, error) {
+	if _, found := fields[db.DeleteTimeField]; found || !obj.SoftDelete() {
+		return fields, nil
+	}
+	snapshot, err := c.tx.Get(docRef)
+	if snapshot != nil && snapshot.Exists() {
+		return fields, nil
+	}
+	if snapshot == nil && err != nil {
+		return nil, errors.Wrap(err, "read document")
+	}
+	created := map[string]interface{}{db.DeleteTimeField: time.Time{}}
+	for key, value := range fields {
+		created[key] = value
+	}
+	return created, nil
 }
 
 // Get data object from table, return nil if object does not exist
@@ -66,10 +113,7 @@ func (c *TransactionFirestore) List(ctx context.Context, obj db.Object, max int)
 	if err := db.AssertObject(ctx, obj, false); err != nil {
 		return nil, err
 	}
-	collectionRef := c.client.getCollectionRef(obj.Collection())
-	iter := c.tx.Documents(collectionRef.Query.Limit(max))
-	defer iter.Stop()
-	return iterObjects(obj, iter)
+	return c.Query(obj).Limit(max).Return(ctx)
 }
 
 // Select return object field from data store, return nil if object does not exist
@@ -93,14 +137,14 @@ func (c *TransactionFirestore) Select(ctx context.Context, obj db.Object, id, fi
 //	c.Query(ctx, &Sample{}).Return(ctx)
 //
 func (c *TransactionFirestore) Query(obj db.Object) db.Query {
-	return (&QueryFirestore{
+	return db.ExcludeDeleted((&QueryFirestore{
 		BaseQuery: db.BaseQuery{
 			QueryTransaction: c,
 			QueryObject:      obj,
 		},
 		query:  c.client.getCollectionRef(obj.Collection()).Query,
 		client: c.client,
-	}).Limit(20)
+	}).defaultLimit(20), obj)
 }
 
 // Set object into table, If the document not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data, if object does not have id, it will created using UUID
@@ -113,7 +157,21 @@ func (c *TransactionFirestore) Set(ctx context.Context, obj db.Object) error {
 	}
 	c.client.BaseClient.BeforeSet(ctx, obj)
 	docRef := c.client.refFromObj(ctx, obj)
-	err := c.tx.Set(docRef, obj)
+	if !obj.Versioned() {
+		if err := c.tx.Set(docRef, obj); err != nil {
+			return errors.Wrapf(err, "tx set doc %v-%v", obj.Collection(), obj.ID())
+		}
+		return nil
+	}
+
+	next, err := c.nextVersion(docRef, obj)
+	if err != nil {
+		return errors.Wrapf(err, "tx set doc %v-%v", obj.Collection(), obj.ID())
+	}
+	version := obj.Version()
+	obj.SetVersion(next) // object is encoded when set, keep old version until transaction commit
+	err = c.tx.Set(docRef, obj)
+	obj.SetVersion(version)
 	if err != nil {
 		return errors.Wrapf(err, "tx set doc %v-%v", obj.Collection(), obj.ID())
 	}
@@ -131,7 +189,23 @@ func (c *TransactionFirestore) Update(ctx context.Context, obj db.Object, fields
 		return err
 	}
 	docRef := c.client.getDocRef(obj.Collection(), obj.ID())
-	err := c.tx.Set(docRef, fields, firestore.MergeAll)
+	fields, err := c.createFields(docRef, obj, fields)
+	if err != nil {
+		return errors.Wrapf(err, "tx update field %v-%v", obj.Collection(), obj.ID())
+	}
+	if obj.Versioned() {
+		next, err := c.nextVersion(docRef, obj)
+		if err != nil {
+			return errors.Wrapf(err, "tx update field %v-%v", obj.Collection(), obj.ID())
+		}
+		versioned := map[string]interface{}{}
+		for key, value := range fields {
+			versioned[key] = value
+		}
+		versioned[db.VersionField] = next
+		fields = versioned
+	}
+	err = c.tx.Set(docRef, fields, firestore.MergeAll)
 	if err != nil {
 		fieldStr := mapping.ToString(fields)
 		return errors.Wrapf(err, "tx update field %v %v-%v"+fieldStr, obj.Collection(), obj.ID())
@@ -157,7 +231,7 @@ func (c *TransactionFirestore) Increment(ctx context.Context, obj db.Object, fie
 	return nil
 }
 
-// Delete object, no error if id not exist
+// Delete object, no error if id not exist. object use soft delete will only set delete time
 //
 //	Delete(ctx, sample)
 //
@@ -165,10 +239,24 @@ func (c *TransactionFirestore) Delete(ctx context.Context, obj db.Object) error
 	if err := db.AssertObject(ctx, obj, true); err != nil {
 		return err
 	}
+	collection, id := obj.Collection(), obj.ID()
 	docRef := c.client.objDeleteRef(obj)
-	err := c.tx.Delete(docRef)
+	var err error
+	if obj.SoftDelete() {
+		var snapshot *firestore.DocumentSnapshot
+		snapshot, err = c.tx.Get(docRef)
+		if snapshot == nil || !snapshot.Exists() {
+			if err != nil && !isNotFound(err) {
+				return errors.Wrapf(err, "tx get %v-%v", collection, id)
+			}
+			return nil
+		}
+		err = c.tx.Update(docRef, softDeleteUpdates())
+	} else {
+		err = c.tx.Delete(docRef)
+	}
 	if err != nil {
-		return errors.Wrapf(err, "tx delete %v-%v", obj.Collection(), obj.ID())
+		return errors.Wrapf(err, "tx delete %v-%v", collection, id)
 	}
 	return nil
 }
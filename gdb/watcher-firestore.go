@@ -0,0 +1,270 @@
+package gdb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// minResumeDelay is delay before first resume after listener interrupted
+//
+const minResumeDelay = 500 * time.Millisecond
+
+// maxResumeDelay is max delay between resume
+//
+const maxResumeDelay = 30 * time.Second
+
+// listener read documents from firestore snapshot listener
+//
+type listener interface {
+
+	// next block until documents changed, return all documents currently watched
+	//
+	next() ([]*firestore.DocumentSnapshot, error)
+
+	// stop listener
+	//
+	stop()
+}
+
+// listenFunc open snapshot listener
+//
+type listenFunc func(ctx context.Context) listener
+
+// WatcherFirestore deliver document change using firestore snapshot listener, it compare documents with previous result so listener can resume without deliver duplicate change
+//
+type WatcherFirestore struct {
+	db.Watcher
+
+	// ctx is context used to listen, watcher stop when it canceled
+	//
+	ctx context.Context
+
+	// cancel stop watcher
+	//
+	cancel context.CancelFunc
+
+	// obj is object used to create changed object
+	//
+	obj db.Object
+
+	// listen open snapshot listener
+	//
+	listen listenFunc
+
+	// known is update time of documents deliver to watcher, key is document id
+	//
+	known map[string]time.Time
+
+	// changes deliver change to caller
+	//
+	changes chan *db.Change
+
+	// err is error stop watcher
+	//
+	err error
+}
+
+// newWatcher create watcher and start watch, return stopped watcher if err is not nil
+//
+func newWatcher(ctx context.Context, obj db.Object, listen listenFunc, err error) *WatcherFirestore {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &WatcherFirestore{
+		ctx:     ctx,
+		cancel:  cancel,
+		obj:     obj,
+		listen:  listen,
+		known:   map[string]time.Time{},
+		changes: make(chan *db.Change),
+	}
+	if err != nil {
+		c.err = err
+		close(c.changes)
+		return c
+	}
+	go c.run()
+	return c
+}
+
+// run listen documents and deliver change, listener will be reopen if it interrupted by retryable error
+//
+func (c *WatcherFirestore) run() {
+	defer close(c.changes)
+	delay := minResumeDelay
+	for {
+		l := c.listen(c.ctx)
+		err := c.receive(l)
+		l.stop()
+		if c.ctx.Err() != nil {
+			return
+		}
+		if !isRetryable(err) {
+			c.err = err
+			return
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxResumeDelay {
+			delay = maxResumeDelay
+		}
+	}
+}
+
+// receive deliver change from listener until error happen
+//
+func (c *WatcherFirestore) receive(l listener) error {
+	for {
+		snapshots, err := l.next()
+		if err != nil {
+			return err
+		}
+		if err := c.deliver(snapshots); err != nil {
+			return err
+		}
+	}
+}
+
+// deliver compare documents with known documents and send change
+//
+func (c *WatcherFirestore) deliver(snapshots []*firestore.DocumentSnapshot) error {
+	changes := []*db.Change{}
+	current := map[string]time.Time{}
+	for _, snapshot := range snapshots {
+		id := snapshot.Ref.ID
+		current[id] = snapshot.UpdateTime
+		known, found := c.known[id]
+		if found && known.Equal(snapshot.UpdateTime) {
+			continue
+		}
+		obj, err := snapshotToObject(c.obj.Factory(), snapshot.Ref, snapshot, nil)
+		if err != nil {
+			return errors.Wrap(err, "watch")
+		}
+		changeType := db.ChangeModified
+		if !found {
+			changeType = db.ChangeAdded
+		}
+		changes = append(changes, &db.Change{Type: changeType, ID: id, Object: obj})
+	}
+	removed := []string{}
+	for id := range c.known {
+		if _, found := current[id]; !found {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+	for _, id := range removed {
+		changes = append(changes, &db.Change{Type: db.ChangeRemoved, ID: id})
+	}
+	c.known = current
+
+	for _, change := range changes {
+		select {
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case c.changes <- change:
+		}
+	}
+	return nil
+}
+
+// isRetryable return true if listener interrupted by temporary error, listener may return iterator.Done on network issue
+//
+func isRetryable(err error) bool {
+	if errors.Is(err, iterator.Done) {
+		return true
+	}
+	st, ok := status.FromError(errors.Cause(err))
+	if !ok {
+		return false
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.Internal, codes.Unknown, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// Changes return channel deliver object change, channel will be closed when watcher stop
+//
+//	for change := range watcher.Changes() {
+//	}
+//
+func (c *WatcherFirestore) Changes() <-chan *db.Change {
+	return c.changes
+}
+
+// Err return error which stop watcher, return nil if watcher stop by Close() or context canceled. only valid after Changes() channel closed
+//
+//	err := watcher.Err()
+//
+func (c *WatcherFirestore) Err() error {
+	return c.err
+}
+
+// Close stop watcher, it is safe to call Close() multiple times
+//
+//	defer watcher.Close()
+//
+func (c *WatcherFirestore) Close() {
+	c.cancel()
+}
+
+// queryListener read documents from query snapshot listener
+//
+type queryListener struct {
+	iter *firestore.QuerySnapshotIterator
+}
+
+// next block until query result changed, return all documents match query
+//
+func (c *queryListener) next() ([]*firestore.DocumentSnapshot, error) {
+	snapshot, err := c.iter.Next()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.Documents.GetAll()
+}
+
+// stop listener
+//
+func (c *queryListener) stop() {
+	c.iter.Stop()
+}
+
+// docListener read document from document snapshot listener
+//
+type docListener struct {
+	obj  db.Object
+	iter *firestore.DocumentSnapshotIterator
+}
+
+// next block until document changed, return document in list, return empty list if document not exist or soft deleted
+//
+func (c *docListener) next() ([]*firestore.DocumentSnapshot, error) {
+	snapshot, err := c.iter.Next()
+	if err != nil {
+		return nil, err
+	}
+	if !snapshot.Exists() || isDeleted(c.obj, snapshot) {
+		return []*firestore.DocumentSnapshot{}, nil
+	}
+	return []*firestore.DocumentSnapshot{snapshot}, nil
+}
+
+// stop listener
+//
+func (c *docListener) stop() {
+	c.iter.Stop()
+}
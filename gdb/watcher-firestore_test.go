@@ -0,0 +1,123 @@
+package gdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/identifier"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// nextChange return next change from watcher, return nil if no change in time or watcher stopped
+//
+func nextChange(watcher db.Watcher) *db.Change {
+	select {
+	case change := <-watcher.Changes():
+		return change
+	case <-time.After(10 * time.Second):
+		return nil
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	rand := identifier.RandomString(8)
+	sample := &Sample{Name: "test-watch-" + rand, Tag: rand}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	defer client.Query(&Sample{}).Where("Tag", "==", rand).Delete(ctx, 100)
+
+	queryWatcher := client.Query(&Sample{}).Where("Tag", "==", rand).Watch(ctx)
+	defer queryWatcher.Close()
+	getWatcher := client.Watch(ctx, &Sample{}, sample.ID())
+	defer getWatcher.Close()
+
+	for _, watcher := range []db.Watcher{queryWatcher, getWatcher} {
+		change := nextChange(watcher)
+		assert.Equal(db.ChangeAdded, change.Type)
+		assert.Equal(sample.ID(), change.ID)
+	}
+
+	err = client.Update(ctx, sample, map[string]interface{}{"Value": 2})
+	assert.Nil(err)
+	for _, watcher := range []db.Watcher{queryWatcher, getWatcher} {
+		change := nextChange(watcher)
+		assert.Equal(db.ChangeModified, change.Type)
+		assert.Equal(2, change.Object.(*Sample).Value)
+	}
+
+	id := sample.ID()
+	err = client.Delete(ctx, sample)
+	assert.Nil(err)
+	for _, watcher := range []db.Watcher{queryWatcher, getWatcher} {
+		change := nextChange(watcher)
+		assert.Equal(db.ChangeRemoved, change.Type)
+		assert.Equal(id, change.ID)
+		assert.Nil(change.Object)
+	}
+
+	queryWatcher.Close()
+	for range queryWatcher.Changes() {
+	}
+	assert.Nil(queryWatcher.Err())
+}
+
+func TestWatchMoreThanDefaultLimit(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	rand := identifier.RandomString(8)
+	defer client.Query(&Sample{}).Where("Tag", "==", rand).Delete(ctx, 100)
+	err := client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		for i := 0; i < 25; i++ {
+			batch.Set(ctx, &Sample{Name: "test-watch-many-" + rand, Tag: rand, Value: i})
+		}
+		return nil
+	})
+	assert.Nil(err)
+
+	watcher := client.Query(&Sample{}).Where("Tag", "==", rand).Watch(ctx)
+	defer watcher.Close()
+	for i := 0; i < 25; i++ {
+		change := nextChange(watcher)
+		assert.NotNil(change)
+		assert.Equal(db.ChangeAdded, change.Type)
+	}
+}
+
+func TestWatchError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	watcher := client.Query(&Sample{}).WhereFilter(db.Or(db.Field("Tag", "==", "a"), db.Field("Tag", "==", "b"))).Watch(ctx)
+	for range watcher.Changes() {
+	}
+	assert.NotNil(watcher.Err())
+
+	watcher = client.Watch(ctx, &Sample{}, "")
+	for range watcher.Changes() {
+	}
+	assert.NotNil(watcher.Err())
+}
+
+func TestWatchRetryable(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.True(isRetryable(status.Error(codes.Unavailable, "unavailable")))
+	assert.True(isRetryable(errors.Wrap(status.Error(codes.Internal, "internal"), "wrap")))
+	assert.True(isRetryable(iterator.Done))
+	assert.False(isRetryable(status.Error(codes.PermissionDenied, "denied")))
+	assert.False(isRetryable(errors.New("not grpc")))
+}
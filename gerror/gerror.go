@@ -8,6 +8,7 @@ import (
 	"github.com/piyuo/libsrv/gaccount"
 	"github.com/piyuo/libsrv/log/logger"
 	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
 )
 
@@ -20,7 +21,14 @@ func NewClient(ctx context.Context) (*errorreporting.Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newClient(ctx, cred)
+}
 
+// newClient return google error reporting client use credential
+//
+//	client, err := newClient(ctx, cred)
+//
+func newClient(ctx context.Context, cred *google.Credentials) (*errorreporting.Client, error) {
 	client, err := errorreporting.NewClient(ctx,
 		cred.ProjectID,
 		errorreporting.Config{
@@ -38,54 +46,26 @@ func NewClient(ctx context.Context) (*errorreporting.Client, error) {
 
 // close error reporting client
 //
-//	defer close(ctx,client)
+//	defer closeClient(ctx, client)
 //
-func close(ctx context.Context, client *errorreporting.Client) {
+func closeClient(ctx context.Context, client *errorreporting.Client) {
 	if err := client.Close(); err != nil {
 		logger.Error(ctx, errors.Wrap(err, "error report close").Error())
 	}
 }
 
-// Write error to google cloud
+// Write queue error to report to google cloud in background, it never block. call Shutdown() before program exit to send queued report
 //
 //	Write(ctx, "nil pointer",  stack)
 //
 func Write(ctx context.Context, message, stack string) {
-	if err := write(ctx, message, stack); err != nil {
-		logger.Error(ctx, errors.Wrap(err, "error report create").Error())
-	}
-}
-
-// write error to google cloud
-//
-//	write(ctx, "nil pointer", stack)
-//
-func write(ctx context.Context, message, stack string) error {
-	client, err := NewClient(ctx)
-	if err != nil {
-		return err
-	}
-	defer close(ctx, client)
-
 	//	stack format like
 	//	at firstLine (a.js:3)
 	//	at secondLine (b.js:3)
-
-	e := errors.New(message)
-	user := env.GetUserID(ctx)
-
-	if stack == "" {
-		client.Report(errorreporting.Entry{
-			Error: e, User: user,
-			Req: env.GetRequest(ctx),
-		})
-		return nil
+	r, err := defaultReporter(ctx)
+	if err != nil {
+		logger.Error(ctx, errors.Wrap(err, "error report create").Error())
+		return
 	}
-
-	client.Report(errorreporting.Entry{
-		Error: e, User: user,
-		Stack: []byte(stack),
-		Req:   env.GetRequest(ctx),
-	})
-	return nil
+	r.Report(ctx, message, stack)
 }
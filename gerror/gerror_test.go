@@ -16,7 +16,7 @@ func TestGerror(t *testing.T) {
 	client, err := NewClient(ctx)
 	assert.Nil(err)
 	assert.NotNil(client)
-	defer close(ctx, client)
+	defer closeClient(ctx, client)
 
 	ctx = env.SetUserID(ctx, "user1")
 	env.AppName = "TestGerror"
@@ -27,3 +27,10 @@ func TestGerror(t *testing.T) {
 	// empty stack
 	Write(ctx, "no stack", "")
 }
+
+func TestTraceMessage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.Equal("hi", traceMessage("hi", ""))
+	assert.Equal("hi [trace trace1]", traceMessage("hi", "trace1"))
+}
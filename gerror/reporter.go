@@ -0,0 +1,356 @@
+package gerror
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/piyuo/libsrv/env"
+	"github.com/piyuo/libsrv/log/logger"
+	"github.com/pkg/errors"
+)
+
+// ReporterOption define how reporter queue, batch, deduplicate and sample report, zero value use default
+//
+type ReporterOption struct {
+
+	// QueueSize is max report waiting to send, report is dropped when queue is full, default is 1000
+	//
+	QueueSize int
+
+	// BatchSize is max report in a batch, default is 20
+	//
+	BatchSize int
+
+	// FlushInterval is how often queued report is sent, default is 1 second
+	//
+	FlushInterval time.Duration
+
+	// DedupWindow is how long identical report is suppressed after it is reported, default is 1 minute, -1 mean no deduplication
+	//
+	DedupWindow time.Duration
+
+	// SampleRate is fraction of report to send from 0 to 1, default is 1 mean send all
+	//
+	SampleRate float64
+}
+
+// withDefault return option with default value
+//
+func (c ReporterOption) withDefault() ReporterOption {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1000
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 20
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
+	}
+	if c.DedupWindow == 0 {
+		c.DedupWindow = time.Minute
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		c.SampleRate = 1
+	}
+	return c
+}
+
+// dedupCleanupSize is seen report count before old seen report is removed
+//
+const dedupCleanupSize = 1000
+
+// seenReport is report recently sent
+//
+type seenReport struct {
+
+	// last is time report last sent
+	//
+	last time.Time
+
+	// suppressed is duplicate report count since last sent
+	//
+	suppressed int
+}
+
+// Reporter send report in background, report is queued, batched, deduplicated and sampled so Report() never block
+//
+//	transport, err := NewGoogleTransport(ctx)
+//	reporter := NewReporter(transport, ReporterOption{})
+//	defer reporter.Close(ctx)
+//	reporter.Report(ctx, "nil pointer", stack)
+//
+type Reporter struct {
+
+	// transport send report
+	//
+	transport Transport
+
+	// option is reporter option
+	//
+	option ReporterOption
+
+	// queue is report waiting to send
+	//
+	queue chan *Report
+
+	// flush request send queued report, result is send to reply channel
+	//
+	flush chan chan error
+
+	// done is closed when background loop end
+	//
+	done chan struct{}
+
+	// mutex protect seen and closed
+	//
+	mutex sync.Mutex
+
+	// seen is report recently sent by stack or message
+	//
+	seen map[string]*seenReport
+
+	// closed is true when reporter is closed
+	//
+	closed bool
+
+	// dropped is report count dropped because queue is full
+	//
+	dropped int64
+}
+
+// NewReporter create reporter send report through transport in background
+//
+//	reporter := NewReporter(NewLocalTransport(), ReporterOption{DedupWindow: time.Minute})
+//
+func NewReporter(transport Transport, option ReporterOption) *Reporter {
+	option = option.withDefault()
+	c := &Reporter{
+		transport: transport,
+		option:    option,
+		queue:     make(chan *Report, option.QueueSize),
+		flush:     make(chan chan error),
+		done:      make(chan struct{}),
+		seen:      map[string]*seenReport{},
+	}
+	go c.run()
+	return c
+}
+
+// Report queue error report, it never block. report is dropped if queue is full, identical report in dedup window is suppressed and counted in next report
+//
+//	reporter.Report(ctx, "nil pointer", stack)
+//
+func (c *Reporter) Report(ctx context.Context, message, stack string) {
+	if c.option.SampleRate < 1 && rand.Float64() >= c.option.SampleRate {
+		return
+	}
+	report := &Report{
+		Time:    time.Now(),
+		Message: message,
+		Stack:   stack,
+		User:    env.GetUserID(ctx),
+		Trace:   env.GetTrace(ctx),
+		Request: env.GetRequest(ctx),
+		Count:   1,
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	if c.option.DedupWindow > 0 {
+		key := stack
+		if key == "" {
+			key = message
+		}
+		if s := c.seen[key]; s != nil {
+			if report.Time.Sub(s.last) < c.option.DedupWindow {
+				s.suppressed++
+				return
+			}
+			report.Count += s.suppressed
+		}
+		c.seen[key] = &seenReport{last: report.Time}
+		if len(c.seen) > dedupCleanupSize {
+			c.cleanupSeen(report.Time)
+		}
+	}
+	select {
+	case c.queue <- report:
+	default:
+		atomic.AddInt64(&c.dropped, 1)
+	}
+}
+
+// cleanupSeen remove seen report out of dedup window, caller must hold mutex
+//
+func (c *Reporter) cleanupSeen(now time.Time) {
+	for key, s := range c.seen {
+		if now.Sub(s.last) >= c.option.DedupWindow {
+			delete(c.seen, key)
+		}
+	}
+}
+
+// Dropped return report count dropped because queue is full
+//
+//	dropped := reporter.Dropped()
+//
+func (c *Reporter) Dropped() int64 {
+	return atomic.LoadInt64(&c.dropped)
+}
+
+// Flush send all queued report and wait until sent, return error if transport failed or ctx is done
+//
+//	err := reporter.Flush(ctx)
+//
+func (c *Reporter) Flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case c.flush <- reply:
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stop accept report, send all queued report and close transport, return error if ctx is done before all report sent
+//
+//	err := reporter.Close(ctx)
+//
+func (c *Reporter) Close(ctx context.Context) error {
+	c.mutex.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := c.transport.Close(); err != nil {
+		return errors.Wrap(err, "close transport")
+	}
+	return nil
+}
+
+// run send queued report in batch until queue is closed
+//
+func (c *Reporter) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.option.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*Report, 0, c.option.BatchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := c.transport.Send(context.Background(), batch)
+		if err != nil {
+			logger.Error(context.Background(), errors.Wrap(err, "error report send").Error())
+		}
+		batch = make([]*Report, 0, c.option.BatchSize)
+		return err
+	}
+
+	for {
+		select {
+		case report, ok := <-c.queue:
+			if !ok {
+				send()
+				return
+			}
+			batch = append(batch, report)
+			if len(batch) >= c.option.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case reply := <-c.flush:
+			var err error
+			for drained := false; !drained; {
+				select {
+				case report, ok := <-c.queue:
+					if !ok {
+						drained = true
+						break
+					}
+					batch = append(batch, report)
+					if len(batch) >= c.option.BatchSize {
+						if sendErr := send(); sendErr != nil {
+							err = sendErr
+						}
+					}
+				default:
+					drained = true
+				}
+			}
+			if sendErr := send(); sendErr != nil {
+				err = sendErr
+			}
+			reply <- err
+		}
+	}
+}
+
+// reporter is default reporter used by Write()
+//
+var reporter *Reporter
+
+// reporterMutex protect reporter
+//
+var reporterMutex sync.Mutex
+
+// Use set default reporter used by Write(), previous reporter is not closed
+//
+//	gerror.Use(gerror.NewReporter(gerror.NewLocalTransport(), gerror.ReporterOption{}))
+//
+func Use(r *Reporter) {
+	reporterMutex.Lock()
+	defer reporterMutex.Unlock()
+	reporter = r
+}
+
+// defaultReporter return default reporter, create one send report to google cloud if not exist
+//
+func defaultReporter(ctx context.Context) (*Reporter, error) {
+	reporterMutex.Lock()
+	defer reporterMutex.Unlock()
+	if reporter == nil {
+		transport, err := NewGoogleTransport(ctx)
+		if err != nil {
+			return nil, err
+		}
+		reporter = NewReporter(transport, ReporterOption{})
+	}
+	return reporter, nil
+}
+
+// Shutdown send all queued report and close default reporter, call it before program exit
+//
+//	err := Shutdown(ctx)
+//
+func Shutdown(ctx context.Context) error {
+	reporterMutex.Lock()
+	r := reporter
+	reporter = nil
+	reporterMutex.Unlock()
+	if r == nil {
+		return nil
+	}
+	return r.Close(ctx)
+}
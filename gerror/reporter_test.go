@@ -0,0 +1,146 @@
+package gerror
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/env"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReporterBatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	transport := NewLocalTransport()
+	reporter := NewReporter(transport, ReporterOption{BatchSize: 2, FlushInterval: time.Hour})
+
+	ctx = env.SetUserID(ctx, "user1")
+	ctx = env.SetTrace(ctx, "trace1")
+	for i := 0; i < 5; i++ {
+		reporter.Report(ctx, "error"+strconv.Itoa(i), "")
+	}
+	assert.Nil(reporter.Flush(ctx))
+	reports := transport.Reports()
+	assert.Len(reports, 5)
+	assert.Equal(3, transport.Batches())
+	assert.Equal("user1", reports[0].User)
+	assert.Equal("trace1", reports[0].Trace)
+	assert.Equal(1, reports[0].Count)
+	assert.Nil(reporter.Close(ctx))
+}
+
+func TestReporterDedup(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	transport := NewLocalTransport()
+	reporter := NewReporter(transport, ReporterOption{DedupWindow: 100 * time.Millisecond})
+	defer reporter.Close(ctx)
+
+	stack := "at firstLine (a.js:3)"
+	for i := 0; i < 3; i++ {
+		reporter.Report(ctx, "hi", stack)
+	}
+	reporter.Report(ctx, "other", "")
+	assert.Nil(reporter.Flush(ctx))
+	reports := transport.Reports()
+	assert.Len(reports, 2)
+
+	// suppressed duplicate is counted in next report
+	time.Sleep(110 * time.Millisecond)
+	reporter.Report(ctx, "hi", stack)
+	assert.Nil(reporter.Flush(ctx))
+	reports = transport.Reports()
+	assert.Len(reports, 3)
+	assert.Equal(3, reports[2].Count)
+	assert.Equal("hi [repeated 3 times]", reportMessage(reports[2]))
+
+	// no deduplication
+	transport2 := NewLocalTransport()
+	reporter2 := NewReporter(transport2, ReporterOption{DedupWindow: -1})
+	reporter2.Report(ctx, "hi", stack)
+	reporter2.Report(ctx, "hi", stack)
+	assert.Nil(reporter2.Close(ctx))
+	assert.Len(transport2.Reports(), 2)
+}
+
+func TestReporterSample(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	transport := NewLocalTransport()
+	reporter := NewReporter(transport, ReporterOption{SampleRate: 0.000001, DedupWindow: -1})
+	for i := 0; i < 100; i++ {
+		reporter.Report(ctx, "hi", "")
+	}
+	assert.Nil(reporter.Close(ctx))
+	assert.Less(len(transport.Reports()), 100)
+}
+
+func TestReporterQueueFull(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	transport := NewLocalTransport()
+	reporter := NewReporter(transport, ReporterOption{QueueSize: 1, BatchSize: 1, FlushInterval: time.Hour, DedupWindow: -1})
+
+	// hold transport so background loop block on send and can not drain queue
+	transport.mutex.Lock()
+	reporter.Report(ctx, "1", "")
+	reporter.Report(ctx, "2", "")
+	reporter.Report(ctx, "3", "")
+	transport.mutex.Unlock()
+
+	assert.True(reporter.Dropped() > 0)
+	assert.Nil(reporter.Close(ctx))
+	assert.Equal(int64(3), reporter.Dropped()+int64(len(transport.Reports())))
+}
+
+func TestReporterClose(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	transport := NewLocalTransport()
+	reporter := NewReporter(transport, ReporterOption{FlushInterval: time.Hour})
+	reporter.Report(ctx, "hi", "")
+	assert.Nil(reporter.Close(ctx))
+	assert.Len(transport.Reports(), 1)
+
+	// report after close is ignored
+	reporter.Report(ctx, "after close", "")
+	assert.Nil(reporter.Flush(ctx))
+	assert.Nil(reporter.Close(ctx))
+	assert.Len(transport.Reports(), 1)
+}
+
+func TestReporterTransportError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	transport := NewLocalTransport()
+	transport.Err = errors.New("service unavailable")
+	reporter := NewReporter(transport, ReporterOption{})
+	defer reporter.Close(ctx)
+
+	reporter.Report(ctx, "hi", "")
+	err := reporter.Flush(ctx)
+	assert.NotNil(err)
+	assert.Empty(transport.Reports())
+}
+
+func TestReporterDefault(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	transport := NewLocalTransport()
+	Use(NewReporter(transport, ReporterOption{}))
+	defer Use(nil)
+
+	Write(ctx, "hi", "")
+	assert.Nil(Shutdown(ctx))
+	assert.Len(transport.Reports(), 1)
+	assert.Nil(Shutdown(ctx))
+}
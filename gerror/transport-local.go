@@ -0,0 +1,79 @@
+package gerror
+
+import (
+	"context"
+	"sync"
+)
+
+// LocalTransport keep report in memory, use it in test instead of google cloud error reporting
+//
+type LocalTransport struct {
+	Transport
+
+	// Err is error return by Send, use it to simulate service failure
+	//
+	Err error
+
+	// mutex protect reports
+	//
+	mutex sync.Mutex
+
+	// reports is report sent
+	//
+	reports []*Report
+
+	// batches is batch count sent
+	//
+	batches int
+}
+
+// NewLocalTransport create transport keep report in memory
+//
+//	transport := NewLocalTransport()
+//
+func NewLocalTransport() *LocalTransport {
+	return &LocalTransport{}
+}
+
+// Send keep batch of report in memory, return Err if it is set
+//
+//	err := transport.Send(ctx, reports)
+//
+func (c *LocalTransport) Send(ctx context.Context, reports []*Report) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.Err != nil {
+		return c.Err
+	}
+	c.reports = append(c.reports, reports...)
+	c.batches++
+	return nil
+}
+
+// Close do nothing
+//
+//	err := transport.Close()
+//
+func (c *LocalTransport) Close() error {
+	return nil
+}
+
+// Reports return report sent
+//
+//	reports := transport.Reports()
+//
+func (c *LocalTransport) Reports() []*Report {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*Report(nil), c.reports...)
+}
+
+// Batches return batch count sent
+//
+//	batches := transport.Batches()
+//
+func (c *LocalTransport) Batches() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.batches
+}
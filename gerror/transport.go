@@ -0,0 +1,161 @@
+package gerror
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/errorreporting"
+	"github.com/piyuo/libsrv/gaccount"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+)
+
+// Report is error to report
+//
+type Report struct {
+
+	// Time is time error happen
+	//
+	Time time.Time
+
+	// Message is error message
+	//
+	Message string
+
+	// Stack is stack trace, format like "at firstLine (a.js:3)\nat secondLine (b.js:3)"
+	//
+	Stack string
+
+	// User is user id when error happen
+	//
+	User string
+
+	// Trace is trace id of request when error happen
+	//
+	Trace string
+
+	// Request is request when error happen, may be nil
+	//
+	Request *http.Request
+
+	// Count is how many time the same error happen, include suppressed duplicate
+	//
+	Count int
+}
+
+// Transport send batch of report to error reporting service
+//
+type Transport interface {
+
+	// Send batch of report
+	//
+	//	err := transport.Send(ctx, reports)
+	//
+	Send(ctx context.Context, reports []*Report) error
+
+	// Close transport
+	//
+	//	err := transport.Close()
+	//
+	Close() error
+}
+
+// GoogleTransport send report to google cloud error reporting, client is created on first send and reused
+//
+type GoogleTransport struct {
+	Transport
+
+	// mutex protect client
+	//
+	mutex sync.Mutex
+
+	// cred is credential used to create client
+	//
+	cred *google.Credentials
+
+	// client is error reporting client
+	//
+	client *errorreporting.Client
+}
+
+// NewGoogleTransport create transport send report to google cloud error reporting, credential is resolved here so background send never read global credential
+//
+//	transport, err := NewGoogleTransport(ctx)
+//
+func NewGoogleTransport(ctx context.Context) (*GoogleTransport, error) {
+	cred, err := gaccount.GlobalCredential(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get credential")
+	}
+	return &GoogleTransport{cred: cred}, nil
+}
+
+// Send batch of report to google cloud error reporting
+//
+//	err := transport.Send(ctx, reports)
+//
+func (c *GoogleTransport) Send(ctx context.Context, reports []*Report) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == nil {
+		client, err := newClient(ctx, c.cred)
+		if err != nil {
+			return errors.Wrap(err, "new client")
+		}
+		c.client = client
+	}
+	for _, report := range reports {
+		entry := errorreporting.Entry{
+			Error: errors.New(reportMessage(report)),
+			User:  report.User,
+			Req:   report.Request,
+		}
+		if report.Stack != "" {
+			entry.Stack = []byte(report.Stack)
+		}
+		c.client.Report(entry)
+	}
+	c.client.Flush()
+	return nil
+}
+
+// Close error reporting client
+//
+//	err := transport.Close()
+//
+func (c *GoogleTransport) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+// reportMessage return report message with trace id and repeat count
+//
+//	message := reportMessage(report) // "nil pointer [trace 105445aa7843bc8bf206b12000100000] [repeated 3 times]"
+//
+func reportMessage(report *Report) string {
+	message := traceMessage(report.Message, report.Trace)
+	if report.Count > 1 {
+		message += " [repeated " + strconv.Itoa(report.Count) + " times]"
+	}
+	return message
+}
+
+// traceMessage add trace id to message, so error report can be correlated with log of the same request
+//
+//	message := traceMessage("nil pointer", trace) // "nil pointer [trace 105445aa7843bc8bf206b12000100000]"
+//
+func traceMessage(message, trace string) string {
+	if trace != "" {
+		return message + " [trace " + trace + "]"
+	}
+	return message
+}
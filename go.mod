@@ -1,40 +1,60 @@
 module github.com/piyuo/libsrv
 
-go 1.16
+go 1.19
 
 require (
-	cloud.google.com/go v0.79.0
-	cloud.google.com/go/firestore v1.5.0
-	cloud.google.com/go/logging v1.2.0
-	cloud.google.com/go/storage v1.14.0
-	github.com/NYTimes/gziphandler v1.1.1 // indirect
+	cloud.google.com/go/cloudtasks v1.10.0
+	cloud.google.com/go/errorreporting v0.3.0
+	cloud.google.com/go/firestore v1.14.0
+	cloud.google.com/go/iam v0.13.0
+	cloud.google.com/go/storage v1.29.0
+	github.com/NYTimes/gziphandler v1.1.1
 	github.com/btcsuite/btcutil v1.0.2
-	github.com/coocood/freecache v1.1.1 // indirect
-	github.com/golang/protobuf v1.5.1
+	github.com/coocood/freecache v1.1.1
+	github.com/golang/protobuf v1.5.3
 	github.com/goodsign/monday v1.0.0
-	github.com/google/uuid v1.2.0
-	github.com/gorilla/schema v1.2.0 // indirect
-	github.com/kr/text v0.2.0 // indirect
+	github.com/google/uuid v1.3.0
+	github.com/kr/pretty v0.3.0
 	github.com/mileusna/useragent v1.0.2
-	github.com/nyaruka/phonenumbers v1.0.68
-	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/pkg/errors v0.9.1
-	github.com/sendgrid/rest v2.6.3+incompatible // indirect
 	github.com/sendgrid/sendgrid-go v3.8.0+incompatible
-	github.com/sfreiberg/gotwilio v0.0.0-20201211181435-c426a3710ab5
-	github.com/sirupsen/logrus v1.7.0 // indirect
-	github.com/stretchr/testify v1.7.0
-	go.uber.org/multierr v1.6.0 // indirect
+	github.com/stretchr/testify v1.8.3
 	go.uber.org/zap v1.16.0
-	golang.org/x/mod v0.4.2 // indirect
-	golang.org/x/net v0.0.0-20210316092652-d523dce5a7f4 // indirect
-	golang.org/x/oauth2 v0.0.0-20210313182246-cd4f82c27b84
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
-	golang.org/x/sys v0.0.0-20210320140829-1e4c9ba3b0c4 // indirect
-	google.golang.org/api v0.42.0
-	google.golang.org/genproto v0.0.0-20210319143718-93e7006c17a6
-	google.golang.org/protobuf v1.26.0
-	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
-	github.com/kr/pretty v0.2.1
+	golang.org/x/oauth2 v0.8.0
+	golang.org/x/sync v0.2.0
+	google.golang.org/api v0.128.0
+	google.golang.org/genproto v0.0.0-20230530153820-e85fd2cbaebc
+	google.golang.org/grpc v1.56.1
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	cloud.google.com/go v0.110.2 // indirect
+	cloud.google.com/go/compute v1.19.3 // indirect
+	cloud.google.com/go/compute/metadata v0.2.3 // indirect
+	cloud.google.com/go/longrunning v0.5.0 // indirect
+	github.com/cespare/xxhash v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
+	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/google/s2a-go v0.1.4 // indirect
+	github.com/googleapis/enterprise-certificate-proxy v0.2.4 // indirect
+	github.com/googleapis/gax-go/v2 v2.12.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rogpeppe/go-internal v1.6.1 // indirect
+	github.com/sendgrid/rest v2.6.3+incompatible // indirect
+	go.opencensus.io v0.24.0 // indirect
+	go.uber.org/atomic v1.7.0 // indirect
+	go.uber.org/multierr v1.6.0 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	golang.org/x/time v0.3.0 // indirect
+	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
+	google.golang.org/appengine v1.6.7 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20230530153820-e85fd2cbaebc // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230530153820-e85fd2cbaebc // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -8,6 +8,7 @@ import (
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
 	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/env"
 	"github.com/piyuo/libsrv/gaccount"
 	"github.com/piyuo/libsrv/gdb"
 	"github.com/piyuo/libsrv/identifier"
@@ -78,6 +79,7 @@ func New(ctx context.Context, queueID, url string, body []byte, name string, dur
 				HttpRequest: &tasks.HttpRequest{
 					HttpMethod: tasks.HttpMethod_POST,
 					Url:        url,
+					Headers:    env.TraceHeaders(ctx), // task continue trace of request create it
 				},
 			},
 		},
@@ -0,0 +1,94 @@
+package log
+
+import (
+	"context"
+	"time"
+
+	"github.com/piyuo/libsrv/log/logger"
+	"go.uber.org/zap"
+)
+
+// Field is typed key/value add to log entry
+//
+type Field = zap.Field
+
+// With return context carry fields, every log write with this context will include fields
+//
+//	ctx = log.With(ctx, log.String("order", orderID), log.Int("amount", 100))
+//	log.Info(ctx, "order paid")
+//
+func With(ctx context.Context, fields ...Field) context.Context {
+	return logger.With(ctx, fields...)
+}
+
+// String return string field
+//
+//	log.String("order", orderID)
+//
+func String(key, value string) Field {
+	return zap.String(key, value)
+}
+
+// Int return int field
+//
+//	log.Int("amount", 100)
+//
+func Int(key string, value int) Field {
+	return zap.Int(key, value)
+}
+
+// Int64 return int64 field
+//
+//	log.Int64("size", 65536)
+//
+func Int64(key string, value int64) Field {
+	return zap.Int64(key, value)
+}
+
+// Float64 return float64 field
+//
+//	log.Float64("rate", 0.5)
+//
+func Float64(key string, value float64) Field {
+	return zap.Float64(key, value)
+}
+
+// Bool return bool field
+//
+//	log.Bool("retry", true)
+//
+func Bool(key string, value bool) Field {
+	return zap.Bool(key, value)
+}
+
+// Duration return duration field
+//
+//	log.Duration("elapsed", time.Second)
+//
+func Duration(key string, value time.Duration) Field {
+	return zap.Duration(key, value)
+}
+
+// Time return time field
+//
+//	log.Time("expired", time.Now())
+//
+func Time(key string, value time.Time) Field {
+	return zap.Time(key, value)
+}
+
+// Err return error field with key "error"
+//
+//	log.Err(err)
+//
+func Err(err error) Field {
+	return zap.Error(err)
+}
+
+// Any return field of any value, use typed field when possible
+//
+//	log.Any("tags", []string{"a", "b"})
+//
+func Any(key string, value interface{}) Field {
+	return zap.Any(key, value)
+}
@@ -0,0 +1,32 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/log/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := With(context.Background(),
+		String("s", "1"),
+		Int("i", 1),
+		Int64("i64", 1),
+		Float64("f", 0.5),
+		Bool("b", true),
+		Duration("d", time.Second),
+		Time("t", time.Now()),
+		Err(errors.New("failed")),
+		Any("a", []string{"a"}),
+	)
+	fields := logger.Fields(ctx)
+	assert.Len(fields, 9)
+	assert.Equal("s", fields[0].Key)
+	assert.Equal("error", fields[7].Key)
+	Info(ctx, "structured log")
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/kr/pretty"
 	"github.com/piyuo/libsrv/gerror"
@@ -11,9 +13,13 @@ import (
 	"github.com/pkg/errors"
 )
 
-// history keep all printed log. !Be careful this is global history include all thread log
+// history keep all printed log. !Be careful this is global history include all thread log, use WithSink() to keep log of one context
 //
-var history *strings.Builder
+var history *logger.MemorySink
+
+// historyMutex protect history
+//
+var historyMutex sync.RWMutex
 
 // forceStopLog is true will not log anything
 //
@@ -25,14 +31,16 @@ func ForceStopLog(value bool) {
 	forceStopLog = value
 }
 
-// prepare write message to history and return information logger need
+// initMessage write message to history and return message
 //
-//	message, fields := prepare(ctx, format, a...)
+//	message := initMessage(ctx, "INFO", format, a...)
 //
-func initMessage(ctx context.Context, format string, a ...interface{}) string {
+func initMessage(ctx context.Context, level, format string, a ...interface{}) string {
 	message := fmt.Sprintf(format, a...)
+	historyMutex.RLock()
+	defer historyMutex.RUnlock()
 	if history != nil {
-		history.WriteString(message + "\n")
+		history.Write(logger.Entry{Time: time.Now(), Level: level, Message: message})
 	}
 	return message
 }
@@ -63,7 +71,7 @@ func Debug(ctx context.Context, format string, a ...interface{}) {
 	if ctx.Err() != nil { // deadline error
 		return
 	}
-	logger.Debug(ctx, initMessage(ctx, format, a...))
+	logger.Debug(ctx, initMessage(ctx, "DEBUG", format, a...))
 }
 
 // Info as Normal but significant events, such as start up, shut down, or a configuration change.
@@ -77,7 +85,7 @@ func Info(ctx context.Context, format string, a ...interface{}) {
 	if ctx.Err() != nil { // deadline error
 		return
 	}
-	logger.Info(ctx, initMessage(ctx, format, a...))
+	logger.Info(ctx, initMessage(ctx, "INFO", format, a...))
 }
 
 // Warn as Warning events might cause problems.
@@ -91,7 +99,7 @@ func Warn(ctx context.Context, format string, a ...interface{}) {
 	if ctx.Err() != nil { // deadline error
 		return
 	}
-	logger.Warn(ctx, initMessage(ctx, format, a...))
+	logger.Warn(ctx, initMessage(ctx, "WARNING", format, a...))
 }
 
 // KeepHistory keep all printed log into history
@@ -99,8 +107,10 @@ func Warn(ctx context.Context, format string, a ...interface{}) {
 //	KeepHistory(true)
 //
 func KeepHistory(flag bool) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
 	if flag {
-		history = &strings.Builder{}
+		history = logger.NewMemorySink()
 		return
 	}
 	history = nil
@@ -111,6 +121,8 @@ func KeepHistory(flag bool) {
 //	ResetHistory()
 //
 func ResetHistory() {
+	historyMutex.RLock()
+	defer historyMutex.RUnlock()
 	if history != nil {
 		history.Reset()
 	}
@@ -121,8 +133,14 @@ func ResetHistory() {
 //	History()
 //
 func History() string {
+	historyMutex.RLock()
+	defer historyMutex.RUnlock()
 	if history != nil {
-		return history.String()
+		var sb strings.Builder
+		for _, message := range history.Messages() {
+			sb.WriteString(message + "\n")
+		}
+		return sb.String()
 	}
 	return ""
 }
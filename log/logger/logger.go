@@ -3,6 +3,8 @@ package logger
 import (
 	"context"
 	"os"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/piyuo/libsrv/env"
@@ -10,23 +12,111 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-// logger global instance, cause log use frequently
+// KeyContext define key used in ctx
 //
-var logger = initial()
+type KeyContext int
 
-// initLogger init zap logger
+const (
+	// KeyContextFields is context key name for fields add by With()
+	//
+	KeyContextFields KeyContext = iota
+
+	// KeyContextSinks is context key name for sinks add by WithSink()
+	//
+	KeyContextSinks
+)
+
+// project is google cloud project id used in trace, trace is not linked to project if it is empty
+//
+var project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+
+// encoderConfig return encoder config use cloud logging field name
+//
+func encoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "severity",
+		NameKey:        "logger",
+		MessageKey:     "message",
+		StacktraceKey:  "stack",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    encodeSeverity,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.MillisDurationEncoder,
+	}
+}
+
+// severities is cloud logging severity by zap level
+//
+var severities = map[zapcore.Level]string{
+	zapcore.DebugLevel:  "DEBUG",
+	zapcore.InfoLevel:   "INFO",
+	zapcore.WarnLevel:   "WARNING",
+	zapcore.ErrorLevel:  "ERROR",
+	zapcore.DPanicLevel: "CRITICAL",
+	zapcore.PanicLevel:  "ALERT",
+	zapcore.FatalLevel:  "EMERGENCY",
+}
+
+// encodeSeverity encode zap level to cloud logging severity
+//
+func encodeSeverity(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if severity, ok := severities[level]; ok {
+		enc.AppendString(severity)
+		return
+	}
+	enc.AppendString("DEFAULT")
+}
+
+// levelOf return zap level of cloud logging severity, return info level if severity is unknown
 //
-func initial() *zap.Logger {
-	config := zap.NewProductionEncoderConfig()
-	atom := zap.NewAtomicLevelAt(zapcore.InfoLevel)
-	if env.Debug {
-		atom = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+func levelOf(severity string) zapcore.Level {
+	for level, s := range severities {
+		if s == severity {
+			return level
+		}
 	}
-	config.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-		enc.AppendString(env.AppName)
+	return zapcore.InfoLevel
+}
+
+// labels return cloud logging labels field with app name and region
+//
+func labels() zap.Field {
+	return zap.Object("logging.googleapis.com/labels", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		if env.AppName != "" {
+			enc.AddString("app", env.AppName)
+		}
+		if env.Region != "" {
+			enc.AddString("region", env.Region)
+		}
+		return nil
+	}))
+}
+
+// With return context carry fields, every log write with this context will include fields
+//
+//	ctx = With(ctx, zap.String("order", orderID))
+//
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	if len(fields) == 0 {
+		return ctx
 	}
-	encoder := zapcore.NewConsoleEncoder(config)
-	return zap.New(zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), atom), zap.AddCaller(), zap.AddCallerSkip(2))
+	current := Fields(ctx)
+	merged := make([]zap.Field, 0, len(current)+len(fields))
+	merged = append(merged, current...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, KeyContextFields, merged)
+}
+
+// Fields return fields add to context by With()
+//
+//	fields := Fields(ctx)
+//
+func Fields(ctx context.Context) []zap.Field {
+	if fields, ok := ctx.Value(KeyContextFields).([]zap.Field); ok {
+		return fields
+	}
+	return nil
 }
 
 // addContextInformation add context information to zap fields
@@ -35,11 +125,88 @@ func initial() *zap.Logger {
 //
 func addContextInformation(ctx context.Context) []zap.Field {
 	var fields []zap.Field
-	user := env.GetUserID(ctx)
-	if user != "" {
-		fields = []zap.Field{zap.String("user", user)}
+	if user := env.GetUserID(ctx); user != "" {
+		fields = append(fields, zap.String("user", user))
+	}
+	if account := env.GetAccountID(ctx); account != "" {
+		fields = append(fields, zap.String("account", account))
+	}
+	if command := env.GetCommand(ctx); command != "" {
+		fields = append(fields, zap.String("command", command))
+	}
+	if request := env.GetRequest(ctx); request != nil {
+		if ip := env.GetIP(ctx); ip != "" {
+			fields = append(fields, zap.String("ip", ip))
+		}
+		// user agent string join device, os and browser with separator, skip it if nothing parsed
+		if agent := env.GetUserAgentString(ctx); strings.Trim(agent, ", ") != "" {
+			fields = append(fields, zap.String("agent", agent))
+		}
+	}
+	if trace := env.GetTrace(ctx); trace != "" {
+		if project != "" {
+			trace = "projects/" + project + "/traces/" + trace
+		}
+		fields = append(fields, zap.String("logging.googleapis.com/trace", trace))
+	}
+	return append(fields, Fields(ctx)...)
+}
+
+// write log entry to global sinks and sinks in context, debug entry is only written when env.Debug is true
+//
+//	write(ctx, zapcore.InfoLevel, "server start")
+//
+func write(ctx context.Context, level zapcore.Level, message string) {
+	if level == zapcore.DebugLevel && !env.Debug {
+		return
+	}
+	entry := Entry{
+		Time:    time.Now(),
+		Level:   severities[level],
+		Message: message,
+		Fields:  addContextInformation(ctx),
+	}
+	entry.Caller = caller()
+	for _, sink := range globalSinks() {
+		sink.Write(entry)
+	}
+	for _, sink := range contextSinks(ctx) {
+		sink.Write(entry)
+	}
+}
+
+// logPackages is function name prefix of log package, caller in these package is skipped
+//
+var logPackages = []string{"github.com/piyuo/libsrv/log.", "github.com/piyuo/libsrv/log/logger."}
+
+// caller return file and line of first caller outside log package, like "server/server.go:60"
+//
+func caller() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !inLogPackage(frame) {
+			return zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true).TrimmedPath()
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// inLogPackage return true if frame is in log package and not in test
+//
+func inLogPackage(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+	for _, prefix := range logPackages {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return true
+		}
 	}
-	return fields
+	return false
 }
 
 // Debug only print message when os.Getenv("DEBUG") is defined
@@ -47,7 +214,7 @@ func addContextInformation(ctx context.Context) []zap.Field {
 //	Debug(ctx,"server start")
 //
 func Debug(ctx context.Context, message string) {
-	logger.Debug(message, addContextInformation(ctx)...)
+	write(ctx, zapcore.DebugLevel, message)
 }
 
 // Info as Normal but significant events, such as start up, shut down, or a configuration change.
@@ -55,7 +222,7 @@ func Debug(ctx context.Context, message string) {
 //	Info(ctx,"server start")
 //
 func Info(ctx context.Context, message string) {
-	logger.Info(message, addContextInformation(ctx)...)
+	write(ctx, zapcore.InfoLevel, message)
 }
 
 // Warn events might cause problems.
@@ -63,7 +230,7 @@ func Info(ctx context.Context, message string) {
 //	Warning(ctx,"hi")
 //
 func Warn(ctx context.Context, message string) {
-	logger.Warn(message, addContextInformation(ctx)...)
+	write(ctx, zapcore.WarnLevel, message)
 }
 
 // Error write error log
@@ -71,5 +238,5 @@ func Warn(ctx context.Context, message string) {
 //	Error(ctx,"error")
 //
 func Error(ctx context.Context, message string) {
-	logger.Error(message, addContextInformation(ctx)...)
+	write(ctx, zapcore.ErrorLevel, message)
 }
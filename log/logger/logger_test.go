@@ -1,28 +1,49 @@
 package logger
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/piyuo/libsrv/env"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-func TestLoggerInitial(t *testing.T) {
+func TestLoggerSink(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
-	l := initial()
-	assert.NotNil(l)
-}
+	sink := NewMemorySink()
+	ctx := WithSink(context.Background(), sink)
+	Info(ctx, "info")
+	Warn(ctx, "warn")
+	Error(ctx, "error")
+	Debug(ctx, "debug")
+	entries := sink.Entries()
+	if env.Debug {
+		assert.Len(entries, 4)
+	} else {
+		assert.Len(entries, 3)
+	}
+	assert.Equal("INFO", entries[0].Level)
+	assert.Equal("info", entries[0].Message)
+	assert.Contains(entries[0].Caller, "logger_test.go")
+	assert.False(entries[0].Time.IsZero())
+	assert.Equal("WARNING", entries[1].Level)
+	assert.Equal("ERROR", entries[2].Level)
+	assert.Equal([]string{"info", "warn", "error"}, sink.Messages()[:3])
+	sink.Reset()
+	assert.Empty(sink.Entries())
 
-func TestLoggerInitialDebug(t *testing.T) {
-	t.Parallel()
-	assert := assert.New(t)
-	bak := env.Debug
-	env.Debug = true
-	l := initial()
-	assert.NotNil(l)
-	env.Debug = bak
+	// other context is not recorded
+	Info(context.Background(), "not recorded")
+	assert.Empty(sink.Entries())
 }
 
 func TestLoggerAddContextInformation(t *testing.T) {
@@ -57,3 +78,94 @@ func TestLoggerError(t *testing.T) {
 	ctx := context.Background()
 	Error(ctx, "logger error")
 }
+
+func TestLoggerCloudLoggingJSON(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	jsonSink := newJSONSink(zapcore.AddSync(&buf))
+	sink := NewMemorySink()
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 7_0 like Mac OS X) AppleWebKit/546.10 (KHTML, like Gecko) Version/6.0 Mobile/7E18WD Safari/8536.25")
+	req.Header.Set(env.TraceHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+	req.RemoteAddr = "10.0.0.1:1234"
+	ctx := env.SetRequest(context.Background(), req)
+	ctx = env.SetUserID(ctx, "user1")
+	ctx = env.SetAccountID(ctx, "account1")
+	ctx = env.SetCommand(ctx, "CmdLogin")
+	ctx = With(ctx, zap.String("order", "o1"))
+	ctx = With(ctx, zap.Int("amount", 100))
+	Warn(WithSink(ctx, sink), "hi")
+	err := jsonSink.Write(sink.Entries()[0])
+	assert.Nil(err)
+
+	entry := map[string]interface{}{}
+	err = json.Unmarshal(buf.Bytes(), &entry)
+	assert.Nil(err)
+	assert.Equal("WARNING", entry["severity"])
+	assert.Equal("hi", entry["message"])
+	assert.Equal("user1", entry["user"])
+	assert.Equal("account1", entry["account"])
+	assert.Equal("CmdLogin", entry["command"])
+	assert.Equal("10.0.0.1", entry["ip"])
+	assert.Equal("iPhone,iOS 7.0,Safari 6.0", entry["agent"])
+	assert.Contains(entry["logging.googleapis.com/trace"], "105445aa7843bc8bf206b12000100000")
+	assert.Equal("o1", entry["order"])
+	assert.Equal(float64(100), entry["amount"])
+	assert.NotEmpty(entry["time"])
+	assert.Contains(entry["caller"], "logger_test.go")
+	assert.Equal("o1", sink.Entries()[0].FieldMap()["order"])
+
+	// empty user agent is not logged
+	req, _ = http.NewRequest("GET", "/", nil)
+	sink = NewMemorySink()
+	Warn(WithSink(env.SetRequest(context.Background(), req), sink), "hi")
+	_, found := sink.Entries()[0].FieldMap()["agent"]
+	assert.False(found)
+}
+
+func TestFileSink(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	filename := filepath.Join(t.TempDir(), "app.log")
+	sink, err := NewFileSink(filename)
+	assert.Nil(err)
+	ctx := WithSink(context.Background(), sink)
+	Info(ctx, "line1")
+	Info(ctx, "line2")
+	err = sink.Close()
+	assert.Nil(err)
+	bytes, err := ioutil.ReadFile(filename)
+	assert.Nil(err)
+	lines := strings.Split(strings.TrimSpace(string(bytes)), "\n")
+	assert.Len(lines, 2)
+	entry := map[string]interface{}{}
+	err = json.Unmarshal([]byte(lines[1]), &entry)
+	assert.Nil(err)
+	assert.Equal("line2", entry["message"])
+
+	_, err = NewFileSink(filepath.Join(t.TempDir(), "not-exist", "app.log"))
+	assert.NotNil(err)
+}
+
+func TestUseSinks(t *testing.T) {
+	assert := assert.New(t)
+	sink := NewMemorySink()
+	UseSinks(sink)
+	defer UseSinks(NewStdoutSink())
+	Info(context.Background(), "global")
+	assert.Equal([]string{"global"}, sink.Messages())
+}
+
+func TestLoggerWith(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	assert.Nil(Fields(ctx))
+	assert.Equal(ctx, With(ctx))
+	ctx1 := With(ctx, zap.String("a", "1"))
+	ctx2 := With(ctx1, zap.String("b", "2"))
+	assert.Len(Fields(ctx1), 1)
+	assert.Len(Fields(ctx2), 2)
+}
@@ -0,0 +1,271 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/piyuo/libsrv/env"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is log entry write to sink
+//
+type Entry struct {
+
+	// Time is time entry created
+	//
+	Time time.Time
+
+	// Level is cloud logging severity like "DEBUG", "INFO", "WARNING", "ERROR"
+	//
+	Level string
+
+	// Message is log message
+	//
+	Message string
+
+	// Caller is file and line write log, like "server/server.go:60"
+	//
+	Caller string
+
+	// Fields is context information and fields add by With()
+	//
+	Fields []zap.Field
+}
+
+// FieldMap return fields as map, error field is converted to string
+//
+//	value := entry.FieldMap()["user"]
+//
+func (c Entry) FieldMap() map[string]interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range c.Fields {
+		field.AddTo(enc)
+	}
+	return enc.Fields
+}
+
+// Sink receive log entry, sink must be safe for concurrent use
+//
+type Sink interface {
+
+	// Write log entry
+	//
+	//	err := sink.Write(entry)
+	//
+	Write(entry Entry) error
+}
+
+// sinks is sinks every log entry write to
+//
+var sinks = []Sink{NewStdoutSink()}
+
+// sinksMutex protect sinks
+//
+var sinksMutex sync.RWMutex
+
+// UseSinks set sinks every log entry write to, default is stdout sink
+//
+//	file, err := NewFileSink("app.log")
+//	UseSinks(NewStdoutSink(), file)
+//
+func UseSinks(s ...Sink) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	sinks = s
+}
+
+// globalSinks return sinks every log entry write to
+//
+func globalSinks() []Sink {
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+	return sinks
+}
+
+// WithSink return context write log entry to sink in addition to global sinks, it let parallel test assert on log without shared global
+//
+//	sink := NewMemorySink()
+//	ctx = WithSink(ctx, sink)
+//
+func WithSink(ctx context.Context, sink Sink) context.Context {
+	current := contextSinks(ctx)
+	merged := make([]Sink, 0, len(current)+1)
+	merged = append(merged, current...)
+	merged = append(merged, sink)
+	return context.WithValue(ctx, KeyContextSinks, merged)
+}
+
+// contextSinks return sinks add to context by WithSink()
+//
+func contextSinks(ctx context.Context) []Sink {
+	if s, ok := ctx.Value(KeyContextSinks).([]Sink); ok {
+		return s
+	}
+	return nil
+}
+
+// JSONSink write cloud logging json entry to writer
+//
+type JSONSink struct {
+	Sink
+
+	// encoder encode entry to json
+	//
+	encoder zapcore.Encoder
+
+	// out is writer entry write to
+	//
+	out zapcore.WriteSyncer
+}
+
+// NewStdoutSink create sink write cloud logging json to stdout
+//
+//	sink := NewStdoutSink()
+//
+func NewStdoutSink() *JSONSink {
+	return newJSONSink(zapcore.Lock(os.Stdout))
+}
+
+// newJSONSink create sink write cloud logging json to out
+//
+func newJSONSink(out zapcore.WriteSyncer) *JSONSink {
+	encoder := zapcore.NewJSONEncoder(encoderConfig())
+	if env.AppName != "" || env.Region != "" {
+		labels().AddTo(encoder)
+	}
+	return &JSONSink{
+		encoder: encoder,
+		out:     out,
+	}
+}
+
+// Write encode entry to json and write to writer
+//
+//	err := sink.Write(entry)
+//
+func (c *JSONSink) Write(entry Entry) error {
+	zapEntry := zapcore.Entry{
+		Level:   levelOf(entry.Level),
+		Time:    entry.Time,
+		Message: entry.Message,
+	}
+	fields := entry.Fields
+	if entry.Caller != "" {
+		fields = append([]zap.Field{zap.String("caller", entry.Caller)}, fields...)
+	}
+	buf, err := c.encoder.EncodeEntry(zapEntry, fields)
+	if err != nil {
+		return errors.Wrap(err, "encode entry")
+	}
+	defer buf.Free()
+	if _, err := c.out.Write(buf.Bytes()); err != nil {
+		return errors.Wrap(err, "write entry")
+	}
+	return nil
+}
+
+// FileSink write cloud logging json entry to file, one entry per line
+//
+type FileSink struct {
+	*JSONSink
+
+	// file is file entry write to
+	//
+	file *os.File
+}
+
+// NewFileSink create sink append cloud logging json to file, file will be created if not exist
+//
+//	sink, err := NewFileSink("app.log")
+//	defer sink.Close()
+//
+func NewFileSink(filename string) (*FileSink, error) {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "open "+filename)
+	}
+	return &FileSink{
+		JSONSink: newJSONSink(zapcore.Lock(file)),
+		file:     file,
+	}, nil
+}
+
+// Close file
+//
+//	err := sink.Close()
+//
+func (c *FileSink) Close() error {
+	return c.file.Close()
+}
+
+// MemorySink keep log entry in memory, use it in test to assert on emitted log
+//
+type MemorySink struct {
+	Sink
+
+	// mutex protect entries
+	//
+	mutex sync.Mutex
+
+	// entries is entry written
+	//
+	entries []Entry
+}
+
+// NewMemorySink create memory sink
+//
+//	sink := NewMemorySink()
+//
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Write keep entry in memory
+//
+//	err := sink.Write(entry)
+//
+func (c *MemorySink) Write(entry Entry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = append(c.entries, entry)
+	return nil
+}
+
+// Entries return copy of entry written
+//
+//	entries := sink.Entries()
+//
+func (c *MemorySink) Entries() []Entry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]Entry(nil), c.entries...)
+}
+
+// Messages return message of entry written
+//
+//	messages := sink.Messages()
+//
+func (c *MemorySink) Messages() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	messages := make([]string, len(c.entries))
+	for i, entry := range c.entries {
+		messages[i] = entry.Message
+	}
+	return messages
+}
+
+// Reset remove all entry
+//
+//	sink.Reset()
+//
+func (c *MemorySink) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = nil
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"context"
+
+	"github.com/piyuo/libsrv/log/logger"
+)
+
+// Entry is log entry write to sink
+//
+type Entry = logger.Entry
+
+// Sink receive log entry, sink must be safe for concurrent use
+//
+type Sink = logger.Sink
+
+// MemorySink keep log entry in memory, use it in test to assert on emitted log
+//
+type MemorySink = logger.MemorySink
+
+// NewStdoutSink create sink write cloud logging json to stdout, it is default sink
+//
+//	log.UseSinks(log.NewStdoutSink())
+//
+func NewStdoutSink() Sink {
+	return logger.NewStdoutSink()
+}
+
+// NewFileSink create sink append cloud logging json to file, one entry per line
+//
+//	sink, err := log.NewFileSink("app.log")
+//	defer sink.Close()
+//
+func NewFileSink(filename string) (*logger.FileSink, error) {
+	return logger.NewFileSink(filename)
+}
+
+// NewMemorySink create sink keep log entry in memory
+//
+//	sink := log.NewMemorySink()
+//	ctx = log.WithSink(ctx, sink)
+//
+func NewMemorySink() *MemorySink {
+	return logger.NewMemorySink()
+}
+
+// UseSinks set sinks every log entry write to, default is stdout sink
+//
+//	log.UseSinks(log.NewStdoutSink(), fileSink)
+//
+func UseSinks(sinks ...Sink) {
+	logger.UseSinks(sinks...)
+}
+
+// WithSink return context write log entry to sink in addition to global sinks, so parallel test can assert on log without shared global
+//
+//	sink := log.NewMemorySink()
+//	ctx = log.WithSink(ctx, sink)
+//	log.Info(ctx, "hi")
+//	entries := sink.Entries()
+//
+func WithSink(ctx context.Context, sink Sink) context.Context {
+	return logger.WithSink(ctx, sink)
+}
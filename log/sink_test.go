@@ -0,0 +1,59 @@
+package log
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSink(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	sink := NewMemorySink()
+	ctx := WithSink(context.Background(), sink)
+	ctx = With(ctx, String("order", "o1"))
+	Info(ctx, "paid %v", 100)
+	Warn(ctx, "slow")
+	Error(ctx, errors.New("failed"))
+
+	entries := sink.Entries()
+	if forceStopLog {
+		assert.Empty(entries)
+		return
+	}
+	assert.True(len(entries) >= 3) // error report may log its own failure
+	assert.Equal("INFO", entries[0].Level)
+	assert.Equal("paid 100", entries[0].Message)
+	assert.Equal("o1", entries[0].FieldMap()["order"])
+	assert.Contains(entries[0].Caller, "sink_test.go")
+	assert.Equal("WARNING", entries[1].Level)
+	assert.Equal("ERROR", entries[2].Level)
+	assert.Contains(entries[2].Message, "failed")
+}
+
+func TestWithSinkConcurrent(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	var wg sync.WaitGroup
+	sinks := make([]*MemorySink, 10)
+	for i := 0; i < 10; i++ {
+		sinks[i] = NewMemorySink()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := WithSink(context.Background(), sinks[i])
+			Info(ctx, strconv.Itoa(i))
+		}(i)
+	}
+	wg.Wait()
+	if forceStopLog {
+		return
+	}
+	for i, sink := range sinks {
+		assert.Equal([]string{strconv.Itoa(i)}, sink.Messages())
+	}
+}
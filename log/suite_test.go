@@ -1,10 +1,12 @@
 package log
 
 import (
+	"context"
 	"os"
 	"testing"
 
 	"github.com/piyuo/libsrv/gaccount"
+	"github.com/piyuo/libsrv/gerror"
 )
 
 func TestMain(m *testing.M) {
@@ -16,8 +18,10 @@ func TestMain(m *testing.M) {
 
 func setup() {
 	gaccount.ForceTestCredential(true)
+	gerror.Use(gerror.NewReporter(gerror.NewLocalTransport(), gerror.ReporterOption{}))
 }
 
 func shutdown() {
+	gerror.Shutdown(context.Background())
 	gaccount.ForceTestCredential(false)
 }
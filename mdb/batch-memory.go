@@ -0,0 +1,103 @@
+package mdb
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+)
+
+// BatchMemory implement in-memory batch, write operation will be applied when batch commit
+//
+type BatchMemory struct {
+	db.Batch
+
+	// client is memory client
+	//
+	client *ClientMemory
+
+	// writes is write operation to apply when batch commit
+	//
+	writes []write
+
+	// err keep first error happen in batch operation, batch operation has no error return, so error will be return on commit
+	//
+	err error
+
+	// hasSomethingToCommit set to true when batch operation has been called like set/update/delete
+	//
+	hasSomethingToCommit bool
+}
+
+// add write operation to batch
+//
+func (c *BatchMemory) add(w write, err error) {
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	c.writes = append(c.writes, w)
+	c.hasSomethingToCommit = true
+}
+
+// Set object into table, If the document not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data, if object does not have id, it will created using UUID
+//
+//	 Set(ctx, object)
+//
+func (c *BatchMemory) Set(ctx context.Context, obj db.Object) {
+	c.client.BaseClient.BeforeSet(ctx, obj)
+	doc, err := objToDoc(obj)
+	c.add(setWrite(obj.Collection(), obj.ID(), doc), err)
+}
+
+// Update partial object field, create new one if object does not exist
+//
+//	Update(ctx, Sample, map[string]interface{}{
+//		"desc": "hi",
+//	})
+//
+func (c *BatchMemory) Update(ctx context.Context, obj db.Object, fields map[string]interface{}) {
+	doc, err := fieldsToDoc(fields)
+	c.add(updateWrite(obj, doc), err)
+}
+
+// Increment value on object field, return error if object does not exist
+//
+//	Increment(ctx,sample, "Value", 2)
+//
+func (c *BatchMemory) Increment(ctx context.Context, obj db.Object, field string, value int) {
+	c.add(incrementWrite(obj.Collection(), obj.ID(), field, int64(value)), nil)
+}
+
+// Delete object, no error if id not exist. object use soft delete will only set delete time
+//
+//	Delete(ctx, sample)
+//
+func (c *BatchMemory) Delete(ctx context.Context, obj db.Object) {
+	c.add(deleteObjWrite(obj), nil)
+}
+
+// DeleteList delete object use list of id, no error if id not exist
+//
+//	DeleteList(ctx, &Sample{}, []string{"1","2"})
+//
+func (c *BatchMemory) DeleteList(ctx context.Context, obj db.Object, list []string) {
+	obj.SetRef(nil)
+	for _, id := range list {
+		obj.SetID(id)
+		c.Delete(ctx, obj)
+	}
+	obj.SetID("")
+}
+
+// DeleteRef delete object use document ref
+//
+//	DeleteRef(ref)
+//
+func (c *BatchMemory) DeleteRef(ref *firestore.DocumentRef) {
+	if ref == nil || ref.Parent == nil {
+		c.add(nil, errors.New("ref must not nil"))
+		return
+	}
+	c.add(deleteWrite(ref.Parent.ID, ref.ID), nil)
+}
@@ -0,0 +1,107 @@
+package mdb
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample := &Sample{
+		Name:  "test-batch",
+		Value: 2001,
+	}
+
+	err := client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Set(ctx, sample) //batch mode do not return error
+		return nil
+	})
+	assert.Nil(err)
+	assert.NotEmpty(sample.ID())
+
+	err = client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Update(ctx, sample, map[string]interface{}{
+			"Value": 2002,
+		})
+		batch.Increment(ctx, sample, "Value", 1)
+		return nil
+	})
+	assert.Nil(err)
+
+	obj, err := client.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal(2003, obj.(*Sample).Value)
+
+	err = client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Delete(ctx, obj)
+		return nil
+	})
+	assert.Nil(err)
+
+	count, err := client.Query(&Sample{}).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+}
+
+func TestBatchDeleteList(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample1 := &Sample{Name: "1"}
+	sample2 := &Sample{Name: "2"}
+	sample3 := &Sample{Name: "3"}
+	assert.Nil(client.Set(ctx, sample1))
+	assert.Nil(client.Set(ctx, sample2))
+	assert.Nil(client.Set(ctx, sample3))
+
+	err := client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.DeleteList(ctx, &Sample{}, []string{sample1.ID(), sample2.ID()})
+		batch.DeleteRef(&firestore.DocumentRef{
+			Parent: &firestore.CollectionRef{ID: "Sample"},
+			ID:     sample3.ID(),
+		})
+		return nil
+	})
+	assert.Nil(err)
+
+	count, err := client.Query(&Sample{}).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+}
+
+func TestBatchFail(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample := &Sample{Name: "test-batch-fail"}
+
+	err := client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Set(ctx, sample)
+		return errors.New("something wrong")
+	})
+	assert.NotNil(err)
+
+	// increment on not exist object will fail whole batch
+	err = client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Set(ctx, sample)
+		notExist := &Sample{}
+		notExist.SetID("not-exist")
+		batch.Increment(ctx, notExist, "Value", 1)
+		return nil
+	})
+	assert.NotNil(err)
+
+	count, err := client.Query(&Sample{}).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+}
@@ -0,0 +1,237 @@
+package mdb
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+)
+
+// docKey is key to locate document
+//
+type docKey struct {
+	collection string
+	id         string
+}
+
+// errReadChanged return when document read in transaction has been changed by others before commit
+//
+var errReadChanged = errors.Wrap(db.ErrConflict, "document read in transaction has been changed")
+
+// write is a write operation, it will be applied on changes when commit
+//
+type write func(ch *changes) error
+
+// changes keep documents modified by write operations, it will be applied to client only when all write operations success, so transaction and batch is atomic
+//
+type changes struct {
+
+	// client is memory client
+	//
+	client *ClientMemory
+
+	// docs is modified documents, nil document mean document been deleted
+	//
+	docs map[docKey]map[string]interface{}
+}
+
+// get return copy of document, return nil if document not exist
+//
+func (c *changes) get(collection, id string) map[string]interface{} {
+	key := docKey{collection: collection, id: id}
+	if doc, found := c.docs[key]; found {
+		if doc == nil {
+			return nil
+		}
+		return copyValue(doc).(map[string]interface{})
+	}
+	return c.client.doc(collection, id)
+}
+
+// set put document into changes
+//
+func (c *changes) set(collection, id string, doc map[string]interface{}) {
+	c.docs[docKey{collection: collection, id: id}] = doc
+}
+
+// delete mark document as deleted
+//
+func (c *changes) delete(collection, id string) {
+	c.docs[docKey{collection: collection, id: id}] = nil
+}
+
+// apply all changes to client
+//
+func (c *changes) apply() {
+	for key, doc := range c.docs {
+		if doc == nil {
+			c.client.remove(key.collection, key.id)
+			continue
+		}
+		c.client.put(key.collection, key.id, doc)
+	}
+}
+
+// readWrite return write operation to check document still the same as read in transaction, return errReadChanged if document has been changed
+//
+func readWrite(reads map[docKey]map[string]interface{}) write {
+	return func(ch *changes) error {
+		for key, doc := range reads {
+			if !reflect.DeepEqual(ch.client.doc(key.collection, key.id), doc) {
+				return errors.Wrapf(errReadChanged, "%v-%v", key.collection, key.id)
+			}
+		}
+		return nil
+	}
+}
+
+// setWrite return write operation to replace whole document
+//
+func setWrite(collection, id string, doc map[string]interface{}) write {
+	return func(ch *changes) error {
+		ch.set(collection, id, doc)
+		return nil
+	}
+}
+
+// mergeWrite return write operation to merge fields into document, create document if not exist
+//
+func mergeWrite(collection, id string, fields map[string]interface{}) write {
+	return func(ch *changes) error {
+		doc := ch.get(collection, id)
+		if doc == nil {
+			doc = map[string]interface{}{}
+		}
+		mergeMap(doc, fields)
+		ch.set(collection, id, doc)
+		return nil
+	}
+}
+
+// updateWrite return write operation to merge fields into object document, document created for object use soft delete will have zero delete time, so query can find it
+//
+func updateWrite(obj db.Object, fields map[string]interface{}) write {
+	collection, id, softDelete := obj.Collection(), obj.ID(), obj.SoftDelete()
+	merge := mergeWrite(collection, id, fields)
+	return func(ch *changes) error {
+		if softDelete && ch.get(collection, id) == nil {
+			ch.set(collection, id, map[string]interface{}{db.DeleteTimeField: time.Time{}})
+		}
+		return merge(ch)
+	}
+}
+
+// incrementWrite return write operation to increment field value, return error if document not exist
+//
+func incrementWrite(collection, id, field string, value interface{}) write {
+	return func(ch *changes) error {
+		doc := ch.get(collection, id)
+		if doc == nil {
+			return errors.Errorf("no document to update %v-%v", collection, id)
+		}
+		result, err := addValue(doc[field], value)
+		if err != nil {
+			return errors.Wrapf(err, "increment %v %v-%v", field, collection, id)
+		}
+		doc[field] = result
+		ch.set(collection, id, doc)
+		return nil
+	}
+}
+
+// deleteWrite return write operation to delete document
+//
+func deleteWrite(collection, id string) write {
+	return func(ch *changes) error {
+		ch.delete(collection, id)
+		return nil
+	}
+}
+
+// versionWrite return write operation to check stored version equal to version then run w and increment version, return db.ErrConflict if stored version has moved
+//
+func versionWrite(collection, id string, version int64, w write) write {
+	return func(ch *changes) error {
+		stored := int64(0)
+		if doc := ch.get(collection, id); doc != nil {
+			stored, _ = doc[db.VersionField].(int64)
+		}
+		if stored != version {
+			return errors.Wrapf(db.ErrConflict, "%v-%v version %v, stored %v", collection, id, version, stored)
+		}
+		if err := w(ch); err != nil {
+			return err
+		}
+		doc := ch.get(collection, id)
+		doc[db.VersionField] = version + 1
+		ch.set(collection, id, doc)
+		return nil
+	}
+}
+
+// softDeleteWrite return write operation to set document delete time, do nothing if document not exist
+//
+func softDeleteWrite(collection, id string) write {
+	return func(ch *changes) error {
+		doc := ch.get(collection, id)
+		if doc == nil {
+			return nil
+		}
+		doc[db.DeleteTimeField] = time.Now().UTC()
+		ch.set(collection, id, doc)
+		return nil
+	}
+}
+
+// restoreWrite return write operation to clear document delete time, return error if document not exist
+//
+func restoreWrite(collection, id string) write {
+	return func(ch *changes) error {
+		doc := ch.get(collection, id)
+		if doc == nil {
+			return errors.Errorf("no document to restore %v-%v", collection, id)
+		}
+		doc[db.DeleteTimeField] = time.Time{}
+		ch.set(collection, id, doc)
+		return nil
+	}
+}
+
+// mergeMap merge src into dst, nested map will be merged too
+//
+func mergeMap(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if srcMap, ok := v.(map[string]interface{}); ok {
+			if dstMap, ok := dst[k].(map[string]interface{}); ok {
+				mergeMap(dstMap, srcMap)
+				continue
+			}
+		}
+		dst[k] = copyValue(v)
+	}
+}
+
+// addValue return current + value, current not number will be treat as 0
+//
+func addValue(current, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int64:
+		switch c := current.(type) {
+		case int64:
+			return c + v, nil
+		case float64:
+			return c + float64(v), nil
+		}
+		return v, nil
+	case float64:
+		switch c := current.(type) {
+		case int64:
+			return float64(c) + v, nil
+		case float64:
+			return c + v, nil
+		}
+		return v, nil
+	}
+	return nil, errors.Errorf("increment value must be number, got %T", value)
+}
@@ -0,0 +1,147 @@
+package mdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheClientGet(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	cached := db.NewCacheClient(client, map[string]time.Duration{"Sample": time.Minute})
+
+	sample := &Sample{Name: "a", Map: map[string]string{"k": "v"}, Numbers: []int{1}}
+	err := cached.Set(ctx, sample)
+	assert.Nil(err)
+	obj, err := cached.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("a", obj.(*Sample).Name)
+
+	// change through other client is not noticed
+	sample.Name = "b"
+	err = client.Set(ctx, sample)
+	assert.Nil(err)
+	obj, err = cached.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal(sample.ID(), obj.ID())
+	assert.Equal("a", obj.(*Sample).Name)
+	assert.Equal("v", obj.(*Sample).Map["k"])
+	assert.Equal([]int{1}, obj.(*Sample).Numbers)
+	found, err := cached.Exists(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.True(found)
+	value, err := cached.Select(ctx, &Sample{}, sample.ID(), "Name")
+	assert.Nil(err)
+	assert.Equal("b", value)
+
+	// change through cache client remove cached object and field
+	sample.Name = "c"
+	err = cached.Set(ctx, sample)
+	assert.Nil(err)
+	obj, err = cached.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("c", obj.(*Sample).Name)
+	value, err = cached.Select(ctx, &Sample{}, sample.ID(), "Name")
+	assert.Nil(err)
+	assert.Equal("c", value)
+
+	err = cached.Increment(ctx, sample, "Value", 2)
+	assert.Nil(err)
+	obj, err = cached.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal(2, obj.(*Sample).Value)
+
+	id := sample.ID()
+	err = cached.Delete(ctx, sample)
+	assert.Nil(err)
+	obj, err = cached.Get(ctx, &Sample{}, id)
+	assert.Nil(err)
+	assert.Nil(obj)
+	found, err = cached.Exists(ctx, &Sample{}, id)
+	assert.Nil(err)
+	assert.False(found)
+	value, err = cached.Select(ctx, &Sample{}, id, "Name")
+	assert.Nil(err)
+	assert.Nil(value)
+
+	// not found is cached
+	sample = &Sample{Name: "d"}
+	sample.SetID(id)
+	err = client.Set(ctx, sample)
+	assert.Nil(err)
+	obj, err = cached.Get(ctx, &Sample{}, id)
+	assert.Nil(err)
+	assert.Nil(obj)
+	cached.InvalidateCollection(&Sample{})
+	obj, err = cached.Get(ctx, &Sample{}, id)
+	assert.Nil(err)
+	assert.Equal("d", obj.(*Sample).Name)
+}
+
+func TestCacheClientNotCached(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	cached := db.NewCacheClient(client, map[string]time.Duration{})
+
+	sample := &Sample{Name: "a"}
+	err := cached.Set(ctx, sample)
+	assert.Nil(err)
+	obj, err := cached.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("a", obj.(*Sample).Name)
+	sample.Name = "b"
+	err = client.Set(ctx, sample)
+	assert.Nil(err)
+	obj, err = cached.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("b", obj.(*Sample).Name)
+}
+
+func TestCacheClientTransaction(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	cached := db.NewCacheClient(client, map[string]time.Duration{"Sample": time.Minute})
+
+	sample := &Sample{Name: "a"}
+	err := cached.Set(ctx, sample)
+	assert.Nil(err)
+	cached.Get(ctx, &Sample{}, sample.ID())
+
+	// read in transaction bypass cache, change in transaction remove cached object
+	sample.Name = "b"
+	err = client.Set(ctx, sample)
+	assert.Nil(err)
+	err = cached.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		obj, err := tx.Get(ctx, &Sample{}, sample.ID())
+		if err != nil {
+			return err
+		}
+		assert.Equal("b", obj.(*Sample).Name)
+		obj.(*Sample).Name = "c"
+		return tx.Set(ctx, obj)
+	})
+	assert.Nil(err)
+	obj, err := cached.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("c", obj.(*Sample).Name)
+
+	// change in batch remove cached object
+	err = cached.Batch(ctx, func(ctx context.Context, bc db.Batch) error {
+		bc.Update(ctx, sample, map[string]interface{}{"Name": "d"})
+		return nil
+	})
+	assert.Nil(err)
+	obj, err = cached.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("d", obj.(*Sample).Name)
+}
@@ -0,0 +1,649 @@
+package mdb
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+)
+
+// ClientMemory implement in-memory connection
+//
+type ClientMemory struct {
+	db.BaseClient
+
+	// mutex protect collections
+	//
+	mutex sync.RWMutex
+
+	// txMutex make sure only one transaction run at a time
+	//
+	txMutex sync.Mutex
+
+	// collections keep all document, key is collection name then document id
+	//
+	collections map[string]map[string]map[string]interface{}
+
+	// closed is true if client is close
+	//
+	closed bool
+
+	// watchers is watcher to notify when commit
+	//
+	watchers map[*WatcherMemory]bool
+}
+
+// Close client
+//
+//	client, err := NewClient(ctx)
+//	defer c.Close()
+//
+func (c *ClientMemory) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+}
+
+// IsClose return true if connection is close
+//
+//	closed := IsClose()
+//
+func (c *ClientMemory) IsClose() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.closed
+}
+
+// doc return copy of document, return nil if document not exist. caller must hold mutex
+//
+func (c *ClientMemory) doc(collection, id string) map[string]interface{} {
+	doc, found := c.collections[collection][id]
+	if !found {
+		return nil
+	}
+	return copyValue(doc).(map[string]interface{})
+}
+
+// put document into collection. caller must hold mutex
+//
+func (c *ClientMemory) put(collection, id string, doc map[string]interface{}) {
+	docs := c.collections[collection]
+	if docs == nil {
+		docs = map[string]map[string]interface{}{}
+		c.collections[collection] = docs
+	}
+	docs[id] = doc
+}
+
+// remove document from collection. caller must hold mutex
+//
+func (c *ClientMemory) remove(collection, id string) {
+	delete(c.collections[collection], id)
+}
+
+// readDoc return copy of document, return nil if document not exist
+//
+func (c *ClientMemory) readDoc(collection, id string) map[string]interface{} {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.doc(collection, id)
+}
+
+// commit apply write operations, nothing will be applied if any write operation failed
+//
+//	err := c.commit(setWrite(obj.Collection(), obj.ID(), doc))
+//
+func (c *ClientMemory) commit(writes ...write) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ch := &changes{
+		client: c,
+		docs:   map[docKey]map[string]interface{}{},
+	}
+	for _, w := range writes {
+		if err := w(ch); err != nil {
+			return err
+		}
+	}
+	ch.apply()
+	for watcher := range c.watchers {
+		select {
+		case watcher.notify <- struct{}{}:
+		default: // watcher already has notify pending
+		}
+	}
+	return nil
+}
+
+// watch add watcher to notify when commit
+//
+func (c *ClientMemory) watch(watcher *WatcherMemory) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.watchers == nil {
+		c.watchers = map[*WatcherMemory]bool{}
+	}
+	c.watchers[watcher] = true
+}
+
+// unwatch remove watcher
+//
+func (c *ClientMemory) unwatch(watcher *WatcherMemory) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.watchers, watcher)
+}
+
+// Batch start a batch operation. batch won't be commit if there is no batch operation like set/update/delete been called
+//
+//	err := Batch(ctx, func(ctx context.Context,batch db.Batch) error {
+//		return nil
+//	})
+//
+func (c *ClientMemory) Batch(ctx context.Context, f db.BatchFunc) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	batch := &BatchMemory{
+		client: c,
+	}
+
+	err := f(ctx, batch)
+	if err != nil {
+		return errors.Wrapf(err, "run batch func")
+	}
+
+	if batch.hasSomethingToCommit {
+		if batch.err != nil {
+			return errors.Wrapf(batch.err, "commit batch")
+		}
+		if err := c.commit(batch.writes...); err != nil {
+			return errors.Wrapf(err, "commit batch")
+		}
+	}
+	return nil
+}
+
+// maxTransactionAttempts is max time transaction function run when document read has been changed by others, the same as firestore default
+//
+const maxTransactionAttempts = 5
+
+// Transaction start a transaction, transaction run one at a time, write operation will be applied only when transaction function return nil.
+// if document read in transaction has been changed by others before commit, transaction function run again, return db.ErrConflict after max attempts
+//
+//	err := Transaction(ctx, func(ctx context.Context,tx db.Transaction) error {
+//		return nil
+//	})
+//
+func (c *ClientMemory) Transaction(ctx context.Context, f db.TransactionFunc) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	c.txMutex.Lock()
+	defer c.txMutex.Unlock()
+
+	var err error
+	for attempt := 0; attempt < maxTransactionAttempts; attempt++ {
+		tx := &TransactionMemory{
+			client: c,
+		}
+		if err = f(ctx, tx); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err = c.commit(append([]write{readWrite(tx.reads)}, tx.writes...)...)
+		if errors.Is(err, errReadChanged) {
+			continue // document read has been changed by others, run transaction again
+		}
+		if err != nil {
+			return err
+		}
+		for _, f := range tx.committed {
+			f()
+		}
+		return nil
+	}
+	return errors.Wrap(err, "transaction conflict")
+}
+
+// docToObject convert document to object, return nil if document is nil
+//
+func docToObject(obj db.Object, id string, doc map[string]interface{}) (db.Object, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	if err := fromDocument(doc, obj); err != nil {
+		return nil, errors.Wrapf(err, "doc to object %v-%v", obj.Collection(), id)
+	}
+	obj.SetID(id)
+	return obj, nil
+}
+
+// snapshotsToObjects convert list of snapshot to list of object
+//
+func snapshotsToObjects(obj db.Object, snapshots []*snapshot) ([]db.Object, error) {
+	list := []db.Object{}
+	for _, s := range snapshots {
+		newObj := obj.Factory()
+		if newObj == nil {
+			return nil, errors.New(obj.Collection() + " not implement Factory()")
+		}
+		if _, err := docToObject(newObj, s.id, s.doc); err != nil {
+			return nil, err
+		}
+		list = append(list, newObj)
+	}
+	return list, nil
+}
+
+// docToField return document field, return nil if document is nil
+//
+func docToField(obj db.Object, id, field string, doc map[string]interface{}) (interface{}, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	value, found := valueAt(doc, field)
+	if !found {
+		return nil, errors.Errorf("get data at field %v %v-%v, no field", field, obj.Collection(), id)
+	}
+	return copyValue(value), nil
+}
+
+// objToDoc convert object to document
+//
+func objToDoc(obj db.Object) (map[string]interface{}, error) {
+	doc, err := toDocument(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "object to doc %v-%v", obj.Collection(), obj.ID())
+	}
+	return doc, nil
+}
+
+// fieldsToDoc convert update fields to document value
+//
+func fieldsToDoc(fields map[string]interface{}) (map[string]interface{}, error) {
+	value, err := toValue(reflect.ValueOf(fields))
+	if err != nil {
+		return nil, errors.Wrap(err, "fields to doc")
+	}
+	return value.(map[string]interface{}), nil
+}
+
+// numberValue convert increment value to int64 or float64
+//
+func numberValue(value interface{}) (interface{}, error) {
+	v, err := toValue(reflect.ValueOf(value))
+	if err != nil {
+		return nil, err
+	}
+	switch v.(type) {
+	case int64, float64:
+		return v, nil
+	}
+	return nil, errors.Errorf("increment value must be number, got %T", value)
+}
+
+// isDeleted return true if object use soft delete and document has been deleted
+//
+func isDeleted(obj db.Object, doc map[string]interface{}) bool {
+	if doc == nil || !obj.SoftDelete() {
+		return false
+	}
+	t, ok := doc[db.DeleteTimeField].(time.Time)
+	return ok && !t.IsZero()
+}
+
+// visibleDoc return nil if document has been soft deleted
+//
+func visibleDoc(obj db.Object, doc map[string]interface{}) map[string]interface{} {
+	if isDeleted(obj, doc) {
+		return nil
+	}
+	return doc
+}
+
+// deleteObjWrite return write operation to delete object, object use soft delete will only set delete time. object id will be clear
+//
+func deleteObjWrite(obj db.Object) write {
+	collection, id := objDelete(obj)
+	if obj.SoftDelete() {
+		return softDeleteWrite(collection, id)
+	}
+	return deleteWrite(collection, id)
+}
+
+// objDelete return collection and id to delete object, object id will be clear
+//
+func objDelete(obj db.Object) (string, string) {
+	collection, id := obj.Collection(), obj.ID()
+	obj.SetRef(nil)
+	obj.SetID("")
+	return collection, id
+}
+
+// Get data object from table, return nil if object does not exist
+//
+//	object, err := Get(ctx, &Sample{}, "id")
+//
+func (c *ClientMemory) Get(ctx context.Context, obj db.Object, id string) (db.Object, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return nil, err
+	}
+	if err := db.AssertID(id); err != nil {
+		return nil, err
+	}
+	obj = obj.Factory() // recreate null safe object
+	return docToObject(obj, id, visibleDoc(obj, c.readDoc(obj.Collection(), id)))
+}
+
+// Watch return watcher deliver change of object with id, first change is added if object exist
+//
+//	watcher := Watch(ctx, &Sample{}, "id")
+//	defer watcher.Close()
+//	for change := range watcher.Changes() {
+//	}
+//
+func (c *ClientMemory) Watch(ctx context.Context, obj db.Object, id string) db.Watcher {
+	err := db.AssertObject(ctx, obj, false)
+	if err == nil {
+		err = db.AssertID(id)
+	}
+	if err != nil {
+		return newWatcher(ctx, c, obj, nil, err)
+	}
+	collection := obj.Collection()
+	return newWatcher(ctx, c, obj, func(ctx context.Context) (map[string]map[string]interface{}, error) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		docs := map[string]map[string]interface{}{}
+		if doc := visibleDoc(obj, c.readDoc(collection, id)); doc != nil {
+			docs[id] = doc
+		}
+		return docs, nil
+	}, nil)
+}
+
+// Exists return true if object with id exist
+//
+//	found,err := Exists(ctx, &Sample{}, "id")
+//
+func (c *ClientMemory) Exists(ctx context.Context, obj db.Object, id string) (bool, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return false, err
+	}
+	if err := db.AssertID(id); err != nil {
+		return false, err
+	}
+	return visibleDoc(obj, c.readDoc(obj.Collection(), id)) != nil, nil
+}
+
+// List return object list, use max to specific return object count
+//
+//	list,err := List(ctx, &Sample{},10)
+//
+func (c *ClientMemory) List(ctx context.Context, obj db.Object, max int) ([]db.Object, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return nil, err
+	}
+	return c.Query(obj).Limit(max).Return(ctx)
+}
+
+// Select return object field from data store, return nil if object does not exist
+//
+//	return Select(ctx, &Sample{}, id, field)
+//
+func (c *ClientMemory) Select(ctx context.Context, obj db.Object, id, field string) (interface{}, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return false, err
+	}
+	if err := db.AssertID(id); err != nil {
+		return false, err
+	}
+	return docToField(obj, id, field, visibleDoc(obj, c.readDoc(obj.Collection(), id)))
+}
+
+// Query create query
+//
+//	c.Query(ctx, &Sample{}).Return(ctx)
+//
+func (c *ClientMemory) Query(obj db.Object) db.Query {
+	return db.ExcludeDeleted(c.QueryWithDeleted(obj), obj)
+}
+
+// QueryWithDeleted create query include soft deleted object and object has no delete time field
+//
+//	c.QueryWithDeleted(&Sample{}).Return(ctx)
+//
+func (c *ClientMemory) QueryWithDeleted(obj db.Object) db.Query {
+	query := &QueryMemory{
+		BaseQuery: db.BaseQuery{
+			QueryObject: obj,
+		},
+		client: c,
+	}
+	return query.defaultLimit(20)
+}
+
+// Set object into table, If the document not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data, if object does not have id, it will created using UUID
+//
+//	 err := Set(ctx, object)
+//
+func (c *ClientMemory) Set(ctx context.Context, obj db.Object) error {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return err
+	}
+	c.BaseClient.BeforeSet(ctx, obj)
+	doc, err := objToDoc(obj)
+	if err != nil {
+		return err
+	}
+	if !obj.Versioned() {
+		return c.commit(setWrite(obj.Collection(), obj.ID(), doc))
+	}
+	version := obj.Version()
+	if err := c.commit(versionWrite(obj.Collection(), obj.ID(), version, setWrite(obj.Collection(), obj.ID(), doc))); err != nil {
+		return errors.Wrap(err, "set")
+	}
+	obj.SetVersion(version + 1)
+	return nil
+}
+
+// Update partial object field, create new one if object does not exist
+//
+//	err = Update(ctx, Sample, map[string]interface{}{
+//		"desc": "hi",
+//	})
+//
+func (c *ClientMemory) Update(ctx context.Context, obj db.Object, fields map[string]interface{}) error {
+	if err := db.AssertObject(ctx, obj, true); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	doc, err := fieldsToDoc(fields)
+	if err != nil {
+		return err
+	}
+	if !obj.Versioned() {
+		return c.commit(updateWrite(obj, doc))
+	}
+	version := obj.Version()
+	if err := c.commit(versionWrite(obj.Collection(), obj.ID(), version, updateWrite(obj, doc))); err != nil {
+		return errors.Wrap(err, "update")
+	}
+	obj.SetVersion(version + 1)
+	return nil
+}
+
+// Increment value on object field, return error if object does not exist
+//
+//	err := Increment(ctx,sample, "Value", 2)
+//
+func (c *ClientMemory) Increment(ctx context.Context, obj db.Object, field string, value int) error {
+	if err := db.AssertObject(ctx, obj, true); err != nil {
+		return err
+	}
+	return c.commit(incrementWrite(obj.Collection(), obj.ID(), field, int64(value)))
+}
+
+// Delete object, no error if id not exist. object use soft delete will only set delete time
+//
+//	Delete(ctx, sample)
+//
+func (c *ClientMemory) Delete(ctx context.Context, obj db.Object) error {
+	if err := db.AssertObject(ctx, obj, true); err != nil {
+		return err
+	}
+	return c.commit(deleteObjWrite(obj))
+}
+
+// Restore undelete soft deleted object, return error if object does not exist or not use soft delete
+//
+//	err := Restore(ctx, sample)
+//
+func (c *ClientMemory) Restore(ctx context.Context, obj db.Object) error {
+	if err := db.AssertObject(ctx, obj, true); err != nil {
+		return err
+	}
+	if !obj.SoftDelete() {
+		return errors.New(obj.Collection() + " not use soft delete")
+	}
+	if err := c.commit(restoreWrite(obj.Collection(), obj.ID())); err != nil {
+		return errors.Wrap(err, "restore")
+	}
+	obj.SetDeleteTime(time.Time{})
+	return nil
+}
+
+// Purge permanently delete soft deleted object which deleted before retention, return number of object deleted
+//
+//	count, err := Purge(ctx, &Sample{}, 30*24*time.Hour)
+//
+func (c *ClientMemory) Purge(ctx context.Context, obj db.Object, retention time.Duration) (int, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return 0, err
+	}
+	if !obj.SoftDelete() {
+		return 0, errors.New(obj.Collection() + " not use soft delete")
+	}
+	query := &QueryMemory{
+		BaseQuery: db.BaseQuery{
+			QueryObject: obj,
+		},
+		client: c,
+	}
+	query.Where(db.DeleteTimeField, ">", time.Time{}).Where(db.DeleteTimeField, "<=", time.Now().UTC().Add(-retention))
+	snapshots, err := query.returnSnapshots(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "purge")
+	}
+	_, numDeleted, err := c.deleteSnapshots(obj.Collection(), len(snapshots)+1, snapshots)
+	if err != nil {
+		return 0, errors.Wrap(err, "purge")
+	}
+	return numDeleted, nil
+}
+
+// deleteSnapshots delete documents in collection. delete max doc count. return true if no doc left
+//
+//	done,delCount, err := deleteSnapshots(ctx, "Sample", 50, snapshots)
+//
+func (c *ClientMemory) deleteSnapshots(collection string, max int, snapshots []*snapshot) (bool, int, error) {
+	writes := []write{}
+	for _, s := range snapshots {
+		writes = append(writes, deleteWrite(collection, s.id))
+	}
+	if err := c.commit(writes...); err != nil {
+		return false, 0, errors.Wrapf(err, "commit delete")
+	}
+	numDeleted := len(snapshots)
+	if numDeleted < max {
+		return true, numDeleted, nil
+	}
+	return false, numDeleted, nil
+}
+
+// Truncate delete all document in collection. max 100 documents.
+// ! only use truncate in test
+//	done,numDeleted, err := Truncate(ctx, "Sample")
+//
+func (c *ClientMemory) Truncate(ctx context.Context, collectionName string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	max := 100
+	query := &QueryMemory{
+		client:     c,
+		collection: collectionName,
+		limit:      max,
+	}
+	snapshots, err := query.snapshots()
+	if err != nil {
+		return errors.Wrap(err, "truncate "+collectionName)
+	}
+	if _, _, err := c.deleteSnapshots(collectionName, max, snapshots); err != nil {
+		return errors.Wrap(err, "truncate "+collectionName)
+	}
+	return nil
+}
+
+// Counter return counter, numshards only used to keep the same behavior with other implementation
+//
+//	sampleCounter,err = Counter("SampleCount", 100)
+//
+func (c *ClientMemory) Counter(counterName string, numshards int) db.Counter {
+	if numshards <= 0 {
+		numshards = 10
+	}
+
+	return &CounterMemory{
+		MetaMemory: MetaMemory{
+			client:     c,
+			collection: "Count",
+			id:         counterName,
+			numShards:  numshards,
+		},
+	}
+}
+
+// Coder return coder, numshards is used to generate unique number the same way other implementation did
+//
+//	productCoder,err = Coder("coderName",100)
+//
+func (c *ClientMemory) Coder(coderName string, numshards int) db.Coder {
+	if numshards <= 0 {
+		numshards = 10
+	}
+
+	return &CoderMemory{
+		MetaMemory: MetaMemory{
+			client:     c,
+			collection: "Code",
+			id:         coderName,
+			numShards:  numshards,
+		},
+	}
+}
+
+// Serial return serial, create one if not exist
+//
+//	productNo,err = Serial("serialName")
+//
+func (c *ClientMemory) Serial(serialName string) db.Serial {
+	return &SerialMemory{
+		MetaMemory: MetaMemory{
+			client:     c,
+			collection: "Serial",
+			id:         serialName,
+			numShards:  1,
+		},
+	}
+}
@@ -0,0 +1,529 @@
+package mdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientClose(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	client := sampleClient()
+	assert.False(client.IsClose())
+	client.Close()
+	assert.True(client.IsClose())
+}
+
+func TestClientCRUD(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample := &Sample{
+		Name:  "test-client-CRUD",
+		Value: 1,
+	}
+	assert.Empty(sample.ID())
+
+	// return error if no id
+	_, err := client.Get(ctx, &Sample{}, "")
+	assert.NotNil(err)
+
+	// return nil if object not exists
+	o, err := client.Get(ctx, &Sample{}, "no id")
+	assert.Nil(err)
+	assert.Nil(o)
+
+	// not found
+	exist, err := client.Exists(ctx, &Sample{}, "no id")
+	assert.Nil(err)
+	assert.False(exist)
+
+	// set object with auto id
+	err = client.Set(ctx, sample)
+	assert.Nil(err)
+	assert.NotEmpty(sample.ID())
+
+	exist, err = client.Exists(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.True(exist)
+
+	// get saved object
+	sample2, err := client.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.NotNil(sample2)
+	assert.Equal(sample.ID(), sample2.ID())
+	assert.Equal(sample.Name, sample2.(*Sample).Name)
+	assert.False(sample2.CreateTime().IsZero())
+	assert.False(sample2.UpdateTime().IsZero())
+
+	// change on object will not change stored document until set
+	sample.Name = "modified"
+	sample3, err := client.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("test-client-CRUD", sample3.(*Sample).Name)
+
+	err = client.Set(ctx, sample)
+	assert.Nil(err)
+	sample3, err = client.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("modified", sample3.(*Sample).Name)
+
+	// set nil object
+	err = client.Set(ctx, nil)
+	assert.NotNil(err)
+
+	// delete object
+	id := sample2.ID()
+	err = client.Delete(ctx, sample2)
+	assert.Nil(err)
+	assert.Empty(sample2.ID())
+	exist, err = client.Exists(ctx, &Sample{}, id)
+	assert.Nil(err)
+	assert.False(exist)
+
+	// delete object without id
+	err = client.Delete(ctx, &Sample{})
+	assert.NotNil(err)
+}
+
+func TestClientUpdate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample := &Sample{
+		Name:  "test-client-update",
+		Value: 6,
+		PObj:  &PlainObject{Name: "plain"},
+	}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+
+	// not exists
+	value, err := client.Select(ctx, &Sample{}, "no id", "Value")
+	assert.Nil(err)
+	assert.Nil(value)
+
+	// found
+	value, err = client.Select(ctx, &Sample{}, sample.ID(), "Value")
+	assert.Nil(err)
+	assert.Equal(int64(6), value)
+
+	// nested field
+	value, err = client.Select(ctx, &Sample{}, sample.ID(), "PObj.Name")
+	assert.Nil(err)
+	assert.Equal("plain", value)
+
+	// field not exist
+	_, err = client.Select(ctx, &Sample{}, sample.ID(), "NotExist")
+	assert.NotNil(err)
+
+	// nothing to update will not error
+	err = client.Update(ctx, sample, nil)
+	assert.Nil(err)
+
+	err = client.Update(ctx, sample, map[string]interface{}{
+		"Name":  "updated",
+		"Value": 2,
+	})
+	assert.Nil(err)
+
+	name, err := client.Select(ctx, &Sample{}, sample.ID(), "Name")
+	assert.Nil(err)
+	assert.Equal("updated", name)
+
+	// update create object if not exist
+	notExist := &Sample{}
+	notExist.SetID("not-exist")
+	err = client.Update(ctx, notExist, map[string]interface{}{
+		"Name": "created",
+	})
+	assert.Nil(err)
+	created, err := client.Get(ctx, &Sample{}, "not-exist")
+	assert.Nil(err)
+	assert.Equal("created", created.(*Sample).Name)
+
+	// increment
+	err = client.Increment(ctx, &Sample{}, "Value", 3)
+	assert.NotNil(err)
+
+	err = client.Increment(ctx, sample, "Value", 3)
+	assert.Nil(err)
+
+	value, err = client.Select(ctx, &Sample{}, sample.ID(), "Value")
+	assert.Nil(err)
+	assert.Equal(int64(5), value)
+
+	// increment object not exist
+	noObj := &Sample{}
+	noObj.SetID("no-obj")
+	err = client.Increment(ctx, noObj, "Value", 3)
+	assert.NotNil(err)
+}
+
+func TestClientList(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	err := client.Set(ctx, &Sample{Name: "sample1", Value: 1001})
+	assert.Nil(err)
+	err = client.Set(ctx, &Sample{Name: "sample2", Value: 1002})
+	assert.Nil(err)
+
+	list, err := client.List(ctx, &Sample{}, 1)
+	assert.Nil(err)
+	assert.Len(list, 1)
+
+	list, err = client.List(ctx, &Sample{}, 10)
+	assert.Nil(err)
+	assert.Len(list, 2)
+
+	// test nil safe
+	sample := list[0].(*Sample)
+	assert.NotNil(sample.Map)
+	assert.NotNil(sample.Array)
+	assert.NotNil(sample.Numbers)
+	assert.NotNil(sample.PObj)
+
+	// no factory
+	err = client.Set(ctx, &SampleNoFactory{Name: "no factory"})
+	assert.Nil(err)
+	_, err = client.List(ctx, &SampleNoFactory{}, 10)
+	assert.NotNil(err)
+}
+
+func TestClientContextCanceled(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	client := sampleClient()
+
+	ctx := test.CanceledContext()
+	sample := &Sample{}
+	sample.SetID("id")
+
+	err := client.Set(ctx, sample)
+	assert.NotNil(err)
+	_, err = client.Get(ctx, &Sample{}, "no id")
+	assert.NotNil(err)
+	err = client.Delete(ctx, sample)
+	assert.NotNil(err)
+	_, err = client.List(ctx, &Sample{}, 1)
+	assert.NotNil(err)
+	_, err = client.Exists(ctx, &Sample{}, "no id")
+	assert.NotNil(err)
+	_, err = client.Select(ctx, &Sample{}, "not id", "Value")
+	assert.NotNil(err)
+	err = client.Update(ctx, sample, map[string]interface{}{
+		"Name": "ctx cancel",
+	})
+	assert.NotNil(err)
+	_, err = client.Query(&Sample{}).Return(ctx)
+	assert.NotNil(err)
+	err = client.Increment(ctx, sample, "Value", 2)
+	assert.NotNil(err)
+	err = client.Truncate(ctx, "Sample")
+	assert.NotNil(err)
+}
+
+func TestClientTruncate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	for i := 0; i < 3; i++ {
+		err := client.Set(ctx, &Sample{Name: "truncate"})
+		assert.Nil(err)
+	}
+
+	err := client.Truncate(ctx, "Sample")
+	assert.Nil(err)
+
+	count, err := client.Query(&Sample{}).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+}
+
+func TestClientIsolated(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client1 := sampleClient()
+	client2 := sampleClient()
+	sample := &Sample{Name: "isolated"}
+	err := client1.Set(ctx, sample)
+	assert.Nil(err)
+
+	exist, err := client2.Exists(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.False(exist)
+}
+
+func TestClientSoftDelete(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	sample := &SampleSoftDelete{Name: "soft"}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	id := sample.ID()
+	other := &SampleSoftDelete{Name: "other"}
+	err = client.Set(ctx, other)
+	assert.Nil(err)
+
+	err = client.Delete(ctx, sample)
+	assert.Nil(err)
+	sample.SetID(id)
+
+	// deleted object is hidden
+	obj, err := client.Get(ctx, &SampleSoftDelete{}, id)
+	assert.Nil(err)
+	assert.Nil(obj)
+	found, err := client.Exists(ctx, &SampleSoftDelete{}, id)
+	assert.Nil(err)
+	assert.False(found)
+	value, err := client.Select(ctx, &SampleSoftDelete{}, id, "Name")
+	assert.Nil(err)
+	assert.Nil(value)
+	list, err := client.List(ctx, &SampleSoftDelete{}, 10)
+	assert.Nil(err)
+	assert.Len(list, 1)
+	count, err := client.Query(&SampleSoftDelete{}).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(1, count)
+
+	// restore
+	err = client.Restore(ctx, sample)
+	assert.Nil(err)
+	obj, err = client.Get(ctx, &SampleSoftDelete{}, id)
+	assert.Nil(err)
+	assert.Equal("soft", obj.(*SampleSoftDelete).Name)
+	assert.True(obj.DeleteTime().IsZero())
+
+	// restore not exist object
+	err = client.Restore(ctx, &SampleSoftDelete{Name: "no id"})
+	assert.NotNil(err)
+	notExist := &SampleSoftDelete{}
+	notExist.SetID("notExist")
+	err = client.Restore(ctx, notExist)
+	assert.NotNil(err)
+
+	// delete in transaction and batch
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return tx.Delete(ctx, obj)
+	})
+	assert.Nil(err)
+	found, err = client.Exists(ctx, &SampleSoftDelete{}, id)
+	assert.Nil(err)
+	assert.False(found)
+	err = client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Delete(ctx, other)
+		return nil
+	})
+	assert.Nil(err)
+	count, err = client.Query(&SampleSoftDelete{}).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+
+	// purge respect retention
+	numPurged, err := client.Purge(ctx, &SampleSoftDelete{}, time.Hour)
+	assert.Nil(err)
+	assert.Equal(0, numPurged)
+	numPurged, err = client.Purge(ctx, &SampleSoftDelete{}, 0)
+	assert.Nil(err)
+	assert.Equal(2, numPurged)
+	err = client.Restore(ctx, sample)
+	assert.NotNil(err)
+
+	// object not use soft delete
+	notSoft := &Sample{}
+	notSoft.SetID("a")
+	err = client.Restore(ctx, notSoft)
+	assert.NotNil(err)
+	_, err = client.Purge(ctx, &Sample{}, 0)
+	assert.NotNil(err)
+}
+
+func TestClientSoftDeleteMissing(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	collection := (&SampleSoftDelete{}).Collection()
+
+	missing := func(id string) *SampleSoftDelete {
+		obj := &SampleSoftDelete{}
+		obj.SetID(id)
+		return obj
+	}
+	err := client.Delete(ctx, missing("missing1"))
+	assert.Nil(err)
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return tx.Delete(ctx, missing("missing2"))
+	})
+	assert.Nil(err)
+	err = client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Delete(ctx, missing("missing3"))
+		return nil
+	})
+	assert.Nil(err)
+
+	// nothing written
+	memory := client.(*ClientMemory)
+	for _, id := range []string{"missing1", "missing2", "missing3"} {
+		assert.Nil(memory.doc(collection, id))
+		err = client.Restore(ctx, missing(id))
+		assert.NotNil(err)
+	}
+	numPurged, err := client.Purge(ctx, &SampleSoftDelete{}, 0)
+	assert.Nil(err)
+	assert.Equal(0, numPurged)
+}
+
+func TestClientDeleteTimeField(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	memory := client.(*ClientMemory)
+
+	// only object use soft delete save delete time
+	sample := &Sample{Name: "plain"}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	_, found := memory.doc(sample.Collection(), sample.ID())[db.DeleteTimeField]
+	assert.False(found)
+	soft := &SampleSoftDelete{Name: "soft"}
+	err = client.Set(ctx, soft)
+	assert.Nil(err)
+	_, found = memory.doc(soft.Collection(), soft.ID())[db.DeleteTimeField]
+	assert.True(found)
+
+	// update create soft delete object with delete time, so query can find it
+	created := func(id string) *SampleSoftDelete {
+		obj := &SampleSoftDelete{}
+		obj.SetID(id)
+		return obj
+	}
+	err = client.Update(ctx, created("update1"), map[string]interface{}{"Name": "update"})
+	assert.Nil(err)
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return tx.Update(ctx, created("update2"), map[string]interface{}{"Name": "update"})
+	})
+	assert.Nil(err)
+	err = client.Batch(ctx, func(ctx context.Context, batch db.Batch) error {
+		batch.Update(ctx, created("update3"), map[string]interface{}{"Name": "update"})
+		return nil
+	})
+	assert.Nil(err)
+	count, err := client.Query(&SampleSoftDelete{}).Where("Name", "==", "update").Count(ctx)
+	assert.Nil(err)
+	assert.Equal(3, count)
+
+	// update existing deleted object keep it deleted
+	id := soft.ID()
+	err = client.Delete(ctx, soft)
+	assert.Nil(err)
+	err = client.Update(ctx, created(id), map[string]interface{}{"Name": "update"})
+	assert.Nil(err)
+	count, err = client.Query(&SampleSoftDelete{}).Where("Name", "==", "update").Count(ctx)
+	assert.Nil(err)
+	assert.Equal(3, count)
+}
+
+func TestClientBackfillDeleteTime(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	memory := client.(*ClientMemory)
+	collection := (&SampleSoftDelete{}).Collection()
+
+	// object saved before soft delete enabled has no delete time field
+	memory.put(collection, "old", map[string]interface{}{"Name": "old"})
+	sample := &SampleSoftDelete{Name: "deleted"}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	err = client.Delete(ctx, sample)
+	assert.Nil(err)
+
+	count, err := client.Query(&SampleSoftDelete{}).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+	count, err = client.QueryWithDeleted(&SampleSoftDelete{}).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(2, count)
+
+	updated, err := db.BackfillDeleteTime(ctx, client, &SampleSoftDelete{})
+	assert.Nil(err)
+	assert.Equal(1, updated)
+	list, err := client.Query(&SampleSoftDelete{}).Return(ctx)
+	assert.Nil(err)
+	assert.Len(list, 1)
+	assert.Equal("old", list[0].(*SampleSoftDelete).Name)
+
+	_, err = db.BackfillDeleteTime(ctx, client, &Sample{})
+	assert.NotNil(err)
+}
+
+func TestClientVersion(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	sample := &SampleVersioned{Name: "v1"}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	assert.Equal(int64(1), sample.Version())
+
+	// two reader
+	obj1, err := client.Get(ctx, &SampleVersioned{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal(int64(1), obj1.Version())
+	obj2, err := client.Get(ctx, &SampleVersioned{}, sample.ID())
+	assert.Nil(err)
+
+	obj1.(*SampleVersioned).Name = "v2"
+	err = client.Set(ctx, obj1)
+	assert.Nil(err)
+	assert.Equal(int64(2), obj1.Version())
+
+	// lost update detected
+	obj2.(*SampleVersioned).Name = "lost"
+	err = client.Set(ctx, obj2)
+	assert.True(errors.Is(err, db.ErrConflict))
+	assert.Equal(int64(1), obj2.Version())
+	err = client.Update(ctx, obj2, map[string]interface{}{"Name": "lost"})
+	assert.True(errors.Is(err, db.ErrConflict))
+
+	// update increment version
+	err = client.Update(ctx, obj1, map[string]interface{}{"Name": "v3"})
+	assert.Nil(err)
+	assert.Equal(int64(3), obj1.Version())
+	obj, err := client.Get(ctx, &SampleVersioned{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("v3", obj.(*SampleVersioned).Name)
+	assert.Equal(int64(3), obj.Version())
+
+	// new object with existing id
+	dup := &SampleVersioned{Name: "dup"}
+	dup.SetID(sample.ID())
+	err = client.Set(ctx, dup)
+	assert.True(errors.Is(err, db.ErrConflict))
+
+	// object not versioned never conflict
+	plain := &Sample{Name: "plain"}
+	err = client.Set(ctx, plain)
+	assert.Nil(err)
+	assert.Equal(int64(0), plain.Version())
+}
@@ -0,0 +1,94 @@
+package mdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoderNum(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	coder := client.Coder("test-coder-num", 1)
+
+	var firstNum int64
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		num, err := coder.NumberRX(ctx, tx)
+		assert.Nil(err)
+		firstNum = num
+		return coder.NumberWX(ctx, tx)
+	})
+	assert.Nil(err)
+	assert.True(firstNum > 0)
+
+	shardsCount, err := coder.ShardsCount(ctx)
+	assert.Nil(err)
+	assert.Equal(1, shardsCount)
+
+	// fail should not change number
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		_, err := coder.NumberRX(ctx, tx)
+		assert.Nil(err)
+		err = coder.NumberWX(ctx, tx)
+		assert.Nil(err)
+		return errors.New("fail")
+	})
+	assert.NotNil(err)
+
+	var secondNum int64
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		num, err := coder.NumberRX(ctx, tx)
+		assert.Nil(err)
+		secondNum = num
+		return coder.NumberWX(ctx, tx)
+	})
+	assert.Nil(err)
+	assert.Equal(firstNum+1, secondNum)
+
+	err = coder.Delete(ctx)
+	assert.Nil(err)
+	shardsCount, err = coder.ShardsCount(ctx)
+	assert.Nil(err)
+	assert.Equal(0, shardsCount)
+}
+
+func TestCoderCode(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	coder := client.Coder("test-coder-code", 10)
+
+	codes := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+			code, err := coder.CodeRX(ctx, tx)
+			assert.Nil(err)
+			codes[code] = true
+			return coder.CodeWX(ctx, tx)
+		})
+		assert.Nil(err)
+	}
+	assert.Len(codes, 20)
+
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		code, err := coder.Code16RX(ctx, tx)
+		assert.Nil(err)
+		assert.NotEmpty(code)
+		return coder.Code16WX(ctx, tx)
+	})
+	assert.Nil(err)
+
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		code, err := coder.Code64RX(ctx, tx)
+		assert.Nil(err)
+		assert.NotEmpty(code)
+		return coder.Code64WX(ctx, tx)
+	})
+	assert.Nil(err)
+}
@@ -0,0 +1,148 @@
+package mdb
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/log"
+	"github.com/pkg/errors"
+)
+
+// CounterMemory implement Counter
+//
+type CounterMemory struct {
+	db.Counter
+
+	MetaMemory
+
+	callRX bool
+
+	// pickedShard is a shard random picked
+	//
+	pickedShard string
+
+	// shardExists return true if shard exists
+	//
+	shardExists bool
+}
+
+// shardAllID return picked all period shard id
+//
+func (c *CounterMemory) shardAllID() string {
+	return c.id + string(db.HierarchyTotal) + "_" + c.pickedShard
+}
+
+// IncrementRX increments a randomly picked shard. must used it in transaction with IncrementWX()
+//
+//	err := Transaction(ctx, func(ctx context.Context,tx db.Transaction) error {
+//		err = counter.IncrementRX(ctx,transaction)
+//		err = counter.IncrementWX(ctx,transaction,1)
+//	})
+//
+func (c *CounterMemory) IncrementRX(ctx context.Context, transaction db.Transaction) error {
+	tx := transaction.(*TransactionMemory)
+	c.callRX = true
+	c.pickedShard = strconv.Itoa(rand.Intn(c.numShards)) //random pick a shard
+	log.Debug(ctx, "counter pick %v from %v shards", c.pickedShard, c.numShards)
+	var err error
+	c.shardExists, err = tx.isShardExists(c.collection, c.shardAllID())
+	if err != nil {
+		return errors.Wrap(err, "all")
+	}
+	return nil
+}
+
+// IncrementWX commit IncrementRX()
+//
+//	err := Transaction(ctx, func(ctx context.Context,tx db.Transaction) error {
+//		err = counter.IncrementRX(ctx,transaction)
+//		err = counter.IncrementWX(ctx,transaction,1)
+//	})
+//
+func (c *CounterMemory) IncrementWX(ctx context.Context, transaction db.Transaction, value interface{}) error {
+	tx := transaction.(*TransactionMemory)
+	if !c.callRX {
+		return errors.New("must call RX first")
+	}
+
+	utcNow := time.Now().UTC()
+	shard := map[string]interface{}{
+		db.MetaID:      c.id,
+		db.MetaN:       value,
+		db.CounterTime: utcNow,
+	}
+
+	if c.shardExists {
+		if err := tx.incrementShard(c.collection, c.shardAllID(), value); err != nil {
+			return errors.Wrap(err, "inc all")
+		}
+	} else {
+		shard[db.CounterDateLevel] = db.HierarchyTotal
+		if err := tx.createShard(c.collection, c.shardAllID(), shard); err != nil {
+			return errors.Wrap(err, "create all")
+		}
+	}
+	c.callRX = false
+	return nil
+}
+
+// CountAll return a total count across all period
+//
+//	count, err = counter.CountAll(ctx)
+//
+func (c *CounterMemory) CountAll(ctx context.Context) (float64, error) {
+	if err := c.check(ctx); err != nil {
+		return 0, err
+	}
+	shards, err := c.shards(levelFilter(db.HierarchyTotal))
+	if err != nil {
+		return 0, errors.Wrapf(err, "shards %v-%v", c.collection, c.id)
+	}
+	return c.countValue(shards)
+}
+
+// CountPeriod return count between from and to
+//
+//	from := time.Date(now.Year()-1, 01, 01, 0, 0, 0, 0, time.UTC)
+//	to := time.Date(now.Year()+1, 01, 01, 0, 0, 0, 0, time.UTC)
+//	count, err := counter.CountPeriod(ctx, HierarchyYear, from, to)
+//
+func (c *CounterMemory) CountPeriod(ctx context.Context, hierarchy db.Hierarchy, from, to time.Time) (float64, error) {
+	if err := c.check(ctx); err != nil {
+		return 0, err
+	}
+	shards, err := c.shards(
+		levelFilter(hierarchy),
+		filter{path: db.CounterTime, op: ">=", value: from.UTC()},
+		filter{path: db.CounterTime, op: "<=", value: to.UTC()},
+	)
+	if err != nil {
+		return 0, errors.Wrapf(err, "shards %v-%v", c.collection, c.id)
+	}
+	return c.countValue(shards)
+}
+
+// levelFilter return filter on counter date level
+//
+func levelFilter(hierarchy db.Hierarchy) filter {
+	return filter{path: db.CounterDateLevel, op: "==", value: string(hierarchy)}
+}
+
+// Delete delete counter
+//
+//	err = Delete(ctx)
+//
+func (c *CounterMemory) Delete(ctx context.Context) error {
+	return c.deleteShards(ctx)
+}
+
+// ShardsCount returns shards count
+//
+//	count, err = ShardsCount(ctx)
+//
+func (c *CounterMemory) ShardsCount(ctx context.Context) (int, error) {
+	return c.shardsCount(ctx)
+}
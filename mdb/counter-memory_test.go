@@ -0,0 +1,93 @@
+package mdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	counter := client.Counter("test-counter", 1)
+
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		err := counter.IncrementRX(ctx, tx)
+		assert.Nil(err)
+		return counter.IncrementWX(ctx, tx, 1)
+	})
+	assert.Nil(err)
+
+	shardsCount, err := counter.ShardsCount(ctx)
+	assert.Nil(err)
+	assert.Equal(1, shardsCount)
+
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		err := counter.IncrementRX(ctx, tx)
+		assert.Nil(err)
+		return counter.IncrementWX(ctx, tx, 2)
+	})
+	assert.Nil(err)
+
+	count, err := counter.CountAll(ctx)
+	assert.Nil(err)
+	assert.Equal(float64(3), count)
+
+	now := time.Now().UTC()
+	count, err = counter.CountPeriod(ctx, db.HierarchyTotal, now.Add(-time.Hour), now.Add(time.Hour))
+	assert.Nil(err)
+	assert.Equal(float64(3), count)
+
+	err = counter.Delete(ctx)
+	assert.Nil(err)
+
+	shardsCount, err = counter.ShardsCount(ctx)
+	assert.Nil(err)
+	assert.Equal(0, shardsCount)
+}
+
+func TestCounterWXWithoutRX(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	counter := client.Counter("test-counter-wx", 0)
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return counter.IncrementWX(ctx, tx, 1)
+	})
+	assert.NotNil(err)
+}
+
+func TestCounterConcurrent(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	var wg sync.WaitGroup
+	concurrent := 10
+	wg.Add(concurrent)
+	for i := 0; i < concurrent; i++ {
+		go func() {
+			defer wg.Done()
+			counter := client.Counter("test-counter-concurrent", 3)
+			client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+				if err := counter.IncrementRX(ctx, tx); err != nil {
+					return err
+				}
+				return counter.IncrementWX(ctx, tx, 1)
+			})
+		}()
+	}
+	wg.Wait()
+
+	count, err := client.Counter("test-counter-concurrent", 3).CountAll(ctx)
+	assert.Nil(err)
+	assert.Equal(float64(concurrent), count)
+}
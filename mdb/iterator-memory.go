@@ -0,0 +1,132 @@
+package mdb
+
+import (
+	"context"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+)
+
+// IteratorMemory walk through query result page by page, every page start after last document of previous page
+//
+type IteratorMemory struct {
+	db.Iterator
+
+	// ctx is context used to read document
+	//
+	ctx context.Context
+
+	// query is query to iterate
+	//
+	query *QueryMemory
+
+	// pageSize is document count read in one page
+	//
+	pageSize int
+
+	// page is documents in current page
+	//
+	page []*snapshot
+
+	// index is current document index in page
+	//
+	index int
+
+	// last is last document read, next page will start after it
+	//
+	last *snapshot
+
+	// current is current object
+	//
+	current db.Object
+
+	// err is error happen in Next()
+	//
+	err error
+
+	// done is true if no more document
+	//
+	done bool
+}
+
+// Next move to next object, return false when no more object or error happen, use Err() to check error
+//
+//	for iter.Next() {
+//		sample := iter.Object().(*Sample)
+//	}
+//
+func (c *IteratorMemory) Next() bool {
+	c.current = nil
+	if c.err != nil || c.done {
+		return false
+	}
+	if c.ctx.Err() != nil {
+		c.err = c.ctx.Err()
+		c.Close()
+		return false
+	}
+	if c.index >= len(c.page) {
+		if c.page != nil && len(c.page) < c.pageSize {
+			c.Close()
+			return false
+		}
+		c.query.limit = c.pageSize
+		if c.last != nil {
+			c.query.start = &cursor{values: c.last.values, id: c.last.id}
+		}
+		page, err := c.query.snapshots()
+		if err != nil {
+			c.err = err
+			c.Close()
+			return false
+		}
+		c.page = page
+		c.index = 0
+		if len(page) == 0 {
+			c.Close()
+			return false
+		}
+	}
+
+	s := c.page[c.index]
+	c.index++
+	c.last = s
+	obj := c.query.QueryObject.Factory()
+	if obj == nil {
+		c.err = errors.New(c.query.QueryObject.Collection() + " not implement Factory()")
+		c.Close()
+		return false
+	}
+	if _, err := docToObject(obj, s.id, s.doc); err != nil {
+		c.err = err
+		c.Close()
+		return false
+	}
+	c.current = obj
+	return true
+}
+
+// Object return current object, return nil if Next() never been called or return false
+//
+//	sample := iter.Object().(*Sample)
+//
+func (c *IteratorMemory) Object() db.Object {
+	return c.current
+}
+
+// Err return error happen in Next(), return ctx.Err() if context is canceled
+//
+//	err := iter.Err()
+//
+func (c *IteratorMemory) Err() error {
+	return c.err
+}
+
+// Close release resource used by iterator, it is safe to call Close() multiple times
+//
+//	defer iter.Close()
+//
+func (c *IteratorMemory) Close() {
+	c.page = nil
+	c.done = true
+}
@@ -0,0 +1,116 @@
+package mdb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterator(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	for i := 0; i < 25; i++ {
+		err := client.Set(ctx, &Sample{Name: "iterator", Value: i%5 + 1})
+		assert.Nil(err)
+	}
+
+	// default limit 20 and same order value will not stop iterator
+	iter := client.Query(&Sample{}).OrderBy("Value").Iterate(ctx, 3)
+	defer iter.Close()
+	count := 0
+	last := -1
+	ids := map[string]bool{}
+	for iter.Next() {
+		sample := iter.Object().(*Sample)
+		assert.True(sample.Value >= last)
+		last = sample.Value
+		ids[sample.ID()] = true
+		count++
+	}
+	assert.Nil(iter.Err())
+	assert.Equal(25, count)
+	assert.Len(ids, 25)
+	assert.False(iter.Next())
+	assert.Nil(iter.Object())
+
+	// page size equal to document count
+	iter = client.Query(&Sample{}).Where("Value", "==", 1).Iterate(ctx, 5)
+	count = 0
+	for iter.Next() {
+		count++
+	}
+	iter.Close()
+	assert.Nil(iter.Err())
+	assert.Equal(5, count)
+}
+
+func TestIteratorKeepQuery(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	for i := 0; i < 5; i++ {
+		err := client.Set(ctx, &Sample{Name: "keep", Value: i + 1})
+		assert.Nil(err)
+	}
+
+	// iterate will not change limit and cursor of query
+	query := client.Query(&Sample{}).OrderBy("Value").StartAt(2).Limit(2)
+	iter := query.Iterate(ctx, 1)
+	count := 0
+	for iter.Next() {
+		count++
+	}
+	iter.Close()
+	assert.Nil(iter.Err())
+	assert.Equal(4, count)
+
+	list, err := query.Return(ctx)
+	assert.Nil(err)
+	assert.Len(list, 2)
+	assert.Equal(2, list[0].(*Sample).Value)
+	assert.Equal(3, list[1].(*Sample).Value)
+}
+
+func TestIteratorError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	for i := 0; i < 3; i++ {
+		err := client.Set(ctx, &Sample{Name: "iterator-" + strconv.Itoa(i)})
+		assert.Nil(err)
+	}
+
+	// no obj
+	iter := client.Query(nil).Iterate(ctx, 10)
+	assert.False(iter.Next())
+	assert.NotNil(iter.Err())
+
+	// canceled context
+	iter = client.Query(&Sample{}).Iterate(test.CanceledContext(), 10)
+	assert.False(iter.Next())
+	assert.NotNil(iter.Err())
+
+	// canceled while iterate
+	ctxCancel, cancel := context.WithCancel(ctx)
+	iter = client.Query(&Sample{}).Iterate(ctxCancel, 1)
+	assert.True(iter.Next())
+	cancel()
+	assert.False(iter.Next())
+	assert.Equal(context.Canceled, iter.Err())
+
+	// not support in transaction
+	client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		iter := tx.Query(&Sample{}).Iterate(ctx, 10)
+		assert.False(iter.Next())
+		assert.NotNil(iter.Err())
+		return nil
+	})
+}
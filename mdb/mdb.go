@@ -0,0 +1,22 @@
+package mdb
+
+import (
+	"context"
+
+	"github.com/piyuo/libsrv/db"
+)
+
+// NewClient create in-memory db client, data only live in client and will not share with other client, use it to test business logic without network or credentials
+//
+//	client, err := NewClient(ctx)
+//	defer client.Close()
+//
+func NewClient(ctx context.Context) (db.Client, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	client := &ClientMemory{
+		collections: map[string]map[string]map[string]interface{}{},
+	}
+	return client, nil
+}
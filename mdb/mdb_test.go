@@ -0,0 +1,26 @@
+package mdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/piyuo/libsrv/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMdb(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client, err := NewClient(ctx)
+	assert.Nil(err)
+	assert.NotNil(client)
+}
+
+func TestInCanceledContext(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	client, err := NewClient(test.CanceledContext())
+	assert.NotNil(err)
+	assert.Nil(client)
+}
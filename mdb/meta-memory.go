@@ -0,0 +1,106 @@
+package mdb
+
+import (
+	"context"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/util"
+	"github.com/pkg/errors"
+)
+
+// MetaMemory is parent of counter/coder/serial, provide basic function like deleteShards() and countValue()
+//
+type MetaMemory struct {
+
+	// client is db client
+	//
+	client *ClientMemory
+
+	// numShards is number of shards
+	//
+	numShards int
+
+	// collection is collection name
+	//
+	collection string
+
+	// id is document id in collection
+	//
+	id string
+}
+
+// check check ctx, table name, id are valid
+//
+func (c *MetaMemory) check(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if c.collection == "" {
+		return errors.New("collection must not empty")
+	}
+	if c.id == "" {
+		return errors.New("id must no empty")
+	}
+	return nil
+}
+
+// shards return shards match filters
+//
+func (c *MetaMemory) shards(filters ...filter) ([]*snapshot, error) {
+	query := &QueryMemory{
+		client:     c.client,
+		collection: c.collection,
+		filters:    append([]filter{{path: db.MetaID, op: "==", value: c.id}}, filters...),
+	}
+	return query.snapshots()
+}
+
+// deleteShards delete all shards
+//
+//	err = deleteShards(ctx)
+//
+func (c *MetaMemory) deleteShards(ctx context.Context) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	shards, err := c.shards()
+	if err != nil {
+		return errors.Wrap(err, "del shards "+c.collection)
+	}
+	if _, _, err := c.client.deleteSnapshots(c.collection, c.numShards+1, shards); err != nil {
+		return errors.Wrap(err, "del shards "+c.collection)
+	}
+	return nil
+}
+
+// shardsCount returns shards count
+//
+//	count, err = shardsCount(ctx)
+//
+func (c *MetaMemory) shardsCount(ctx context.Context) (int, error) {
+	if err := c.check(ctx); err != nil {
+		return 0, err
+	}
+	shards, err := c.shards()
+	if err != nil {
+		return 0, errors.Wrapf(err, "shards %v-%v", c.collection, c.id)
+	}
+	return len(shards), nil
+}
+
+// countValue returns a total value count on given shards
+//
+//	count, err = counter.countValue(shards)
+//
+func (c *MetaMemory) countValue(shards []*snapshot) (float64, error) {
+	var total float64
+	for _, shard := range shards {
+		iTotal := shard.doc[db.MetaN]
+		shardCount, err := util.ToFloat64(iTotal)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid dataType %T want float64 %v-%v", iTotal, c.collection, c.id)
+		}
+		total += shardCount
+	}
+	return total, nil
+}
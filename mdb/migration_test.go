@@ -0,0 +1,225 @@
+package mdb
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// defaultTag set tag to default if it is empty
+//
+func defaultTag(ctx context.Context, obj db.Object) (bool, error) {
+	sample := obj.(*Sample)
+	if sample.Tag != "" {
+		return false, nil
+	}
+	sample.Tag = "default"
+	return true, nil
+}
+
+func TestMigration(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	total := db.MigrationPageSize + 50
+	for i := 0; i < total; i++ {
+		sample := &Sample{Name: "n" + strconv.Itoa(i)}
+		if i%2 == 0 {
+			sample.Tag = "t"
+		}
+		err := client.Set(ctx, sample)
+		assert.Nil(err)
+	}
+
+	migrator := db.NewMigrator(client).Register(1, "default-tag", &Sample{}, defaultTag)
+
+	// dry run will not write
+	results, err := migrator.Run(ctx, true)
+	assert.Nil(err)
+	assert.Len(results, 1)
+	assert.Equal(total, results[0].Scanned)
+	assert.Equal(total/2, results[0].Changed)
+	count, err := client.Query(&Sample{}).Where("Tag", "==", "default").Count(ctx)
+	assert.Nil(err)
+	assert.Equal(0, count)
+	applied, err := migrator.Applied(ctx)
+	assert.Nil(err)
+	assert.Empty(applied)
+
+	results, err = migrator.Run(ctx, false)
+	assert.Nil(err)
+	assert.Len(results, 1)
+	assert.Equal(total, results[0].Scanned)
+	assert.Equal(total/2, results[0].Changed)
+	count, err = client.Query(&Sample{}).Where("Tag", "==", "default").Count(ctx)
+	assert.Nil(err)
+	assert.Equal(total/2, count)
+	applied, err = migrator.Applied(ctx)
+	assert.Nil(err)
+	assert.Equal([]int{1}, applied)
+
+	obj, err := client.Get(ctx, &db.MigrationRecord{}, "1")
+	assert.Nil(err)
+	record := obj.(*db.MigrationRecord)
+	assert.Equal("default-tag", record.Name)
+	assert.True(record.Done)
+	assert.Empty(record.Token)
+	assert.Equal(total, record.Scanned)
+
+	// applied migration will be skipped
+	results, err = migrator.Run(ctx, false)
+	assert.Nil(err)
+	assert.Empty(results)
+
+	// name changed
+	_, err = db.NewMigrator(client).Register(1, "renamed", &Sample{}, defaultTag).Run(ctx, false)
+	assert.NotNil(err)
+}
+
+func TestMigrationResume(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	total := db.MigrationPageSize * 2
+	for i := 0; i < total; i++ {
+		err := client.Set(ctx, &Sample{Name: "n" + strconv.Itoa(i)})
+		assert.Nil(err)
+	}
+
+	// fail on second page
+	calls := 0
+	failOnce := func(ctx context.Context, obj db.Object) (bool, error) {
+		calls++
+		if calls == db.MigrationPageSize+1 {
+			return false, errors.New("interrupted")
+		}
+		return defaultTag(ctx, obj)
+	}
+	results, err := db.NewMigrator(client).Register(1, "default-tag", &Sample{}, failOnce).Run(ctx, false)
+	assert.NotNil(err)
+	assert.Len(results, 1)
+	assert.Equal(db.MigrationPageSize, results[0].Changed)
+
+	obj, err := client.Get(ctx, &db.MigrationRecord{}, "1")
+	assert.Nil(err)
+	record := obj.(*db.MigrationRecord)
+	assert.False(record.Done)
+	assert.NotEmpty(record.Token)
+	assert.Equal(db.MigrationPageSize, record.Changed)
+
+	// resume from second page
+	results, err = db.NewMigrator(client).Register(1, "default-tag", &Sample{}, failOnce).Run(ctx, false)
+	assert.Nil(err)
+	assert.Equal(db.MigrationPageSize, results[0].Scanned)
+	assert.Equal(db.MigrationPageSize, results[0].Changed)
+	count, err := client.Query(&Sample{}).Where("Tag", "==", "default").Count(ctx)
+	assert.Nil(err)
+	assert.Equal(total, count)
+}
+
+func TestMigrationOrder(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	err := client.Set(ctx, &Sample{Name: "a"})
+	assert.Nil(err)
+
+	order := []int{}
+	migrateFunc := func(version int) db.MigrateFunc {
+		return func(ctx context.Context, obj db.Object) (bool, error) {
+			order = append(order, version)
+			return false, nil
+		}
+	}
+	migrator := db.NewMigrator(client).
+		Register(3, "three", &Sample{}, migrateFunc(3)).
+		Register(1, "one", &Sample{}, migrateFunc(1)).
+		Register(2, "two", &Sample{}, migrateFunc(2))
+	results, err := migrator.Run(ctx, false)
+	assert.Nil(err)
+	assert.Len(results, 3)
+	assert.Equal([]int{1, 2, 3}, order)
+
+	// invalid register
+	_, err = db.NewMigrator(client).Register(0, "zero", &Sample{}, migrateFunc(0)).Run(ctx, false)
+	assert.NotNil(err)
+	_, err = db.NewMigrator(client).Register(1, "one", &Sample{}, migrateFunc(1)).Register(1, "dup", &Sample{}, migrateFunc(1)).Run(ctx, false)
+	assert.NotNil(err)
+	_, err = db.NewMigrator(client).Register(1, "", &Sample{}, migrateFunc(1)).Applied(ctx)
+	assert.NotNil(err)
+	_, err = db.NewMigrator(client).Register(1, "one", nil, migrateFunc(1)).Run(ctx, false)
+	assert.NotNil(err)
+}
+
+func TestMigrationSoftDeleted(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	live := &SampleSoftDelete{Name: "live"}
+	err := client.Set(ctx, live)
+	assert.Nil(err)
+	deleted := &SampleSoftDelete{Name: "deleted"}
+	err = client.Set(ctx, deleted)
+	assert.Nil(err)
+	err = client.Delete(ctx, deleted)
+	assert.Nil(err)
+
+	// soft deleted object is migrated too
+	rename := func(ctx context.Context, obj db.Object) (bool, error) {
+		sample := obj.(*SampleSoftDelete)
+		sample.Name = "migrated-" + sample.ID()
+		return true, nil
+	}
+	results, err := db.NewMigrator(client).Register(1, "rename", &SampleSoftDelete{}, rename).Run(ctx, false)
+	assert.Nil(err)
+	assert.Equal(2, results[0].Scanned)
+	assert.Equal(2, results[0].Changed)
+	list, err := client.QueryWithDeleted(&SampleSoftDelete{}).Return(ctx)
+	assert.Nil(err)
+	assert.Len(list, 2)
+	for _, obj := range list {
+		assert.Equal("migrated-"+obj.ID(), obj.(*SampleSoftDelete).Name)
+	}
+}
+
+func TestMigrationVersioned(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample := &SampleVersioned{Name: "a"}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+
+	// object changed by others during migration is read and migrated again, change is not lost
+	calls := 0
+	suffix := func(ctx context.Context, obj db.Object) (bool, error) {
+		calls++
+		if calls == 1 {
+			other, err := client.Get(ctx, &SampleVersioned{}, obj.ID())
+			assert.Nil(err)
+			other.(*SampleVersioned).Name = "changed"
+			assert.Nil(client.Set(ctx, other))
+		}
+		versioned := obj.(*SampleVersioned)
+		versioned.Name += "-m"
+		return true, nil
+	}
+	results, err := db.NewMigrator(client).Register(1, "suffix", &SampleVersioned{}, suffix).Run(ctx, false)
+	assert.Nil(err)
+	assert.Equal(2, calls)
+	assert.Equal(1, results[0].Scanned)
+	assert.Equal(1, results[0].Changed)
+	obj, err := client.Get(ctx, &SampleVersioned{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("changed-m", obj.(*SampleVersioned).Name)
+	assert.Equal(int64(3), obj.Version())
+}
@@ -0,0 +1,894 @@
+package mdb
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/log"
+	"github.com/piyuo/libsrv/util"
+	"github.com/pkg/errors"
+)
+
+// documentID is path used to filter on document id
+//
+const documentID = "__name__"
+
+// filter is query filter
+//
+type filter struct {
+	path  string
+	op    string
+	value interface{}
+}
+
+// order is query order
+//
+type order struct {
+	path string
+	desc bool
+}
+
+// cursor is query start/end position
+//
+type cursor struct {
+	values []interface{}
+
+	// inclusive is true if document on cursor position should be include, like StartAt() and EndAt()
+	//
+	inclusive bool
+
+	// id is document id on cursor position, it is used when documents has the same values, empty mean not compare id
+	//
+	id string
+
+	// page is true if cursor come from page token, page token must have value for every order field
+	//
+	page bool
+}
+
+// snapshot is document read from collection
+//
+type snapshot struct {
+	id  string
+	doc map[string]interface{}
+
+	// values is document values on query order fields
+	//
+	values []interface{}
+}
+
+// QueryMemory implement in-memory query
+//
+type QueryMemory struct {
+	db.BaseQuery
+
+	// client is memory client
+	//
+	client *ClientMemory
+
+	// collection is collection to query, it is QueryObject's collection
+	//
+	collection string
+
+	// filters is query filters, all filter must match
+	//
+	filters []filter
+
+	// disjunctions is and-groups from or filter, document must match at least one group, nil mean no or filter
+	//
+	disjunctions [][]filter
+
+	// orders is query order
+	//
+	orders []order
+
+	// limit is max document to return, 0 mean no limit
+	//
+	limit int
+
+	// limited is true if limit set by Limit(), default limit set when query created is not counted
+	//
+	limited bool
+
+	// start is query start position
+	//
+	start *cursor
+
+	// end is query end position
+	//
+	end *cursor
+
+	// encrypt is true if page token need encrypt
+	//
+	encrypt bool
+
+	// err keep error happen when build query, it will be return when query execute
+	//
+	err error
+}
+
+// clone return copy of query, filter, order and cursor are copied so change on copy like page cursor will not affect original query
+//
+func (c *QueryMemory) clone() *QueryMemory {
+	query := *c
+	query.filters = append([]filter(nil), c.filters...)
+	query.orders = append([]order(nil), c.orders...)
+	if c.disjunctions != nil {
+		query.disjunctions = make([][]filter, len(c.disjunctions))
+		for i, group := range c.disjunctions {
+			query.disjunctions[i] = append([]filter(nil), group...)
+		}
+	}
+	if c.start != nil {
+		start := *c.start
+		query.start = &start
+	}
+	if c.end != nil {
+		end := *c.end
+		query.end = &end
+	}
+	return &query
+}
+
+// Where set filter, if path == "ID" mean using document id in as filter
+//
+//	list, err := Query(&Sample{}).Where("ID", "==", "sample1").Return(ctx)
+//
+func (c *QueryMemory) Where(path, op string, value interface{}) db.Query {
+	f, err := c.newFilter(path, op, value)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.filters = append(c.filters, f)
+	return c
+}
+
+// newFilter create filter, if path == "ID" mean using document id in as filter
+//
+func (c *QueryMemory) newFilter(path, op string, value interface{}) (filter, error) {
+	if c.QueryObject != nil && path == "ID" {
+		path = documentID
+	}
+	if err := db.AssertOp(op); err != nil {
+		return filter{}, err
+	}
+	v, err := toValue(reflect.ValueOf(value))
+	if err != nil {
+		return filter{}, errors.Wrapf(err, "where %v %v", path, op)
+	}
+	return filter{path: path, op: op, value: v}, nil
+}
+
+// WhereFilter set composite filter, it combine with other filter using and. or filter can not use with OrderBy() and cursor
+//
+//	list, err := Query(&Sample{}).WhereFilter(db.Or(
+//		db.Field("Tag", "in", []string{"a", "b"}),
+//		db.Field("Array", "array-contains", "x"),
+//	)).Return(ctx)
+//
+func (c *QueryMemory) WhereFilter(f *db.Filter) db.Query {
+	groups, err := f.Disjunctions()
+	if err != nil {
+		c.err = err
+		return c
+	}
+	disjunctions := [][]filter{}
+	for _, group := range groups {
+		filters := []filter{}
+		for _, field := range group {
+			item, err := c.newFilter(field.Path, field.Op, field.Value)
+			if err != nil {
+				c.err = err
+				return c
+			}
+			filters = append(filters, item)
+		}
+		disjunctions = append(disjunctions, filters)
+	}
+
+	if len(disjunctions) == 1 {
+		c.filters = append(c.filters, disjunctions[0]...)
+		return c
+	}
+	if c.disjunctions == nil {
+		c.disjunctions = disjunctions
+		return c
+	}
+	product := [][]filter{}
+	for _, left := range c.disjunctions {
+		for _, right := range disjunctions {
+			product = append(product, append(append([]filter{}, left...), right...))
+		}
+	}
+	if len(product) > db.MaxDisjunctions {
+		c.err = errors.Errorf("filter expand to %v queries, max is %v", len(product), db.MaxDisjunctions)
+		return c
+	}
+	c.disjunctions = product
+	return c
+}
+
+// OrderBy set query order by asc
+//
+//	list, err = Query(&Sample{}).OrderBy("Name").Return(ctx)
+//
+func (c *QueryMemory) OrderBy(path string) db.Query {
+	c.orders = append(c.orders, order{path: path})
+	return c
+}
+
+// OrderByDesc set query order by desc
+//
+//	list, err = Query(&Sample{}).OrderByDesc("Name").Limit(1).Return(ctx)
+//
+func (c *QueryMemory) OrderByDesc(path string) db.Query {
+	c.orders = append(c.orders, order{path: path, desc: true})
+	return c
+}
+
+// Limit set query limit
+//
+//	list, err = Query(&Sample{}).OrderBy("Name").Limit(1).Return(ctx)
+//
+func (c *QueryMemory) Limit(n int) db.Query {
+	c.limit = n
+	c.limited = true
+	return c
+}
+
+// defaultLimit set limit used when Limit() not called, Watch() ignore it
+//
+func (c *QueryMemory) defaultLimit(n int) *QueryMemory {
+	c.Limit(n)
+	c.limited = false
+	return c
+}
+
+// newCursor create cursor from field values
+//
+func (c *QueryMemory) newCursor(inclusive bool, fieldValues []interface{}) *cursor {
+	values := []interface{}{}
+	for _, fieldValue := range fieldValues {
+		v, err := toValue(reflect.ValueOf(fieldValue))
+		if err != nil {
+			c.err = errors.Wrap(err, "cursor value")
+			return nil
+		}
+		values = append(values, v)
+	}
+	return &cursor{values: values, inclusive: inclusive}
+}
+
+// StartAt implement Paginate, please be aware not use index but fieldValue to do the trick, see sample
+//
+//	list, err = Query(&Sample{}).OrderBy("Name").StartAt("irvine city").Return(ctx)
+//
+func (c *QueryMemory) StartAt(docSnapshotOrFieldValues ...interface{}) db.Query {
+	c.start = c.newCursor(true, docSnapshotOrFieldValues)
+	return c
+}
+
+// StartAfter implement Paginate, please be aware not use index but fieldValue to do the trick, see sample
+//
+//	list, err = Query(&Sample{}).OrderBy("Name").StartAfter("santa ana city").Return(ctx)
+//
+func (c *QueryMemory) StartAfter(docSnapshotOrFieldValues ...interface{}) db.Query {
+	c.start = c.newCursor(false, docSnapshotOrFieldValues)
+	return c
+}
+
+// EndAt implement Paginate, please be aware not use index but fieldValue to do the trick, see sample
+//
+//	list, err = Query(&Sample{}).OrderBy("Name").EndAt("irvine city").Return(ctx)
+//
+func (c *QueryMemory) EndAt(docSnapshotOrFieldValues ...interface{}) db.Query {
+	c.end = c.newCursor(true, docSnapshotOrFieldValues)
+	return c
+}
+
+// EndBefore implement Paginate, please be aware not use index but fieldValue to do the trick, see sample
+//
+//	list, err = Query(&Sample{}).OrderBy("Name").EndBefore("irvine city").Return(ctx)
+//
+func (c *QueryMemory) EndBefore(docSnapshotOrFieldValues ...interface{}) db.Query {
+	c.end = c.newCursor(false, docSnapshotOrFieldValues)
+	return c
+}
+
+// Paginate resume query after page token return by ReturnPage(), empty token mean first page. token is encrypted using crypto package if encrypt is true, use the same encrypt on every page
+//
+//	list, next, err := Query(&Sample{}).OrderBy("Name").Paginate(token, true).ReturnPage(ctx)
+//
+func (c *QueryMemory) Paginate(token string, encrypt bool) db.Query {
+	c.encrypt = encrypt
+	if token == "" {
+		return c
+	}
+	values, id, err := db.DecodePageToken(token, encrypt)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.start = &cursor{values: values, id: id, page: true}
+	return c
+}
+
+// match return true if document match filter
+//
+func (f *filter) match(id string, doc map[string]interface{}) bool {
+	var value interface{} = id
+	found := true
+	if f.path != documentID {
+		value, found = valueAt(doc, f.path)
+	}
+	if !found {
+		return false
+	}
+
+	switch f.op {
+	case "==":
+		return compareValues(value, f.value) == 0
+	case "!=":
+		return value != nil && compareValues(value, f.value) != 0
+	case "<", "<=", ">", ">=":
+		if typeOrder(value) != typeOrder(f.value) {
+			return false
+		}
+		result := compareValues(value, f.value)
+		switch f.op {
+		case "<":
+			return result < 0
+		case "<=":
+			return result <= 0
+		case ">":
+			return result > 0
+		}
+		return result >= 0
+	case "in":
+		return containsValue(f.value, value)
+	case "not-in":
+		return value != nil && !containsValue(f.value, value)
+	case "array-contains":
+		return containsValue(value, f.value)
+	case "array-contains-any":
+		list, ok := f.value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range list {
+			if containsValue(value, item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsValue return true if list contains value
+//
+func containsValue(list, value interface{}) bool {
+	items, ok := list.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range items {
+		if compareValues(item, value) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveOrders return query order, if there is no order but has inequality filter, query will order by inequality field
+//
+func (c *QueryMemory) effectiveOrders() []order {
+	if len(c.orders) > 0 {
+		return c.orders
+	}
+	for _, f := range c.filters {
+		if db.IsInequality(f.op) && f.path != documentID {
+			return []order{{path: f.path}}
+		}
+	}
+	return nil
+}
+
+// orderValues return document values on order fields, return false if document missing any order field
+//
+func orderValues(orders []order, s *snapshot) ([]interface{}, bool) {
+	values := make([]interface{}, len(orders))
+	for i, o := range orders {
+		value, found := valueAt(s.doc, o.path)
+		if !found {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// matchAll return true if document match all filters
+//
+func matchAll(filters []filter, id string, doc map[string]interface{}) bool {
+	for i := range filters {
+		if !filters[i].match(id, doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// match return true if document match query filters
+//
+func (c *QueryMemory) match(id string, doc map[string]interface{}) bool {
+	if !matchAll(c.filters, id, doc) {
+		return false
+	}
+	if c.disjunctions == nil {
+		return true
+	}
+	for _, group := range c.disjunctions {
+		if matchAll(group, id, doc) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareCursor compare document with cursor, document id is compared only when cursor has id
+//
+func compareCursor(orders []order, s *snapshot, cur *cursor) int {
+	for i, cursorValue := range cur.values {
+		result := compareValues(s.values[i], cursorValue)
+		if orders[i].desc {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	if cur.id != "" {
+		result := compareIDs(orders, s.id, cur.id)
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// compareIDs compare document id, document id is the last order and use the same direction as last order
+//
+func compareIDs(orders []order, a, b string) int {
+	result := 0
+	if a < b {
+		result = -1
+	} else if a > b {
+		result = 1
+	}
+	if len(orders) > 0 && orders[len(orders)-1].desc {
+		return -result
+	}
+	return result
+}
+
+// snapshots return documents match query
+//
+func (c *QueryMemory) snapshots() ([]*snapshot, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.disjunctions != nil && (len(c.orders) > 0 || c.start != nil || c.end != nil) {
+		return nil, errors.New("or filter can not use with order by and cursor")
+	}
+	orders := c.effectiveOrders()
+	for _, cur := range []*cursor{c.start, c.end} {
+		if cur != nil && cur.page && len(cur.values) != len(orders) {
+			return nil, errors.New("page token not match query order")
+		}
+		if cur != nil && len(cur.values) > len(orders) {
+			return nil, errors.Errorf("too many cursor values, got %v want at most %v", len(cur.values), len(orders))
+		}
+	}
+
+	c.client.mutex.RLock()
+	items := []*snapshot{}
+	for id, doc := range c.client.collections[c.collection] {
+		if !c.match(id, doc) {
+			continue
+		}
+		s := &snapshot{id: id, doc: doc}
+		values, found := orderValues(orders, s)
+		if !found {
+			continue
+		}
+		s.values = values
+		items = append(items, s)
+	}
+	for _, s := range items {
+		s.doc = copyValue(s.doc).(map[string]interface{})
+		s.values = copyValue(s.values).([]interface{})
+	}
+	c.client.mutex.RUnlock()
+
+	sort.Slice(items, func(a, b int) bool {
+		for i, o := range orders {
+			result := compareValues(items[a].values[i], items[b].values[i])
+			if result != 0 {
+				if o.desc {
+					return result > 0
+				}
+				return result < 0
+			}
+		}
+		return compareIDs(orders, items[a].id, items[b].id) < 0
+	})
+
+	result := []*snapshot{}
+	for _, s := range items {
+		if c.start != nil {
+			compare := compareCursor(orders, s, c.start)
+			if compare < 0 || (compare == 0 && !c.start.inclusive) {
+				continue
+			}
+		}
+		if c.end != nil {
+			compare := compareCursor(orders, s, c.end)
+			if compare > 0 || (compare == 0 && !c.end.inclusive) {
+				continue
+			}
+		}
+		result = append(result, s)
+		if c.limit > 0 && len(result) >= c.limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// returnSnapshots check query and return documents match query
+//
+func (c *QueryMemory) returnSnapshots(ctx context.Context) ([]*snapshot, error) {
+	if err := db.AssertObject(ctx, c.QueryObject, false); err != nil {
+		return nil, err
+	}
+	if c.QueryTransaction != nil {
+		if err := c.QueryTransaction.(*TransactionMemory).read(); err != nil {
+			return nil, err
+		}
+	}
+	c.collection = c.QueryObject.Collection()
+	snapshots, err := c.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if c.QueryTransaction != nil {
+		tx := c.QueryTransaction.(*TransactionMemory)
+		for _, s := range snapshots {
+			tx.remember(c.collection, s.id, s.doc)
+		}
+	}
+	return snapshots, nil
+}
+
+// Return query result with default limit to 20 object, use Limit() to override default limit, return nil if anything wrong
+//
+//	list, err = Query(&Sample{}).OrderByDesc("Name").Limit(1).Return(ctx)
+//
+func (c *QueryMemory) Return(ctx context.Context) ([]db.Object, error) {
+	snapshots, err := c.returnSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotsToObjects(c.QueryObject, snapshots)
+}
+
+// ReturnID only return object id with default limit to 20 object, use Limit() to override default limit, return nil if anything wrong
+//
+//	idList, err := Query(&Sample{}).OrderBy("From").Limit(1).StartAt("b city").ReturnID(ctx)
+//
+func (c *QueryMemory) ReturnID(ctx context.Context) ([]string, error) {
+	snapshots, err := c.returnSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, s := range snapshots {
+		result = append(result, s.id)
+	}
+	return result, nil
+}
+
+// ReturnCount return object count with default limit to 20 object, use Limit() to override default limit, use Count() to get exact count
+//
+//	count, err := Query(&Sample{}).Where("Name", "==", "sample1").ReturnCount(ctx)
+//
+func (c *QueryMemory) ReturnCount(ctx context.Context) (int, error) {
+	snapshots, err := c.returnSnapshots(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(snapshots), nil
+}
+
+// aggregate return sum and count of numeric value on field in all document match query, Limit() is ignored
+//
+func (c *QueryMemory) aggregate(ctx context.Context, field string) (float64, int, error) {
+	all := *c
+	all.limit = 0
+	snapshots, err := all.returnSnapshots(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	var sum float64
+	count := 0
+	for _, s := range snapshots {
+		if field == "" {
+			count++
+			continue
+		}
+		value, found := valueAt(s.doc, field)
+		if !found {
+			continue
+		}
+		number, err := util.ToFloat64(value)
+		if err != nil {
+			continue // not numeric
+		}
+		sum += number
+		count++
+	}
+	return sum, count, nil
+}
+
+// Count return exact count of object match query, Limit() is ignored
+//
+//	count, err := Query(&Sample{}).Where("Name", "==", "sample1").Count(ctx)
+//
+func (c *QueryMemory) Count(ctx context.Context) (int, error) {
+	_, count, err := c.aggregate(ctx, "")
+	return count, err
+}
+
+// Sum return sum of numeric field on all object match query, Limit() is ignored, non-numeric value is ignored
+//
+//	total, err := Query(&Sample{}).Where("Tag", "==", "a").Sum(ctx, "Value")
+//
+func (c *QueryMemory) Sum(ctx context.Context, field string) (float64, error) {
+	sum, _, err := c.aggregate(ctx, field)
+	return sum, err
+}
+
+// Avg return average of numeric field on all object match query, Limit() is ignored, non-numeric value is ignored, return 0 if no numeric value
+//
+//	avg, err := Query(&Sample{}).Where("Tag", "==", "a").Avg(ctx, "Value")
+//
+func (c *QueryMemory) Avg(ctx context.Context, field string) (float64, error) {
+	sum, count, err := c.aggregate(ctx, field)
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	return sum / float64(count), nil
+}
+
+// ReturnEmpty return true if no object exist
+//
+//	isEmpty, err := Query(&Sample{}).Where("Name", "==", "sample1").ReturnEmpty(ctx)
+//
+func (c *QueryMemory) ReturnEmpty(ctx context.Context) (bool, error) {
+	c.Limit(1)
+	snapshots, err := c.returnSnapshots(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(snapshots) == 0, nil
+}
+
+// ReturnExists return true if object exist
+//
+//	isExists, err := Query(&Sample{}).Where("Name", "==", "sample1").ReturnExists(ctx)
+//
+func (c *QueryMemory) ReturnExists(ctx context.Context) (bool, error) {
+	empty, err := c.ReturnEmpty(ctx)
+	return !empty, err
+}
+
+// ReturnFirst return first object from query
+//
+//	obj, err := Query(&Sample{}).OrderBy("From").Limit(1).StartAt("b city").ReturnFirst(ctx)
+//	greet := obj.(*Greet)
+//
+func (c *QueryMemory) ReturnFirst(ctx context.Context) (db.Object, error) {
+	if err := db.AssertObject(ctx, c.QueryObject, false); err != nil {
+		return nil, err
+	}
+	list, err := c.Limit(1).Return(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "return first")
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[0], nil
+}
+
+// ReturnFirstID return first object id from query
+//
+//	id, err := Query(&Sample{}).OrderBy("From").Limit(1).StartAt("b city").ReturnFirstID(ctx)
+//
+func (c *QueryMemory) ReturnFirstID(ctx context.Context) (string, error) {
+	if err := db.AssertObject(ctx, c.QueryObject, false); err != nil {
+		return "", err
+	}
+	list, err := c.Limit(1).ReturnID(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "return first id")
+	}
+	if len(list) == 0 {
+		return "", nil
+	}
+	return list[0], nil
+}
+
+// ReturnPage return one page of object with default limit to 20 object, use Limit() to set page size. return url-safe token to next page, token is empty if no more object. not support with or filter
+//
+//	list, next, err := Query(&Sample{}).OrderBy("Name").Paginate(token, false).ReturnPage(ctx)
+//
+func (c *QueryMemory) ReturnPage(ctx context.Context) ([]db.Object, string, error) {
+	if c.disjunctions != nil {
+		return nil, "", errors.New("page token is not support with or filter")
+	}
+	page := *c
+	if page.limit > 0 {
+		page.limit++ // read one more to know if there is next page
+	}
+	snapshots, err := page.returnSnapshots(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if c.limit > 0 && len(snapshots) > c.limit {
+		snapshots = snapshots[:c.limit]
+		last := snapshots[len(snapshots)-1]
+		next, err = db.EncodePageToken(last.values, last.id, c.encrypt)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	list, err := snapshotsToObjects(c.QueryObject, snapshots)
+	if err != nil {
+		return nil, "", err
+	}
+	return list, next, nil
+}
+
+// Iterate return iterator to walk through all object page by page. Limit() is ignored, pageSize is object count read at a time, not support in transaction
+//
+//	iter := Query(&Sample{}).OrderBy("Name").Iterate(ctx, 100)
+//	defer iter.Close()
+//	for iter.Next() {
+//		sample := iter.Object().(*Sample)
+//	}
+//	err := iter.Err()
+//
+func (c *QueryMemory) Iterate(ctx context.Context, pageSize int) db.Iterator {
+	if pageSize <= 0 {
+		pageSize = db.DefaultPageSize
+	}
+	query := c.clone()
+	iter := &IteratorMemory{
+		ctx:      ctx,
+		query:    query,
+		pageSize: pageSize,
+	}
+	if err := db.AssertObject(ctx, c.QueryObject, false); err != nil {
+		iter.err = err
+	} else if c.QueryTransaction != nil {
+		iter.err = errors.New("iterate query is not support in transaction")
+	} else if c.disjunctions != nil {
+		iter.err = errors.New("iterate query is not support with or filter")
+	} else {
+		query.collection = c.QueryObject.Collection()
+	}
+	return iter
+}
+
+// Watch return watcher deliver change of all object match query, first change are all object currently match query. default limit is ignored, only limit set by Limit() is kept. not support in transaction and with or filter
+//
+//	watcher := Query(&Sample{}).Where("Tag", "==", "a").Watch(ctx)
+//	defer watcher.Close()
+//	for change := range watcher.Changes() {
+//		sample := change.Object.(*Sample)
+//	}
+//
+func (c *QueryMemory) Watch(ctx context.Context) db.Watcher {
+	err := db.AssertObject(ctx, c.QueryObject, false)
+	if err == nil && c.QueryTransaction != nil {
+		err = errors.New("watch query is not support in transaction")
+	} else if err == nil && c.disjunctions != nil {
+		err = errors.New("watch query is not support with or filter")
+	} else if err == nil && c.err != nil {
+		err = c.err
+	}
+	if err != nil {
+		return newWatcher(ctx, c.client, c.QueryObject, nil, err)
+	}
+
+	query := c.clone()
+	query.collection = c.QueryObject.Collection()
+	if !query.limited {
+		query.limit = 0
+	}
+	return newWatcher(ctx, c.client, c.QueryObject, func(ctx context.Context) (map[string]map[string]interface{}, error) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		snapshots, err := query.snapshots()
+		if err != nil {
+			return nil, err
+		}
+		docs := map[string]map[string]interface{}{}
+		for _, s := range snapshots {
+			docs[s.id] = s.doc
+		}
+		return docs, nil
+	}, nil)
+}
+
+// Delete delete all document return from query. delete max doc count. return is done,delete count, error
+//
+//	done, count, err := client.Query(&Sample{}).Where("Name", "==", name).Delete(ctx, 100)
+//
+func (c *QueryMemory) Delete(ctx context.Context, max int) (bool, int, error) {
+	if ctx.Err() != nil {
+		return false, 0, ctx.Err()
+	}
+	if c.QueryTransaction != nil {
+		return false, 0, errors.New("delete query is not support in transaction, use tx.Delete() instead")
+	}
+
+	c.Limit(max)
+	snapshots, err := c.returnSnapshots(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+	complete, numDeleted, err := c.client.deleteSnapshots(c.collection, max, snapshots)
+	if err != nil {
+		return false, numDeleted, errors.Wrap(err, "delete "+c.collection)
+	}
+	return complete, numDeleted, nil
+}
+
+// Cleanup delete 25 document a time, at most 60,000 object in one call. return true if no object left in collection
+//
+//	done,  err := client.Query(&Sample{}).Where("Name", "==", name).Cleanup(ctx)
+//
+func (c *QueryMemory) Cleanup(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if c.QueryTransaction != nil {
+		return false, errors.New("cleanup query is not support in transaction")
+	}
+
+	numDeleted := 0
+	complete := false
+	for i := 0; i < 2400; i++ {
+		done, count, err := c.Delete(ctx, 25)
+		if err != nil {
+			return false, errors.Wrap(err, "clean 25 doc")
+		}
+		numDeleted += count
+		if done {
+			complete = true
+			break
+		}
+	}
+	if numDeleted > 0 {
+		log.Info(ctx, "cleanup %s, deleted %v ", c.QueryObject.Collection(), numDeleted)
+	}
+	return complete, nil
+}
@@ -0,0 +1,513 @@
+package mdb
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleQueryClient return client with sample a/b/c in collection
+//
+func sampleQueryClient(ctx context.Context) db.Client {
+	client := sampleClient()
+	samples := []*Sample{
+		{Name: "a city", Value: 1, Tag: "t1", Array: []string{"x", "y"}},
+		{Name: "b city", Value: 2, Tag: "t1", Array: []string{"y"}},
+		{Name: "c city", Value: 3, Tag: "t2"},
+	}
+	for i, sample := range samples {
+		sample.SetID(string(rune('a' + i)))
+		client.Set(ctx, sample)
+	}
+	return client
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+
+	// no obj will result error
+	_, err := client.Query(nil).Where("ID", "==", "a").ReturnFirstID(ctx)
+	assert.NotNil(err)
+
+	// id filter
+	firstID, err := client.Query(&Sample{}).Where("ID", "==", "b").ReturnFirstID(ctx)
+	assert.Nil(err)
+	assert.Equal("b", firstID)
+
+	list, err := client.Query(&Sample{}).Where("Name", "==", "a city").Return(ctx)
+	assert.Nil(err)
+	assert.Len(list, 1)
+	assert.Equal("a city", list[0].(*Sample).Name)
+
+	count, err := client.Query(&Sample{}).Where("Tag", "==", "t1").Where("Value", ">", 1).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(1, count)
+
+	// order
+	obj, err := client.Query(&Sample{}).OrderByDesc("Name").ReturnFirst(ctx)
+	assert.Nil(err)
+	assert.Equal("c city", obj.(*Sample).Name)
+
+	idList, err := client.Query(&Sample{}).OrderBy("Value").ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "b", "c"}, idList)
+
+	// limit
+	list, err = client.Query(&Sample{}).Limit(2).Return(ctx)
+	assert.Nil(err)
+	assert.Len(list, 2)
+
+	// cursor
+	idList, err = client.Query(&Sample{}).OrderBy("Name").StartAt("b city").ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"b", "c"}, idList)
+
+	idList, err = client.Query(&Sample{}).OrderBy("Name").StartAfter("b city").ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"c"}, idList)
+
+	idList, err = client.Query(&Sample{}).OrderBy("Name").EndAt("b city").ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "b"}, idList)
+
+	idList, err = client.Query(&Sample{}).OrderBy("Name").EndBefore("b city").ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a"}, idList)
+
+	idList, err = client.Query(&Sample{}).OrderByDesc("Name").StartAfter("c city").ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"b", "a"}, idList)
+
+	// cursor values more than order
+	_, err = client.Query(&Sample{}).StartAt("b city").ReturnID(ctx)
+	assert.NotNil(err)
+
+	// empty/exists
+	isEmpty, err := client.Query(&Sample{}).Where("Name", "==", "not exist").ReturnEmpty(ctx)
+	assert.Nil(err)
+	assert.True(isEmpty)
+
+	isExist, err := client.Query(&Sample{}).Where("Name", "==", "a city").ReturnExists(ctx)
+	assert.Nil(err)
+	assert.True(isExist)
+}
+
+func TestQueryOperator(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+
+	idList, err := client.Query(&Sample{}).Where("Value", "!=", 2).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "c"}, idList)
+
+	idList, err = client.Query(&Sample{}).Where("Value", "in", []int{1, 3}).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "c"}, idList)
+
+	idList, err = client.Query(&Sample{}).Where("Value", "not-in", []int{1, 3}).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"b"}, idList)
+
+	idList, err = client.Query(&Sample{}).Where("Array", "array-contains", "y").ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "b"}, idList)
+
+	idList, err = client.Query(&Sample{}).Where("Array", "array-contains-any", []string{"x", "z"}).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a"}, idList)
+
+	idList, err = client.Query(&Sample{}).Where("ID", "in", []string{"a", "c"}).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "c"}, idList)
+
+	// inequality filter order by filter field
+	idList, err = client.Query(&Sample{}).Where("Name", "<", "c").ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "b"}, idList)
+
+	// invalid operator
+	_, err = client.Query(&Sample{}).Where("Value", "like", 1).Return(ctx)
+	assert.NotNil(err)
+}
+
+func TestQueryNotExistFieldWillNotError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+	obj, err := client.Query(&Sample{}).Where("notExist", "<", time.Now().UTC()).ReturnFirst(ctx)
+	assert.Nil(err)
+	assert.Nil(obj)
+}
+
+func TestQueryTime(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+	obj, err := client.Query(&Sample{}).Where("CreateTime", "<=", time.Now().Add(5*time.Second).UTC()).ReturnFirst(ctx)
+	assert.Nil(err)
+	assert.NotNil(obj)
+
+	obj, err = client.Query(&Sample{}).Where("CreateTime", ">", time.Now().Add(5*time.Second).UTC()).ReturnFirst(ctx)
+	assert.Nil(err)
+	assert.Nil(obj)
+}
+
+func TestQueryDelete(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+
+	done, count, err := client.Query(&Sample{}).Where("Tag", "==", "t1").Delete(ctx, 100)
+	assert.Nil(err)
+	assert.True(done)
+	assert.Equal(2, count)
+
+	count, err = client.Query(&Sample{}).ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(1, count)
+
+	// delete query is not support in transaction
+	client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		done, numDeleted, err := tx.Query(&Sample{}).Where("Tag", "==", "t2").Delete(ctx, 10)
+		assert.NotNil(err)
+		assert.False(done)
+		assert.Equal(0, numDeleted)
+		return nil
+	})
+}
+
+func TestQueryCleanup(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	for i := 0; i < 30; i++ {
+		err := client.Set(ctx, &Sample{Name: "cleanup"})
+		assert.Nil(err)
+	}
+
+	done, err := client.Query(&Sample{}).Where("Name", "==", "cleanup").Cleanup(ctx)
+	assert.Nil(err)
+	assert.True(done)
+
+	found, err := client.Query(&Sample{}).Where("Name", "==", "cleanup").ReturnExists(ctx)
+	assert.Nil(err)
+	assert.False(found)
+}
+
+func TestQueryAggregate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	for i := 0; i < 25; i++ {
+		client.Set(ctx, &Sample{Name: "agg", Value: i%5 + 1})
+	}
+	client.Set(ctx, &Sample{Name: "agg"}) // no value
+
+	// ReturnCount is bounded by default limit, Count is not
+	count, err := client.Query(&Sample{}).Where("Name", "==", "agg").ReturnCount(ctx)
+	assert.Nil(err)
+	assert.Equal(20, count)
+
+	count, err = client.Query(&Sample{}).Where("Name", "==", "agg").Count(ctx)
+	assert.Nil(err)
+	assert.Equal(26, count)
+
+	count, err = client.Query(&Sample{}).Where("Name", "==", "agg").Limit(3).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(26, count)
+
+	count, err = client.Query(&Sample{}).Where("Value", ">", 3).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(10, count)
+
+	sum, err := client.Query(&Sample{}).Where("Name", "==", "agg").Sum(ctx, "Value")
+	assert.Nil(err)
+	assert.Equal(float64(75), sum)
+
+	avg, err := client.Query(&Sample{}).Where("Name", "==", "agg").Avg(ctx, "Value")
+	assert.Nil(err)
+	assert.Equal(float64(3), avg)
+
+	// non-numeric field is ignored
+	sum, err = client.Query(&Sample{}).Where("Name", "==", "agg").Sum(ctx, "Name")
+	assert.Nil(err)
+	assert.Equal(float64(0), sum)
+
+	avg, err = client.Query(&Sample{}).Where("Name", "==", "not exist").Avg(ctx, "Value")
+	assert.Nil(err)
+	assert.Equal(float64(0), avg)
+
+	// no obj will result error
+	_, err = client.Query(nil).Count(ctx)
+	assert.NotNil(err)
+}
+
+func TestQueryAggregateInTransaction(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		count, err := tx.Query(&Sample{}).Where("Tag", "==", "t1").Count(ctx)
+		assert.Nil(err)
+		assert.Equal(2, count)
+
+		sum, err := tx.Query(&Sample{}).Sum(ctx, "Value")
+		assert.Nil(err)
+		assert.Equal(float64(6), sum)
+
+		err = tx.Set(ctx, &Sample{Name: "d city"})
+		assert.Nil(err)
+
+		// read after write
+		_, err = tx.Query(&Sample{}).Count(ctx)
+		assert.NotNil(err)
+		return nil
+	})
+	assert.Nil(err)
+}
+
+func TestQueryWhereFilter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+
+	// or
+	idList, err := client.Query(&Sample{}).WhereFilter(db.Or(
+		db.Field("Name", "==", "a city"),
+		db.Field("Value", "==", 3),
+	)).ReturnID(ctx)
+	assert.Nil(err)
+	assert.ElementsMatch([]string{"a", "c"}, idList)
+
+	// document match more than one group only return once
+	count, err := client.Query(&Sample{}).WhereFilter(db.Or(
+		db.Field("Tag", "==", "t1"),
+		db.Field("Array", "array-contains", "y"),
+	)).Count(ctx)
+	assert.Nil(err)
+	assert.Equal(2, count)
+
+	// and with or
+	idList, err = client.Query(&Sample{}).Where("Tag", "==", "t1").WhereFilter(db.Or(
+		db.Field("Value", "==", 2),
+		db.Field("Value", "==", 3),
+	)).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"b"}, idList)
+
+	// nested
+	idList, err = client.Query(&Sample{}).WhereFilter(db.Or(
+		db.And(db.Field("Tag", "==", "t1"), db.Field("Value", ">", 1)),
+		db.Field("Tag", "==", "t2"),
+	)).ReturnID(ctx)
+	assert.Nil(err)
+	assert.ElementsMatch([]string{"b", "c"}, idList)
+
+	// two or filter
+	idList, err = client.Query(&Sample{}).
+		WhereFilter(db.Or(db.Field("Value", "==", 1), db.Field("Value", "==", 2))).
+		WhereFilter(db.Or(db.Field("Name", "==", "b city"), db.Field("Name", "==", "c city"))).
+		ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"b"}, idList)
+
+	// in, not-in, array-contains-any
+	idList, err = client.Query(&Sample{}).WhereFilter(db.Field("Value", "in", []int{1, 3})).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a", "c"}, idList)
+
+	idList, err = client.Query(&Sample{}).WhereFilter(db.Field("ID", "not-in", []string{"a", "c"})).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"b"}, idList)
+
+	idList, err = client.Query(&Sample{}).WhereFilter(db.Field("Array", "array-contains-any", []string{"x", "z"})).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal([]string{"a"}, idList)
+
+	// limit on merged result
+	list, err := client.Query(&Sample{}).WhereFilter(db.Or(
+		db.Field("Tag", "==", "t1"),
+		db.Field("Tag", "==", "t2"),
+	)).Limit(2).Return(ctx)
+	assert.Nil(err)
+	assert.Len(list, 2)
+}
+
+func TestQueryWhereFilterError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+
+	// invalid op
+	_, err := client.Query(&Sample{}).Where("Name", "=", "a city").Return(ctx)
+	assert.NotNil(err)
+	_, err = client.Query(&Sample{}).WhereFilter(db.Field("Name", "contains", "a")).Return(ctx)
+	assert.NotNil(err)
+
+	// list value
+	_, err = client.Query(&Sample{}).WhereFilter(db.Field("Value", "in", 1)).Return(ctx)
+	assert.NotNil(err)
+	_, err = client.Query(&Sample{}).WhereFilter(db.Field("Value", "in", []int{})).Return(ctx)
+	assert.NotNil(err)
+	_, err = client.Query(&Sample{}).WhereFilter(db.Field("Value", "in", make([]int, db.MaxFilterValues+1))).Return(ctx)
+	assert.NotNil(err)
+
+	// empty composite
+	_, err = client.Query(&Sample{}).WhereFilter(db.Or()).Return(ctx)
+	assert.NotNil(err)
+
+	// unsupported combination
+	_, err = client.Query(&Sample{}).WhereFilter(db.And(
+		db.Field("Value", "in", []int{1, 2}),
+		db.Field("Tag", "not-in", []string{"t2"}),
+	)).Return(ctx)
+	assert.NotNil(err)
+
+	_, err = client.Query(&Sample{}).WhereFilter(db.And(
+		db.Field("Array", "array-contains", "x"),
+		db.Field("Array", "array-contains-any", []string{"y"}),
+	)).Return(ctx)
+	assert.NotNil(err)
+
+	_, err = client.Query(&Sample{}).WhereFilter(db.And(
+		db.Field("Value", ">", 1),
+		db.Field("Name", "<", "b"),
+	)).Return(ctx)
+	assert.NotNil(err)
+
+	_, err = client.Query(&Sample{}).WhereFilter(db.And(
+		db.Field("Tag", "!=", "t1"),
+		db.Field("Tag", "not-in", []string{"t2"}),
+	)).Return(ctx)
+	assert.NotNil(err)
+
+	// too many queries
+	values := []*db.Filter{}
+	for i := 0; i < db.MaxDisjunctions+1; i++ {
+		values = append(values, db.Field("Value", "==", i))
+	}
+	_, err = client.Query(&Sample{}).WhereFilter(db.Or(values...)).Return(ctx)
+	assert.NotNil(err)
+
+	// or with order by, cursor and iterate
+	or := db.Or(db.Field("Value", "==", 1), db.Field("Value", "==", 2))
+	_, err = client.Query(&Sample{}).WhereFilter(or).OrderBy("Name").Return(ctx)
+	assert.NotNil(err)
+	_, err = client.Query(&Sample{}).WhereFilter(or).OrderBy("Name").StartAt("a").Return(ctx)
+	assert.NotNil(err)
+	iter := client.Query(&Sample{}).WhereFilter(or).Iterate(ctx, 10)
+	defer iter.Close()
+	assert.False(iter.Next())
+	assert.NotNil(iter.Err())
+}
+
+func TestQueryReturnPage(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	for i := 0; i < 25; i++ {
+		client.Set(ctx, &Sample{Name: "page", Value: i%3 + 1})
+	}
+
+	readAll := func(newQuery func() db.Query) []string {
+		ids := []string{}
+		token := ""
+		for i := 0; i < 10; i++ {
+			list, next, err := newQuery().Limit(10).Paginate(token, false).ReturnPage(ctx)
+			assert.Nil(err)
+			for _, obj := range list {
+				ids = append(ids, obj.ID())
+			}
+			if next == "" {
+				break
+			}
+			assert.Equal(url.QueryEscape(next), next)
+			token = next
+		}
+		return ids
+	}
+
+	// order by field with the same values
+	ids := readAll(func() db.Query { return client.Query(&Sample{}).OrderBy("Value") })
+	assert.Len(ids, 25)
+	all, err := client.Query(&Sample{}).OrderBy("Value").Limit(100).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal(all, ids)
+
+	ids = readAll(func() db.Query { return client.Query(&Sample{}).OrderByDesc("Value") })
+	assert.Len(ids, 25)
+	all, err = client.Query(&Sample{}).OrderByDesc("Value").Limit(100).ReturnID(ctx)
+	assert.Nil(err)
+	assert.Equal(all, ids)
+
+	// no order
+	ids = readAll(func() db.Query { return client.Query(&Sample{}) })
+	assert.Len(ids, 25)
+
+	// inequality filter
+	ids = readAll(func() db.Query { return client.Query(&Sample{}).Where("Value", ">", 1) })
+	assert.Len(ids, 16)
+
+	// exact page has no next token
+	list, next, err := client.Query(&Sample{}).Limit(25).ReturnPage(ctx)
+	assert.Nil(err)
+	assert.Len(list, 25)
+	assert.Empty(next)
+}
+
+func TestQueryReturnPageError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+
+	_, next, err := client.Query(&Sample{}).OrderBy("Name").Limit(1).ReturnPage(ctx)
+	assert.Nil(err)
+	assert.NotEmpty(next)
+
+	// encrypt not match
+	_, _, err = client.Query(&Sample{}).OrderBy("Name").Limit(1).Paginate(next, true).ReturnPage(ctx)
+	assert.NotNil(err)
+
+	// token not match query order
+	_, _, err = client.Query(&Sample{}).OrderBy("Name").OrderBy("Value").Limit(1).Paginate(next, false).ReturnPage(ctx)
+	assert.NotNil(err)
+	_, _, err = client.Query(&Sample{}).Limit(1).Paginate(next, false).ReturnPage(ctx)
+	assert.NotNil(err)
+
+	// invalid token
+	_, _, err = client.Query(&Sample{}).OrderBy("Name").Paginate("p!!", false).ReturnPage(ctx)
+	assert.NotNil(err)
+	_, _, err = client.Query(&Sample{}).OrderBy("Name").Paginate("pe30", false).ReturnPage(ctx)
+	assert.NotNil(err)
+
+	// or filter
+	_, _, err = client.Query(&Sample{}).WhereFilter(db.Or(
+		db.Field("Value", "==", 1),
+		db.Field("Value", "==", 2),
+	)).ReturnPage(ctx)
+	assert.NotNil(err)
+
+	// no obj
+	_, _, err = client.Query(nil).ReturnPage(ctx)
+	assert.NotNil(err)
+}
@@ -0,0 +1,117 @@
+package mdb
+
+import (
+	"context"
+
+	"github.com/piyuo/libsrv/db"
+)
+
+type PlainObject struct {
+	ID   string
+	Name string
+}
+
+// SampleNoFactory with no factory and collection
+//
+type SampleNoFactory struct {
+	db.Model
+	Name string
+}
+
+func (c *SampleNoFactory) Factory() db.Object {
+	return nil
+}
+
+func (c *SampleNoFactory) Collection() string {
+	return "SampleNoFactory"
+}
+
+// SampleSoftDelete use soft delete
+//
+type SampleSoftDelete struct {
+	db.Model
+	Name string `firestore:"Name,omitempty"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *SampleSoftDelete) Factory() db.Object {
+	return &SampleSoftDelete{}
+}
+
+// Collection is name in the database
+//
+func (c *SampleSoftDelete) Collection() string {
+	return "SampleSoftDelete"
+}
+
+// SoftDelete enable soft delete
+//
+func (c *SampleSoftDelete) SoftDelete() bool {
+	return true
+}
+
+// SampleVersioned use optimistic concurrency
+//
+type SampleVersioned struct {
+	db.Model
+	Name string `firestore:"Name,omitempty"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *SampleVersioned) Factory() db.Object {
+	return &SampleVersioned{}
+}
+
+// Collection is name in the database
+//
+func (c *SampleVersioned) Collection() string {
+	return "SampleVersioned"
+}
+
+// Versioned enable optimistic concurrency
+//
+func (c *SampleVersioned) Versioned() bool {
+	return true
+}
+
+// Sample for test
+//
+type Sample struct {
+	db.Model
+	Name    string            `firestore:"Name,omitempty"`
+	Tag     string            `firestore:"Tag,omitempty"`
+	Value   int               `firestore:"Value,omitempty"`
+	Map     map[string]string `firestore:"Map,omitempty"`
+	Array   []string          `firestore:"Array,omitempty"`
+	Numbers []int             `firestore:"Numbers,omitempty"`
+	PObj    *PlainObject      `firestore:"PObj,omitempty"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *Sample) Factory() db.Object {
+	return &Sample{
+		Map:     map[string]string{},
+		Array:   []string{},
+		Numbers: []int{},
+		PObj:    &PlainObject{},
+	}
+}
+
+// Collection is name in the database
+//
+func (c *Sample) Collection() string {
+	return "Sample"
+}
+
+// sampleClient create new memory client use for test
+//
+func sampleClient() db.Client {
+	client, err := NewClient(context.Background())
+	if err != nil {
+		return nil
+	}
+	return client
+}
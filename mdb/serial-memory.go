@@ -0,0 +1,87 @@
+package mdb
+
+import (
+	"context"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+)
+
+// SerialMemory generate serial from memory
+//
+type SerialMemory struct {
+	db.Serial
+
+	MetaMemory
+
+	callRX bool
+
+	shardExist bool
+}
+
+// NumberRX return sequence number, number is unique and serial, must used it in transaction with NumberWX()
+//
+//	err := Transaction(ctx, func(ctx context.Context,tx db.Transaction) error {
+//		num, err:= serial.NumberRX(ctx,tx)
+//		err := serial.NumberWX(ctx,tx)
+//	})
+//
+func (c *SerialMemory) NumberRX(ctx context.Context, transaction db.Transaction) (int64, error) {
+	tx := transaction.(*TransactionMemory)
+	c.callRX = true
+	doc, err := tx.readDoc(c.collection, c.id)
+	if err != nil {
+		return 0, errors.Wrapf(err, "get serial doc %v-%v", c.collection, c.id)
+	}
+
+	if doc == nil {
+		c.shardExist = false
+		return 1, nil
+	}
+
+	id, ok := doc[db.MetaN].(int64)
+	if !ok {
+		return 0, errors.Errorf("invalid dataType %T want int64 %v-%v", doc[db.MetaN], c.collection, c.id)
+	}
+	c.shardExist = true
+	return id + 1, nil
+}
+
+// NumberWX commit NumberRX
+//
+//	err := Transaction(ctx, func(ctx context.Context,tx db.Transaction) error {
+//		num, err:= serial.NumberRX(ctx,tx)
+//		err := serial.NumberWX(ctx,tx)
+//	})
+//
+func (c *SerialMemory) NumberWX(ctx context.Context, transaction db.Transaction) error {
+	if !c.callRX {
+		return errors.New("must call RX first")
+	}
+
+	tx := transaction.(*TransactionMemory)
+	if c.shardExist {
+		if err := tx.incrementShard(c.collection, c.id, 1); err != nil {
+			return err
+		}
+	} else {
+		shard := map[string]interface{}{
+			db.MetaID: c.id,
+			db.MetaN:  1,
+		}
+		if err := tx.createShard(c.collection, c.id, shard); err != nil {
+			return err
+		}
+	}
+	c.callRX = false
+	c.shardExist = false
+	return nil
+}
+
+// Delete delete serial
+//
+//	err = Delete(ctx)
+//
+func (c *SerialMemory) Delete(ctx context.Context) error {
+	return c.deleteShards(ctx)
+}
@@ -0,0 +1,71 @@
+package mdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerial(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	serial := client.Serial("test-serial")
+
+	var firstSerial int64
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		num, err := serial.NumberRX(ctx, tx)
+		assert.Nil(err)
+		firstSerial = num
+		return serial.NumberWX(ctx, tx)
+	})
+	assert.Nil(err)
+	assert.Equal(int64(1), firstSerial)
+
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		_, err := serial.NumberRX(ctx, tx)
+		assert.Nil(err)
+		err = serial.NumberWX(ctx, tx)
+		assert.Nil(err)
+		return errors.New("fail")
+	})
+	assert.NotNil(err)
+
+	var secondSerial int64
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		num, err := serial.NumberRX(ctx, tx)
+		assert.Nil(err)
+		secondSerial = num
+		return serial.NumberWX(ctx, tx)
+	})
+	assert.Nil(err)
+	assert.Equal(int64(2), secondSerial)
+
+	// reset serial
+	err = serial.Delete(ctx)
+	assert.Nil(err)
+
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		num, err := serial.NumberRX(ctx, tx)
+		assert.Nil(err)
+		assert.Equal(int64(1), num)
+		return serial.NumberWX(ctx, tx)
+	})
+	assert.Nil(err)
+}
+
+func TestSerialWXWithoutRX(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	serial := client.Serial("test-serial-wx")
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return serial.NumberWX(ctx, tx)
+	})
+	assert.NotNil(err)
+}
@@ -0,0 +1,23 @@
+package mdb
+
+import (
+	"os"
+	"testing"
+
+	"github.com/piyuo/libsrv/log"
+)
+
+func TestMain(m *testing.M) {
+	setup()
+	code := m.Run()
+	shutdown()
+	os.Exit(code)
+}
+
+func setup() {
+	log.ForceStopLog(true)
+}
+
+func shutdown() {
+	log.ForceStopLog(false)
+}
@@ -0,0 +1,269 @@
+package mdb
+
+import (
+	"context"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+)
+
+// TransactionMemory implement in-memory transaction, write operation will be applied when transaction commit.
+// like firestore, document read in transaction is checked when commit, transaction is run again if document has been changed by others. query only check document it returned, document newly match query is not detected
+//
+type TransactionMemory struct {
+	db.Transaction
+
+	// client is memory client
+	//
+	client *ClientMemory
+
+	// writes is write operation to apply when transaction commit
+	//
+	writes []write
+
+	// committed is function to run after transaction commit, like increment object version
+	//
+	committed []func()
+
+	// reads is document read in transaction, nil document mean document not exist when read
+	//
+	reads map[docKey]map[string]interface{}
+}
+
+// remember document read in transaction, so it can be checked when commit
+//
+func (c *TransactionMemory) remember(collection, id string, doc map[string]interface{}) {
+	if c.reads == nil {
+		c.reads = map[docKey]map[string]interface{}{}
+	}
+	key := docKey{collection: collection, id: id}
+	if _, found := c.reads[key]; found {
+		return
+	}
+	if doc != nil {
+		doc = copyValue(doc).(map[string]interface{})
+	}
+	c.reads[key] = doc
+}
+
+// read return error if transaction already has write operation, like firestore all reads must happen before writes
+//
+func (c *TransactionMemory) read() error {
+	if len(c.writes) > 0 {
+		return errors.New("read after write in transaction")
+	}
+	return nil
+}
+
+// versioned wrap write operation with version check if object is versioned, like firestore stored version must read before any write
+//
+func (c *TransactionMemory) versioned(obj db.Object, w write) (write, error) {
+	if !obj.Versioned() {
+		return w, nil
+	}
+	if err := c.read(); err != nil {
+		return nil, errors.Wrap(err, "read version")
+	}
+	version := obj.Version()
+	c.committed = append(c.committed, func() {
+		obj.SetVersion(version + 1)
+	})
+	return versionWrite(obj.Collection(), obj.ID(), version, w), nil
+}
+
+// readDoc return copy of document, return nil if document not exist
+//
+func (c *TransactionMemory) readDoc(collection, id string) (map[string]interface{}, error) {
+	if err := c.read(); err != nil {
+		return nil, err
+	}
+	doc := c.client.readDoc(collection, id)
+	c.remember(collection, id, doc)
+	return doc, nil
+}
+
+// Get data object from table, return nil if object does not exist
+//
+//	object, err := Get(ctx, &Sample{}, "id")
+//
+func (c *TransactionMemory) Get(ctx context.Context, obj db.Object, id string) (db.Object, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return nil, err
+	}
+	if err := db.AssertID(id); err != nil {
+		return nil, err
+	}
+	obj = obj.Factory() // recreate null safe object
+	doc, err := c.readDoc(obj.Collection(), id)
+	if err != nil {
+		return nil, err
+	}
+	return docToObject(obj, id, visibleDoc(obj, doc))
+}
+
+// Exists return true if object with id exist
+//
+//	found,err := Exists(ctx, &Sample{}, "id")
+//
+func (c *TransactionMemory) Exists(ctx context.Context, obj db.Object, id string) (bool, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return false, err
+	}
+	if err := db.AssertID(id); err != nil {
+		return false, err
+	}
+	doc, err := c.readDoc(obj.Collection(), id)
+	if err != nil {
+		return false, err
+	}
+	return visibleDoc(obj, doc) != nil, nil
+}
+
+// List return object list, use max to specific return object count
+//
+//	list,err := List(ctx, &Sample{},10)
+//
+func (c *TransactionMemory) List(ctx context.Context, obj db.Object, max int) ([]db.Object, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return nil, err
+	}
+	return c.Query(obj).Limit(max).Return(ctx)
+}
+
+// Select return object field from data store, return nil if object does not exist
+//
+//	return Select(ctx, &Sample{}, id, field)
+//
+func (c *TransactionMemory) Select(ctx context.Context, obj db.Object, id, field string) (interface{}, error) {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return false, err
+	}
+	if err := db.AssertID(id); err != nil {
+		return false, err
+	}
+	doc, err := c.readDoc(obj.Collection(), id)
+	if err != nil {
+		return nil, err
+	}
+	return docToField(obj, id, field, visibleDoc(obj, doc))
+}
+
+// Query create query
+//
+//	c.Query(ctx, &Sample{}).Return(ctx)
+//
+func (c *TransactionMemory) Query(obj db.Object) db.Query {
+	query := &QueryMemory{
+		BaseQuery: db.BaseQuery{
+			QueryTransaction: c,
+			QueryObject:      obj,
+		},
+		client: c.client,
+	}
+	return db.ExcludeDeleted(query.defaultLimit(20), obj)
+}
+
+// Set object into table, If the document not exist, it will be created. If the document does exist, its contents will be overwritten with the newly provided data, if object does not have id, it will created using UUID
+//
+//	 err := Set(ctx, object)
+//
+func (c *TransactionMemory) Set(ctx context.Context, obj db.Object) error {
+	if err := db.AssertObject(ctx, obj, false); err != nil {
+		return err
+	}
+	c.client.BaseClient.BeforeSet(ctx, obj)
+	doc, err := objToDoc(obj)
+	if err != nil {
+		return errors.Wrapf(err, "tx set doc %v-%v", obj.Collection(), obj.ID())
+	}
+	w, err := c.versioned(obj, setWrite(obj.Collection(), obj.ID(), doc))
+	if err != nil {
+		return errors.Wrapf(err, "tx set doc %v-%v", obj.Collection(), obj.ID())
+	}
+	c.writes = append(c.writes, w)
+	return nil
+}
+
+// Update partial object field, create new one if object does not exist
+//
+//	err = Update(ctx, Sample, map[string]interface{}{
+//		"desc": "hi",
+//	})
+//
+func (c *TransactionMemory) Update(ctx context.Context, obj db.Object, fields map[string]interface{}) error {
+	if err := db.AssertObject(ctx, obj, true); err != nil {
+		return err
+	}
+	doc, err := fieldsToDoc(fields)
+	if err != nil {
+		return errors.Wrapf(err, "tx update field %v-%v", obj.Collection(), obj.ID())
+	}
+	w, err := c.versioned(obj, updateWrite(obj, doc))
+	if err != nil {
+		return errors.Wrapf(err, "tx update field %v-%v", obj.Collection(), obj.ID())
+	}
+	c.writes = append(c.writes, w)
+	return nil
+}
+
+// Increment value on object field, return error if object does not exist
+//
+//	err := Increment(ctx,sample, "Value", 2)
+//
+func (c *TransactionMemory) Increment(ctx context.Context, obj db.Object, field string, value int) error {
+	if err := db.AssertObject(ctx, obj, true); err != nil {
+		return err
+	}
+	c.writes = append(c.writes, incrementWrite(obj.Collection(), obj.ID(), field, int64(value)))
+	return nil
+}
+
+// Delete object, no error if id not exist. object use soft delete will only set delete time
+//
+//	Delete(ctx, sample)
+//
+func (c *TransactionMemory) Delete(ctx context.Context, obj db.Object) error {
+	if err := db.AssertObject(ctx, obj, true); err != nil {
+		return err
+	}
+	c.writes = append(c.writes, deleteObjWrite(obj))
+	return nil
+}
+
+// isShardExists return true if shard already exist
+//
+func (c *TransactionMemory) isShardExists(collection, id string) (bool, error) {
+	doc, err := c.readDoc(collection, id)
+	if err != nil {
+		return false, err
+	}
+	return doc != nil, nil
+}
+
+// createShard create a shard
+//
+func (c *TransactionMemory) createShard(collection, id string, shard map[string]interface{}) error {
+	if shard[db.MetaN] == nil {
+		return errors.New("N must not nil")
+	}
+	doc, err := fieldsToDoc(shard)
+	if err != nil {
+		return errors.Wrap(err, "tx set shard")
+	}
+	c.writes = append(c.writes, mergeWrite(collection, id, doc))
+	return nil
+}
+
+// incrementShard increment shard count
+//
+func (c *TransactionMemory) incrementShard(collection, id string, value interface{}) error {
+	if value == nil {
+		return errors.New("value must not nil")
+	}
+	number, err := numberValue(value)
+	if err != nil {
+		return errors.Wrap(err, "tx update shard")
+	}
+	c.writes = append(c.writes, incrementWrite(collection, id, db.MetaN, number))
+	return nil
+}
@@ -0,0 +1,271 @@
+package mdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample := &Sample{
+		Name:  "test-tx",
+		Value: 1,
+	}
+
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return tx.Set(ctx, sample)
+	})
+	assert.Nil(err)
+
+	found, err := client.Query(&Sample{}).Where("Name", "==", "test-tx").ReturnExists(ctx)
+	assert.Nil(err)
+	assert.True(found)
+
+	// read before write
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		sample2, err := tx.Get(ctx, &Sample{}, sample.ID())
+		assert.Nil(err)
+		assert.NotNil(sample2.(*Sample).PObj)
+
+		found, err := tx.Exists(ctx, &Sample{}, sample.ID())
+		assert.Nil(err)
+		assert.True(found)
+
+		list, err := tx.List(ctx, &Sample{}, 10)
+		assert.Nil(err)
+		assert.Len(list, 1)
+
+		value, err := tx.Select(ctx, &Sample{}, sample.ID(), "Value")
+		assert.Nil(err)
+		assert.Equal(int64(1), value)
+		return nil
+	})
+	assert.Nil(err)
+
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		err := tx.Update(ctx, sample, map[string]interface{}{
+			"Value": 2,
+		})
+		assert.Nil(err)
+		err = tx.Increment(ctx, sample, "Value", 1)
+		assert.Nil(err)
+
+		// write is not visible before commit
+		value, err := client.Select(ctx, &Sample{}, sample.ID(), "Value")
+		assert.Nil(err)
+		assert.Equal(int64(1), value)
+
+		// read after write
+		_, err = tx.Get(ctx, &Sample{}, sample.ID())
+		assert.NotNil(err)
+		_, err = tx.Query(&Sample{}).Return(ctx)
+		assert.NotNil(err)
+		return nil
+	})
+	assert.Nil(err)
+
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		obj, err := tx.Query(&Sample{}).Where("Name", "==", "test-tx").ReturnFirst(ctx)
+		assert.Nil(err)
+		assert.Equal(3, obj.(*Sample).Value)
+		return tx.Delete(ctx, sample)
+	})
+	assert.Nil(err)
+
+	found, err = client.Query(&Sample{}).Where("Name", "==", "test-tx").ReturnExists(ctx)
+	assert.Nil(err)
+	assert.False(found)
+}
+
+func TestTransactionFail(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample := &Sample{Name: "test-tx-fail"}
+
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		err := tx.Set(ctx, sample)
+		assert.Nil(err)
+		return errors.New("something wrong")
+	})
+	assert.NotNil(err)
+
+	found, err := client.Exists(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.False(found)
+
+	// increment on not exist object will fail whole transaction
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		err := tx.Set(ctx, sample)
+		assert.Nil(err)
+		notExist := &Sample{}
+		notExist.SetID("not-exist")
+		return tx.Increment(ctx, notExist, "Value", 1)
+	})
+	assert.NotNil(err)
+
+	found, err = client.Exists(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.False(found)
+}
+
+func TestTransactionAssert(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		_, err := tx.Get(ctx, nil, "")
+		assert.NotNil(err)
+		_, err = tx.Get(ctx, &Sample{}, "")
+		assert.NotNil(err)
+		_, err = tx.Exists(ctx, nil, "")
+		assert.NotNil(err)
+		_, err = tx.List(ctx, nil, 10)
+		assert.NotNil(err)
+		_, err = tx.Select(ctx, &Sample{}, "", "")
+		assert.NotNil(err)
+		err = tx.Set(ctx, nil)
+		assert.NotNil(err)
+		err = tx.Update(ctx, nil, nil)
+		assert.NotNil(err)
+		err = tx.Increment(ctx, nil, "Value", 1)
+		assert.NotNil(err)
+		err = tx.Delete(ctx, nil)
+		assert.NotNil(err)
+		err = tx.(*TransactionMemory).createShard("not-exists", "not-exists", map[string]interface{}{})
+		assert.NotNil(err)
+		err = tx.(*TransactionMemory).incrementShard("not-exists", "not-exists", nil)
+		assert.NotNil(err)
+		return nil
+	})
+	assert.Nil(err)
+}
+
+func TestTransactionVersion(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	sample := &SampleVersioned{Name: "v1"}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		obj, err := tx.Get(ctx, &SampleVersioned{}, sample.ID())
+		if err != nil {
+			return err
+		}
+		obj.(*SampleVersioned).Name = "v2"
+		if err := tx.Set(ctx, obj); err != nil {
+			return err
+		}
+		// version increment after commit
+		assert.Equal(int64(1), obj.Version())
+		return nil
+	})
+	assert.Nil(err)
+
+	// stale object
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		return tx.Set(ctx, sample)
+	})
+	assert.True(errors.Is(err, db.ErrConflict))
+	assert.Equal(int64(1), sample.Version())
+
+	// failed transaction will not increment version
+	fresh, err := client.Get(ctx, &SampleVersioned{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal(int64(2), fresh.Version())
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		if err := tx.Update(ctx, fresh, map[string]interface{}{"Name": "v3"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.NotNil(err)
+	assert.Equal(int64(2), fresh.Version())
+
+	// versioned write must happen before other write
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		if err := tx.Set(ctx, &Sample{Name: "other"}); err != nil {
+			return err
+		}
+		return tx.Set(ctx, fresh)
+	})
+	assert.NotNil(err)
+}
+
+func TestTransactionReadChanged(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	sample := &Sample{Name: "read-changed", Value: 1}
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+
+	// document changed outside transaction between read and commit, transaction run again
+	attempts := 0
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		attempts++
+		obj, err := tx.Get(ctx, &Sample{}, sample.ID())
+		if err != nil {
+			return err
+		}
+		if attempts == 1 {
+			assert.Nil(client.Increment(ctx, sample, "Value", 10))
+		}
+		read := obj.(*Sample)
+		read.Value++
+		return tx.Set(ctx, read)
+	})
+	assert.Nil(err)
+	assert.Equal(2, attempts)
+	obj, err := client.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal(12, obj.(*Sample).Value)
+
+	// document returned by query is checked too
+	attempts = 0
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		attempts++
+		list, err := tx.Query(&Sample{}).Where("Name", "==", "read-changed").Return(ctx)
+		if err != nil {
+			return err
+		}
+		assert.Len(list, 1)
+		if attempts == 1 {
+			assert.Nil(client.Increment(ctx, sample, "Value", 10))
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(2, attempts)
+
+	// always changed return conflict
+	attempts = 0
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		attempts++
+		if _, err := tx.Get(ctx, &Sample{}, sample.ID()); err != nil {
+			return err
+		}
+		assert.Nil(client.Increment(ctx, sample, "Value", 1))
+		return tx.Update(ctx, sample, map[string]interface{}{"Name": "lost"})
+	})
+	assert.True(errors.Is(err, db.ErrConflict))
+	assert.Equal(maxTransactionAttempts, attempts)
+	obj, err = client.Get(ctx, &Sample{}, sample.ID())
+	assert.Nil(err)
+	assert.Equal("read-changed", obj.(*Sample).Name)
+}
@@ -0,0 +1,555 @@
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var typeOfTime = reflect.TypeOf(time.Time{})
+
+// field describe a struct field stored in document
+//
+type field struct {
+	// name is field name in document
+	//
+	name string
+
+	// index is field index used by reflect.Value.FieldByIndex()
+	//
+	index []int
+
+	// omitEmpty is true if field should not be stored when empty
+	//
+	omitEmpty bool
+}
+
+// structFields return fields of struct type, use the same rule as firestore: exported field only, embedded struct will be flatten, `firestore:"name,omitempty"` tag is honoured and `firestore:"-"` will be skipped
+//
+//	fields := structFields(reflect.TypeOf(Sample{}))
+//
+func structFields(t reflect.Type) []field {
+	fields := []field{}
+	seen := map[string]bool{}
+	embedded := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("firestore")
+		if tag == "-" {
+			continue
+		}
+		name, options := parseTag(tag)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != typeOfTime {
+			embedded = append(embedded, f)
+			continue
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		seen[name] = true
+		fields = append(fields, field{
+			name:      name,
+			index:     f.Index,
+			omitEmpty: strings.Contains(options, "omitempty"),
+		})
+	}
+
+	// embedded fields has lower priority than direct fields
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		for _, inner := range structFields(ft) {
+			if seen[inner.name] {
+				continue
+			}
+			seen[inner.name] = true
+			inner.index = append([]int{f.Index[0]}, inner.index...)
+			fields = append(fields, inner)
+		}
+	}
+	return fields
+}
+
+// parseTag return name and options from firestore tag
+//
+func parseTag(tag string) (string, string) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+// fieldByIndex return field value by index, return invalid value if index go through nil pointer
+//
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// isEmptyValue return true if value is empty, it is the same rule firestore use on omitempty
+//
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	if v.Type() == typeOfTime {
+		return v.Interface().(time.Time).IsZero()
+	}
+	return false
+}
+
+// toDocument convert object to document
+//
+//	doc, err := toDocument(sample)
+//
+func toDocument(obj interface{}) (map[string]interface{}, error) {
+	value, err := toValue(reflect.ValueOf(obj))
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("%T can not convert to document", obj)
+	}
+	return doc, nil
+}
+
+// toValue convert go value to value stored in document, integer become int64, float become float64, struct become map and slice become []interface{}
+//
+//	value, err := toValue(reflect.ValueOf(1)) // int64(1)
+//
+func toValue(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type() == typeOfTime {
+		return v.Interface().(time.Time).UTC(), nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return toValue(v.Elem())
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return append([]byte{}, v.Bytes()...), nil
+		}
+		return toArray(v)
+	case reflect.Array:
+		return toArray(v)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errors.Errorf("map key must be string, got %v", v.Type().Key())
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := map[string]interface{}{}
+		iter := v.MapRange()
+		for iter.Next() {
+			value, err := toValue(iter.Value())
+			if err != nil {
+				return nil, errors.Wrapf(err, "map key %v", iter.Key().String())
+			}
+			m[iter.Key().String()] = value
+		}
+		return m, nil
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		for _, f := range structFields(v.Type()) {
+			fv := fieldByIndex(v, f.index, false)
+			if !fv.IsValid() || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			value, err := toValue(fv)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %v", f.name)
+			}
+			m[f.name] = value
+		}
+		return m, nil
+	}
+	return nil, errors.Errorf("type %v not support", v.Type())
+}
+
+// toArray convert slice or array to []interface{}
+//
+func toArray(v reflect.Value) ([]interface{}, error) {
+	list := make([]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		value, err := toValue(v.Index(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "index %v", i)
+		}
+		list[i] = value
+	}
+	return list, nil
+}
+
+// fromDocument set document data to object, field not in object will be ignore
+//
+//	err := fromDocument(doc, sample)
+//
+func fromDocument(doc map[string]interface{}, obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.Errorf("%T must be non nil pointer", obj)
+	}
+	return setValue(v.Elem(), doc)
+}
+
+// setValue set document value to go value
+//
+func setValue(dst reflect.Value, src interface{}) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Type() == typeOfTime {
+		t, ok := src.(time.Time)
+		if !ok {
+			return typeErr(dst, src)
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() > 0 {
+			return typeErr(dst, src)
+		}
+		dst.Set(reflect.ValueOf(copyValue(src)))
+		return nil
+	case reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		if err := setValue(elem.Elem(), src); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return typeErr(dst, src)
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return typeErr(dst, src)
+		}
+		dst.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch x := src.(type) {
+		case int64:
+			i = x
+		case float64:
+			i = int64(x)
+			if float64(i) != x {
+				return typeErr(dst, src)
+			}
+		default:
+			return typeErr(dst, src)
+		}
+		if dst.OverflowInt(i) {
+			return errors.Errorf("value %v overflow %v", i, dst.Type())
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		switch x := src.(type) {
+		case int64:
+			u = uint64(x)
+		case float64:
+			u = uint64(x)
+			if float64(u) != x {
+				return typeErr(dst, src)
+			}
+		default:
+			return typeErr(dst, src)
+		}
+		if dst.OverflowUint(u) {
+			return errors.Errorf("value %v overflow %v", u, dst.Type())
+		}
+		dst.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch x := src.(type) {
+		case int64:
+			f = float64(x)
+		case float64:
+			f = x
+		default:
+			return typeErr(dst, src)
+		}
+		dst.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		if b, ok := src.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		list, ok := src.([]interface{})
+		if !ok {
+			return typeErr(dst, src)
+		}
+		slice := reflect.MakeSlice(dst.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return errors.Wrapf(err, "index %v", i)
+			}
+		}
+		dst.Set(slice)
+		return nil
+	case reflect.Array:
+		list, ok := src.([]interface{})
+		if !ok {
+			return typeErr(dst, src)
+		}
+		for i := 0; i < dst.Len(); i++ {
+			var item interface{}
+			if i < len(list) {
+				item = list[i]
+			}
+			if err := setValue(dst.Index(i), item); err != nil {
+				return errors.Wrapf(err, "index %v", i)
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return typeErr(dst, src)
+		}
+		result := reflect.MakeMapWithSize(dst.Type(), len(m))
+		for k, item := range m {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := setValue(elem, item); err != nil {
+				return errors.Wrapf(err, "map key %v", k)
+			}
+			result.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(result)
+		return nil
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return typeErr(dst, src)
+		}
+		for _, f := range structFields(dst.Type()) {
+			item, found := m[f.name]
+			if !found {
+				continue
+			}
+			if err := setValue(fieldByIndex(dst, f.index, true), item); err != nil {
+				return errors.Wrapf(err, "field %v", f.name)
+			}
+		}
+		return nil
+	}
+	return typeErr(dst, src)
+}
+
+// typeErr return error when document value can not set to go value
+//
+func typeErr(dst reflect.Value, src interface{}) error {
+	return errors.Errorf("can not set %T to %v", src, dst.Type())
+}
+
+// copyValue deep copy document value
+//
+func copyValue(value interface{}) interface{} {
+	switch x := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			m[k] = copyValue(v)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(x))
+		for i, v := range x {
+			list[i] = copyValue(v)
+		}
+		return list
+	case []byte:
+		return append([]byte{}, x...)
+	}
+	return value
+}
+
+// valueAt return value at dot separated path, return false if path not exist
+//
+//	value, found := valueAt(doc, "PObj.Name")
+//
+func valueAt(doc map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, name := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// typeOrder return value type order, it is the same order firestore used to compare value in different type
+//
+func typeOrder(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int64, float64:
+		return 2
+	case time.Time:
+		return 3
+	case string:
+		return 4
+	case []byte:
+		return 5
+	case []interface{}:
+		return 6
+	case map[string]interface{}:
+		return 7
+	}
+	return 8
+}
+
+// compareValues return -1 if a < b, 0 if a == b, 1 if a > b. value in different type will compared by type order
+//
+func compareValues(a, b interface{}) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return compareInt(int64(ta), int64(tb))
+	}
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		if x == y {
+			return 0
+		}
+		if !x {
+			return -1
+		}
+		return 1
+	case int64:
+		if y, ok := b.(int64); ok {
+			return compareInt(x, y)
+		}
+		return compareFloat(float64(x), b.(float64))
+	case float64:
+		if y, ok := b.(int64); ok {
+			return compareFloat(x, float64(y))
+		}
+		return compareFloat(x, b.(float64))
+	case time.Time:
+		y := b.(time.Time)
+		if x.Before(y) {
+			return -1
+		}
+		if x.After(y) {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	case []byte:
+		return bytes.Compare(x, b.([]byte))
+	case []interface{}:
+		y := b.([]interface{})
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compareValues(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInt(int64(len(x)), int64(len(y)))
+	case map[string]interface{}:
+		return strings.Compare(fmt.Sprint(x), fmt.Sprint(b))
+	}
+	return 0
+}
+
+// compareInt compare two int64
+//
+func compareInt(a, b int64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// compareFloat compare two float64
+//
+func compareFloat(a, b float64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
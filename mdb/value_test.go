@@ -0,0 +1,86 @@
+package mdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueDocument(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	now := time.Now().UTC()
+	sample := &Sample{
+		Name:    "a",
+		Value:   1,
+		Map:     map[string]string{"k": "v"},
+		Numbers: []int{1, 2},
+		PObj:    &PlainObject{ID: "p", Name: "plain"},
+	}
+	sample.SetID("id")
+	sample.SetCreateTime(now)
+
+	doc, err := toDocument(sample)
+	assert.Nil(err)
+	assert.Equal("a", doc["Name"])
+	assert.Equal(int64(1), doc["Value"])
+	assert.Equal(now, doc["CreateTime"])
+	assert.Equal([]interface{}{int64(1), int64(2)}, doc["Numbers"])
+	assert.Equal(map[string]interface{}{"k": "v"}, doc["Map"])
+	assert.Equal(map[string]interface{}{"ID": "p", "Name": "plain"}, doc["PObj"])
+
+	// omitempty and unexported field will not save
+	_, found := doc["Tag"]
+	assert.False(found)
+	_, found = doc["UpdateTime"]
+	assert.False(found)
+	_, found = doc["id"]
+	assert.False(found)
+
+	sample2 := sample.Factory().(*Sample)
+	err = fromDocument(doc, sample2)
+	assert.Nil(err)
+	assert.Equal("a", sample2.Name)
+	assert.Equal(1, sample2.Value)
+	assert.Equal(now, sample2.CreateTime())
+	assert.Equal([]int{1, 2}, sample2.Numbers)
+	assert.Equal("v", sample2.Map["k"])
+	assert.Equal("plain", sample2.PObj.Name)
+	assert.Empty(sample2.ID())
+
+	// type mismatch
+	err = fromDocument(map[string]interface{}{"Value": "not number"}, sample2)
+	assert.NotNil(err)
+
+	// not pointer
+	err = fromDocument(doc, Sample{})
+	assert.NotNil(err)
+}
+
+func TestValueAt(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	doc := map[string]interface{}{
+		"PObj": map[string]interface{}{"Name": "plain"},
+	}
+	value, found := valueAt(doc, "PObj.Name")
+	assert.True(found)
+	assert.Equal("plain", value)
+	_, found = valueAt(doc, "PObj.NotExist")
+	assert.False(found)
+	_, found = valueAt(doc, "PObj.Name.Deep")
+	assert.False(found)
+}
+
+func TestValueCompare(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	assert.Equal(0, compareValues(int64(1), float64(1)))
+	assert.Equal(-1, compareValues(int64(1), float64(1.5)))
+	assert.Equal(1, compareValues("b", "a"))
+	assert.Equal(-1, compareValues(nil, false))
+	assert.Equal(-1, compareValues(int64(100), "a"))
+	assert.Equal(-1, compareValues(time.Unix(1, 0), time.Unix(2, 0)))
+	assert.Equal(1, compareValues([]interface{}{"a", "b"}, []interface{}{"a"}))
+}
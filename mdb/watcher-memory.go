@@ -0,0 +1,177 @@
+package mdb
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/piyuo/libsrv/db"
+)
+
+// readFunc return current documents watched, key is document id
+//
+type readFunc func(ctx context.Context) (map[string]map[string]interface{}, error)
+
+// WatcherMemory deliver document change, client notify watcher on every commit and watcher compare documents with previous result to find change
+//
+type WatcherMemory struct {
+	db.Watcher
+
+	// client is memory client
+	//
+	client *ClientMemory
+
+	// ctx is context used to read document, watcher stop when it canceled
+	//
+	ctx context.Context
+
+	// cancel stop watcher
+	//
+	cancel context.CancelFunc
+
+	// obj is object used to create changed object
+	//
+	obj db.Object
+
+	// read return current documents watched
+	//
+	read readFunc
+
+	// known is documents deliver to watcher, key is document id
+	//
+	known map[string]map[string]interface{}
+
+	// notify receive signal when client commit
+	//
+	notify chan struct{}
+
+	// changes deliver change to caller
+	//
+	changes chan *db.Change
+
+	// err is error stop watcher
+	//
+	err error
+}
+
+// newWatcher create watcher and start watch, return stopped watcher if err is not nil
+//
+func newWatcher(ctx context.Context, client *ClientMemory, obj db.Object, read readFunc, err error) *WatcherMemory {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &WatcherMemory{
+		client:  client,
+		ctx:     ctx,
+		cancel:  cancel,
+		obj:     obj,
+		read:    read,
+		known:   map[string]map[string]interface{}{},
+		notify:  make(chan struct{}, 1),
+		changes: make(chan *db.Change),
+	}
+	if err != nil {
+		c.err = err
+		close(c.changes)
+		return c
+	}
+	client.watch(c)
+	go c.run()
+	return c
+}
+
+// run read documents and deliver change every time client commit
+//
+func (c *WatcherMemory) run() {
+	defer close(c.changes)
+	defer c.client.unwatch(c)
+	for {
+		docs, err := c.read(c.ctx)
+		if err != nil {
+			if c.ctx.Err() == nil {
+				c.err = err
+			}
+			return
+		}
+		if !c.deliver(docs) {
+			return
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-c.notify:
+		}
+	}
+}
+
+// deliver compare documents with known documents and send change, return false if watcher stopped
+//
+func (c *WatcherMemory) deliver(docs map[string]map[string]interface{}) bool {
+	changes := []*db.Change{}
+	for _, id := range sortedIDs(docs) {
+		doc := docs[id]
+		known, found := c.known[id]
+		if found && reflect.DeepEqual(known, doc) {
+			continue
+		}
+		obj, err := docToObject(c.obj.Factory(), id, doc)
+		if err != nil {
+			c.err = err
+			return false
+		}
+		changeType := db.ChangeModified
+		if !found {
+			changeType = db.ChangeAdded
+		}
+		changes = append(changes, &db.Change{Type: changeType, ID: id, Object: obj})
+	}
+	for _, id := range sortedIDs(c.known) {
+		if _, found := docs[id]; !found {
+			changes = append(changes, &db.Change{Type: db.ChangeRemoved, ID: id})
+		}
+	}
+	c.known = docs
+
+	for _, change := range changes {
+		select {
+		case <-c.ctx.Done():
+			return false
+		case c.changes <- change:
+		}
+	}
+	return true
+}
+
+// sortedIDs return document ids in order
+//
+func sortedIDs(docs map[string]map[string]interface{}) []string {
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Changes return channel deliver object change, channel will be closed when watcher stop
+//
+//	for change := range watcher.Changes() {
+//	}
+//
+func (c *WatcherMemory) Changes() <-chan *db.Change {
+	return c.changes
+}
+
+// Err return error which stop watcher, return nil if watcher stop by Close() or context canceled. only valid after Changes() channel closed
+//
+//	err := watcher.Err()
+//
+func (c *WatcherMemory) Err() error {
+	return c.err
+}
+
+// Close stop watcher, it is safe to call Close() multiple times
+//
+//	defer watcher.Close()
+//
+func (c *WatcherMemory) Close() {
+	c.cancel()
+}
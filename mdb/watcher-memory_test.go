@@ -0,0 +1,237 @@
+package mdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// nextChange return next change from watcher, return nil if no change in time or watcher stopped
+//
+func nextChange(watcher db.Watcher) *db.Change {
+	select {
+	case change := <-watcher.Changes():
+		return change
+	case <-time.After(2 * time.Second):
+		return nil
+	}
+}
+
+// waitStop return true if watcher stopped in time
+//
+func waitStop(watcher db.Watcher) bool {
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-watcher.Changes():
+			if !ok {
+				return true
+			}
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+func TestWatchQuery(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleQueryClient(ctx)
+
+	watcher := client.Query(&Sample{}).Where("Tag", "==", "t1").Watch(ctx)
+	defer watcher.Close()
+
+	// first result
+	change := nextChange(watcher)
+	assert.Equal(db.ChangeAdded, change.Type)
+	assert.Equal("a", change.ID)
+	assert.Equal("a city", change.Object.(*Sample).Name)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeAdded, change.Type)
+	assert.Equal("b", change.ID)
+
+	// modified
+	err := client.Update(ctx, change.Object, map[string]interface{}{"Value": 20})
+	assert.Nil(err)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeModified, change.Type)
+	assert.Equal("b", change.ID)
+	assert.Equal(20, change.Object.(*Sample).Value)
+
+	// enter result
+	c, err := client.Get(ctx, &Sample{}, "c")
+	assert.Nil(err)
+	err = client.Update(ctx, c, map[string]interface{}{"Tag": "t1"})
+	assert.Nil(err)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeAdded, change.Type)
+	assert.Equal("c", change.ID)
+
+	// leave result
+	err = client.Update(ctx, c, map[string]interface{}{"Tag": "t2"})
+	assert.Nil(err)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeRemoved, change.Type)
+	assert.Equal("c", change.ID)
+	assert.Nil(change.Object)
+
+	// write outside result will not deliver change
+	err = client.Update(ctx, c, map[string]interface{}{"Value": 30})
+	assert.Nil(err)
+
+	// deleted
+	a, err := client.Get(ctx, &Sample{}, "a")
+	assert.Nil(err)
+	err = client.Delete(ctx, a)
+	assert.Nil(err)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeRemoved, change.Type)
+	assert.Equal("a", change.ID)
+
+	watcher.Close()
+	assert.True(waitStop(watcher))
+	assert.Nil(watcher.Err())
+}
+
+func TestWatchGet(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	watcher := client.Watch(ctx, &Sample{}, "w")
+	defer watcher.Close()
+
+	sample := &Sample{Name: "w1"}
+	sample.SetID("w")
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+	change := nextChange(watcher)
+	assert.Equal(db.ChangeAdded, change.Type)
+	assert.Equal("w", change.ID)
+	assert.Equal("w1", change.Object.(*Sample).Name)
+
+	sample.Name = "w2"
+	err = client.Set(ctx, sample)
+	assert.Nil(err)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeModified, change.Type)
+	assert.Equal("w2", change.Object.(*Sample).Name)
+
+	err = client.Delete(ctx, sample)
+	assert.Nil(err)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeRemoved, change.Type)
+	assert.Equal("w", change.ID)
+}
+
+func TestWatchSoftDelete(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	sample := &SampleSoftDelete{Name: "s"}
+	sample.SetID("s")
+	err := client.Set(ctx, sample)
+	assert.Nil(err)
+
+	watcher := client.Watch(ctx, &SampleSoftDelete{}, "s")
+	defer watcher.Close()
+	change := nextChange(watcher)
+	assert.Equal(db.ChangeAdded, change.Type)
+
+	// soft deleted object is removed
+	err = client.Delete(ctx, sample)
+	assert.Nil(err)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeRemoved, change.Type)
+
+	sample.SetID("s")
+	err = client.Restore(ctx, sample)
+	assert.Nil(err)
+	change = nextChange(watcher)
+	assert.Equal(db.ChangeAdded, change.Type)
+}
+
+func TestWatchCancel(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	client := sampleQueryClient(ctx)
+
+	watcher := client.Query(&Sample{}).Watch(ctx)
+	cancel()
+	assert.True(waitStop(watcher))
+	assert.Nil(watcher.Err())
+
+	// close multiple times
+	watcher.Close()
+	watcher.Close()
+}
+
+func TestWatchError(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+
+	watcher := client.Query(nil).Watch(ctx)
+	assert.True(waitStop(watcher))
+	assert.NotNil(watcher.Err())
+
+	watcher = client.Watch(ctx, nil, "a")
+	assert.True(waitStop(watcher))
+	assert.NotNil(watcher.Err())
+
+	watcher = client.Query(&Sample{}).WhereFilter(db.Or(db.Field("Tag", "==", "a"), db.Field("Tag", "==", "b"))).Watch(ctx)
+	assert.True(waitStop(watcher))
+	assert.NotNil(watcher.Err())
+
+	watcher = client.Query(&Sample{}).Where("Tag", "bad", "a").Watch(ctx)
+	assert.True(waitStop(watcher))
+	assert.NotNil(watcher.Err())
+
+	err := client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		watcher := tx.Query(&Sample{}).Watch(ctx)
+		assert.True(waitStop(watcher))
+		assert.NotNil(watcher.Err())
+		return nil
+	})
+	assert.Nil(err)
+}
+
+func TestWatchMoreThanDefaultLimit(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	client := sampleClient()
+	for i := 0; i < 25; i++ {
+		err := client.Set(ctx, &Sample{Name: "many", Value: i})
+		assert.Nil(err)
+	}
+
+	watcher := client.Query(&Sample{}).Where("Name", "==", "many").Watch(ctx)
+	defer watcher.Close()
+	for i := 0; i < 25; i++ {
+		change := nextChange(watcher)
+		assert.NotNil(change)
+		assert.Equal(db.ChangeAdded, change.Type)
+	}
+
+	// explicit limit is kept
+	limited := client.Query(&Sample{}).Where("Name", "==", "many").Limit(3).Watch(ctx)
+	defer limited.Close()
+	for i := 0; i < 3; i++ {
+		assert.NotNil(nextChange(limited))
+	}
+	select {
+	case change := <-limited.Changes():
+		assert.Nil(change)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
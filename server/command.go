@@ -35,10 +35,15 @@ func setDeadlineCommand(ctx context.Context) (context.Context, context.CancelFun
 
 // CommandEntry create command handler function
 //
+//	handler := CommandEntry(&mock.MapXXX{})
+//
 func CommandEntry(cmdMap command.IMap) http.Handler {
-	dispatch := &command.Dispatch{
-		Map: cmdMap,
-	}
+	return commandEntry(&command.Dispatch{Map: cmdMap})
+}
+
+// commandEntry create command handler function using dispatch, so server can set duplicate window on dispatch
+//
+func commandEntry(dispatch *command.Dispatch) http.Handler {
 	f := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 
@@ -51,8 +56,14 @@ func CommandEntry(cmdMap command.IMap) http.Handler {
 		ctx, cancel := setDeadlineCommand(r.Context())
 		defer cancel()
 
-		//add request to context
+		//add request and trace to context
 		ctx = env.SetRequest(ctx, r)
+		ctx = withTrace(ctx, r)
+
+		var bytes []byte
+		defer recoverPanic(ctx, w, r, http.StatusInternalServerError, func() []log.Field {
+			return actionFields(dispatch, bytes)
+		})
 
 		if r.Body == nil {
 			WriteStatus(w, http.StatusBadRequest, "no request")
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/piyuo/libsrv/command"
 	"github.com/piyuo/libsrv/command/mock"
 	"github.com/stretchr/testify/assert"
 )
@@ -41,6 +43,37 @@ func TestServerEmptyRequestWillReturnBadRequest(t *testing.T) {
 	http.DefaultServeMux = new(http.ServeMux)
 }
 
+func TestCommandEntryDuplicate(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	dispatch := &command.Dispatch{Map: &mock.MapXXX{}}
+	blockBytes, err := dispatch.EncodeCommand(command.BlockHighFrequency.XXX_MapID(), command.BlockHighFrequency)
+	assert.Nil(err)
+	serve := func(handler http.Handler, actBytes []byte) []byte {
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(actBytes))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(http.StatusOK, resp.Result().StatusCode)
+		return resp.Body.Bytes()
+	}
+	respond := newTestAction("duplicate")
+	_, bigData := newBigDataAction()
+
+	// duplicate window reject the same command
+	handler := commandEntry(&command.Dispatch{Map: &mock.MapXXX{}, DuplicateWindow: 10 * time.Second})
+	assert.NotEqual(blockBytes, serve(handler, respond))
+	assert.Equal(blockBytes, serve(handler, respond))
+
+	// idempotent action is never rejected
+	assert.NotEqual(blockBytes, serve(handler, bigData))
+	assert.NotEqual(blockBytes, serve(handler, bigData))
+
+	// no duplicate check by default
+	handler = CommandEntry(&mock.MapXXX{})
+	assert.NotEqual(blockBytes, serve(handler, respond))
+	assert.NotEqual(blockBytes, serve(handler, respond))
+}
+
 func TestCmdDeadline(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/piyuo/libsrv/cache"
+	"github.com/piyuo/libsrv/env"
+	"github.com/pkg/errors"
+)
+
+// CacheReport is cache statistics report by CacheStatsHandler
+//
+type CacheReport struct {
+
+	// Count is cache entry total count
+	//
+	Count int64 `json:"count"`
+
+	// Stats is cache counter by key prefix
+	//
+	Stats []cache.Stat `json:"stats"`
+}
+
+// debugTokenHeader is request header carry debug token
+//
+const debugTokenHeader = "X-Debug-Token"
+
+// allowDebugWrite return true if request can change server state, env.Debug is true or X-Debug-Token header match os env DEBUG_TOKEN
+//
+func allowDebugWrite(r *http.Request) bool {
+	if env.Debug {
+		return true
+	}
+	token := os.Getenv("DEBUG_TOKEN")
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(debugTokenHeader)), []byte(token)) == 1
+}
+
+// CacheStatsHandler report cache statistics as json, GET is read only. POST with query string "invalidate" invalidate namespace before report, it is only allowed when env.Debug is true or X-Debug-Token header match os env DEBUG_TOKEN
+//
+//	server := &Server{
+//		HTTPHandlers: map[string]HTTPHandler{"/debug/cache": CacheStatsHandler},
+//	}
+//	// GET /debug/cache
+//	// POST /debug/cache?invalidate=m-
+//
+func CacheStatsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if prefix, ok := Query(r, "invalidate"); ok {
+		if r.Method != http.MethodPost {
+			WriteStatus(w, http.StatusMethodNotAllowed, "invalidate need POST")
+			return nil
+		}
+		if !allowDebugWrite(r) {
+			WriteStatus(w, http.StatusForbidden, "invalidate not allowed")
+			return nil
+		}
+		if err := cache.InvalidateNamespace(prefix); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return nil
+		}
+	}
+	bytes, err := json.Marshal(&CacheReport{
+		Count: cache.Count(),
+		Stats: cache.Stats(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal cache report")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytes)
+	return nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/piyuo/libsrv/cache"
+	"github.com/piyuo/libsrv/env"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheStatsHandler(t *testing.T) {
+	assert := assert.New(t)
+	cache.SetString("debug-a", "1", 0)
+	cache.Get("debug-a")
+	cache.Get("debug-b")
+
+	req, _ := http.NewRequest("GET", "/debug/cache", nil)
+	resp := httptest.NewRecorder()
+	err := CacheStatsHandler(context.Background(), resp, req)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("application/json", resp.Header().Get("Content-Type"))
+	report := &CacheReport{}
+	err = json.Unmarshal(resp.Body.Bytes(), report)
+	assert.Nil(err)
+	assert.True(report.Count > 0)
+	var stat *cache.Stat
+	for i := range report.Stats {
+		if report.Stats[i].Prefix == "debug-" {
+			stat = &report.Stats[i]
+		}
+	}
+	assert.NotNil(stat)
+	assert.Equal(int64(1), stat.Hits)
+	assert.Equal(int64(1), stat.Misses)
+
+	// GET is read only
+	req, _ = http.NewRequest("GET", "/debug/cache?invalidate=debug-", nil)
+	resp = httptest.NewRecorder()
+	err = CacheStatsHandler(context.Background(), resp, req)
+	assert.Nil(err)
+	assert.Equal(http.StatusMethodNotAllowed, resp.Code)
+	found, _, _ := cache.Get("debug-a")
+	assert.True(found)
+
+	// POST without debug or token is forbidden
+	debug := env.Debug
+	defer func() { env.Debug = debug }()
+	env.Debug = false
+	req, _ = http.NewRequest("POST", "/debug/cache?invalidate=debug-", nil)
+	resp = httptest.NewRecorder()
+	err = CacheStatsHandler(context.Background(), resp, req)
+	assert.Nil(err)
+	assert.Equal(http.StatusForbidden, resp.Code)
+
+	// POST with token invalidate namespace
+	t.Setenv("DEBUG_TOKEN", "secret")
+	req, _ = http.NewRequest("POST", "/debug/cache?invalidate=debug-", nil)
+	req.Header.Set(debugTokenHeader, "wrong")
+	resp = httptest.NewRecorder()
+	err = CacheStatsHandler(context.Background(), resp, req)
+	assert.Nil(err)
+	assert.Equal(http.StatusForbidden, resp.Code)
+
+	req.Header.Set(debugTokenHeader, "secret")
+	resp = httptest.NewRecorder()
+	err = CacheStatsHandler(context.Background(), resp, req)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.Code)
+	found, _, _ = cache.Get("debug-a")
+	assert.False(found)
+
+	// POST in debug mode
+	env.Debug = true
+	req, _ = http.NewRequest("POST", "/debug/cache?invalidate=debug", nil)
+	resp = httptest.NewRecorder()
+	err = CacheStatsHandler(context.Background(), resp, req)
+	assert.Nil(err)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+}
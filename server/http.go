@@ -39,6 +39,8 @@ func HTTPEntry(httpHandler HTTPHandler) http.Handler {
 		//add deadline to context
 		ctx, cancel := setDeadlineHTTP(r.Context())
 		defer cancel()
+		ctx = withTrace(ctx, r)
+		defer recoverPanic(ctx, w, r, http.StatusInternalServerError, nil)
 
 		err := httpHandler(ctx, w, r)
 		if err != nil {
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/piyuo/libsrv/command"
+	"github.com/piyuo/libsrv/log"
+	"github.com/pkg/errors"
+)
+
+// recoverPanic recover panic in handler, report it with stack and request metadata then write status code to response, it must be called by defer. fields is called only when panic happen to add more metadata, it can be nil
+//
+//	defer recoverPanic(ctx, w, r, http.StatusInternalServerError, nil)
+//
+func recoverPanic(ctx context.Context, w http.ResponseWriter, r *http.Request, statusCode int, fields func() []log.Field) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if recovered == http.ErrAbortHandler {
+		// let net/http abort response as handler intended
+		panic(recovered)
+	}
+	// request may be canceled or timed out when panic happen, report it anyway
+	ctx = withoutCancel(ctx)
+	ctx = log.With(ctx, log.String("path", r.URL.Path))
+	if fields != nil {
+		ctx = log.With(ctx, fields()...)
+	}
+	// error created here carry stack of panicking goroutine
+	log.Error(ctx, errors.Errorf("panic: %v", recovered))
+	WriteStatus(w, statusCode, http.StatusText(statusCode))
+}
+
+// detachedContext keep value of parent but is never canceled and has no deadline
+//
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (c detachedContext) Done() <-chan struct{} { return nil }
+
+func (c detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// withoutCancel return context keep value of ctx but is not canceled when ctx is canceled
+//
+//	ctx = withoutCancel(ctx)
+//
+func withoutCancel(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// actionFields return action name field of command, return nil if command can not be decoded
+//
+//	fields := actionFields(dispatch, bytes)
+//
+func actionFields(dispatch *command.Dispatch, bytes []byte) (fields []log.Field) {
+	defer func() {
+		// broken map may panic again, ignore it so panic is still reported
+		if recover() != nil {
+			fields = nil
+		}
+	}()
+	_, action, err := dispatch.DecodeCommand(bytes)
+	if err != nil {
+		return nil
+	}
+	if named, ok := action.(command.Action); ok {
+		return []log.Field{log.String("action", named.XXX_MapName())}
+	}
+	return nil
+}
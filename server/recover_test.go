@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/command"
+	"github.com/piyuo/libsrv/command/mock"
+	"github.com/piyuo/libsrv/env"
+	"github.com/piyuo/libsrv/gerror"
+	"github.com/piyuo/libsrv/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// brokenMap panic when create object
+//
+type brokenMap struct{}
+
+func (c *brokenMap) NewObjectByID(id uint16) interface{} {
+	panic("broken map")
+}
+
+// useLocalReporter send error report to local transport during test
+//
+func useLocalReporter() *gerror.LocalTransport {
+	transport := gerror.NewLocalTransport()
+	gerror.Use(gerror.NewReporter(transport, gerror.ReporterOption{}))
+	return transport
+}
+
+// errorEntries return error entry in sink
+//
+func errorEntries(sink *log.MemorySink) []log.Entry {
+	var entries []log.Entry
+	for _, entry := range sink.Entries() {
+		if entry.Level == "ERROR" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestRecoverHTTP(t *testing.T) {
+	assert := assert.New(t)
+	transport := useLocalReporter()
+	defer gerror.Use(nil)
+	log.ForceStopLog(false)
+	defer log.ForceStopLog(true)
+	sink := log.NewMemorySink()
+
+	req, _ := http.NewRequest("GET", "/api", nil)
+	req = req.WithContext(log.WithSink(req.Context(), sink))
+	resp := httptest.NewRecorder()
+	HTTPEntry(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic("something wrong")
+	}).ServeHTTP(resp, req)
+	assert.Equal(http.StatusInternalServerError, resp.Result().StatusCode)
+
+	entries := errorEntries(sink)
+	assert.Len(entries, 1)
+	assert.Contains(entries[0].Message, "panic: something wrong")
+	assert.Equal("/api", entries[0].FieldMap()["path"])
+
+	assert.Nil(gerror.Shutdown(context.Background()))
+	reports := transport.Reports()
+	assert.Len(reports, 1)
+	assert.Equal("panic: something wrong", reports[0].Message)
+	assert.Contains(reports[0].Stack, "recover_test.go")
+}
+
+func TestRecoverCanceledContext(t *testing.T) {
+	assert := assert.New(t)
+	transport := useLocalReporter()
+	defer gerror.Use(nil)
+	log.ForceStopLog(false)
+	defer log.ForceStopLog(true)
+	sink := log.NewMemorySink()
+
+	// request canceled before panic is still reported
+	ctx, cancel := context.WithCancel(log.WithSink(context.Background(), sink))
+	cancel()
+	req, _ := http.NewRequest("GET", "/api", nil)
+	req = req.WithContext(ctx)
+	resp := httptest.NewRecorder()
+	HTTPEntry(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic("canceled")
+	}).ServeHTTP(resp, req)
+	assert.Equal(http.StatusInternalServerError, resp.Result().StatusCode)
+
+	entries := errorEntries(sink)
+	assert.Len(entries, 1)
+	assert.Contains(entries[0].Message, "panic: canceled")
+	assert.Nil(gerror.Shutdown(context.Background()))
+	assert.Len(transport.Reports(), 1)
+}
+
+func TestWithoutCancel(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx, cancel := context.WithTimeout(env.SetUserID(context.Background(), "user1"), time.Millisecond)
+	cancel()
+	detached := withoutCancel(ctx)
+	assert.Nil(detached.Err())
+	assert.Nil(detached.Done())
+	_, ok := detached.Deadline()
+	assert.False(ok)
+	assert.NotNil(ctx.Err())
+	assert.Equal("user1", env.GetUserID(detached))
+}
+
+func TestRecoverTask(t *testing.T) {
+	assert := assert.New(t)
+	useLocalReporter()
+	defer gerror.Use(nil)
+
+	req, _ := http.NewRequest("GET", "/task?debug=1", nil)
+	resp := httptest.NewRecorder()
+	TaskEntry(func(ctx context.Context, r *http.Request) error {
+		panic("something wrong")
+	}).ServeHTTP(resp, req)
+	assert.Equal(http.StatusInternalServerError, resp.Result().StatusCode)
+	assert.Nil(gerror.Shutdown(context.Background()))
+}
+
+func TestRecoverCommand(t *testing.T) {
+	assert := assert.New(t)
+	useLocalReporter()
+	defer gerror.Use(nil)
+	log.ForceStopLog(false)
+	defer log.ForceStopLog(true)
+	sink := log.NewMemorySink()
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("bad command"))
+	req = req.WithContext(log.WithSink(req.Context(), sink))
+	resp := httptest.NewRecorder()
+	CommandEntry(&brokenMap{}).ServeHTTP(resp, req)
+	assert.Equal(http.StatusInternalServerError, resp.Result().StatusCode)
+	assert.Len(errorEntries(sink), 1)
+	assert.Nil(gerror.Shutdown(context.Background()))
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	assert := assert.New(t)
+	req, _ := http.NewRequest("GET", "/", nil)
+	resp := httptest.NewRecorder()
+	assert.PanicsWithValue(http.ErrAbortHandler, func() {
+		HTTPEntry(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			panic(http.ErrAbortHandler)
+		}).ServeHTTP(resp, req)
+	})
+}
+
+func TestActionFields(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	dispatch := &command.Dispatch{Map: &mock.MapXXX{}}
+	action := &mock.CmdRespond{}
+	bytes, err := dispatch.EncodeCommand(action.XXX_MapID(), action)
+	assert.Nil(err)
+
+	fields := actionFields(dispatch, bytes)
+	assert.Len(fields, 1)
+	assert.Equal("action", fields[0].Key)
+	assert.Equal(action.XXX_MapName(), fields[0].String)
+
+	// broken map
+	assert.Nil(actionFields(&command.Dispatch{Map: &brokenMap{}}, bytes))
+	// bad command
+	assert.Nil(actionFields(dispatch, []byte{1}))
+}
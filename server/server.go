@@ -6,9 +6,13 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/piyuo/libsrv/command"
+	"github.com/piyuo/libsrv/env"
+	"github.com/piyuo/libsrv/gerror"
 	"github.com/piyuo/libsrv/log"
 )
 
@@ -41,6 +45,14 @@ type Server struct {
 	// TaskHandlers is task handler map to handle http request
 	//
 	TaskHandlers map[string]TaskHandler
+
+	// DuplicateWindow is how long the same command from the same user and ip is rejected with BlockHighFrequency, 0 mean no duplicate check. 10 seconds is recommended, action implement command.Idempotent is never rejected
+	//
+	DuplicateWindow time.Duration
+
+	// Limiter check command action name before it run, caller exceed rate limit get BlockShort or BlockLong, nil mean no rate limit. use firewall.NewLimiter() to create it
+	//
+	Limiter command.Limiter
 }
 
 // Start http server to listen request and serve content, defult port is 8080, you can change use export PORT="8080"
@@ -60,8 +72,41 @@ func (s *Server) Start() {
 		panic(msg)
 	}
 
-	if err := http.ListenAndServe(s.ready(ctx), nil); err != nil {
+	httpServer := &http.Server{Addr: s.ready(ctx)}
+	done := make(chan struct{})
+	go shutdownOnSignal(httpServer, done)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
 		log.Error(ctx, err)
+		return
+	}
+	// wait request drained and error report flushed
+	<-done
+}
+
+// shutdownTimeout is max time to finish request and send queued error report when server shutdown
+//
+const shutdownTimeout = 10 * time.Second
+
+// shutdownOnSignal wait SIGTERM or interrupt, then stop accept request and send queued error report, done is closed when shutdown finished
+//
+func shutdownOnSignal(httpServer *http.Server, done chan struct{}) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	<-sig
+	gracefulShutdown(httpServer, done)
+}
+
+// gracefulShutdown stop accept request, wait request finish and send queued error report, done is closed when shutdown finished
+//
+func gracefulShutdown(httpServer *http.Server, done chan struct{}) {
+	defer close(done)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Error(ctx, err)
+	}
+	if err := gerror.Shutdown(ctx); err != nil {
+		log.Warn(ctx, "error report shutdown: %v", err)
 	}
 }
 
@@ -72,7 +117,11 @@ func (s *Server) ready(ctx context.Context) string {
 
 	if s.CommandHandlers != nil {
 		for pattern, cmdMap := range s.CommandHandlers {
-			http.Handle(pattern, CommandEntry(cmdMap))
+			http.Handle(pattern, commandEntry(&command.Dispatch{
+				Map:             cmdMap,
+				DuplicateWindow: s.DuplicateWindow,
+				Limiter:         s.Limiter,
+			}))
 			break // only allow one command handler for now
 		}
 		//realease command handlers, don't need anymore
@@ -103,6 +152,18 @@ func (s *Server) ready(ctx context.Context) string {
 	return ":" + port
 }
 
+// withTrace set trace id from request header into context, new trace id is created if request has no trace id
+//
+//	ctx = withTrace(ctx, r)
+//
+func withTrace(ctx context.Context, r *http.Request) context.Context {
+	trace := env.TraceFromRequest(r)
+	if trace == "" {
+		trace = env.NewTrace()
+	}
+	return env.SetTrace(ctx, trace)
+}
+
 // handleRouteException convert error to status code, so client command service know how to deal with it
 //
 func handleRouteException(ctx context.Context, w http.ResponseWriter, err error) {
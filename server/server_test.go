@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/piyuo/libsrv/command"
 	"github.com/piyuo/libsrv/command/mock"
+	"github.com/piyuo/libsrv/env"
+	"github.com/piyuo/libsrv/gerror"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -239,3 +242,48 @@ func TestServerQuery(t *testing.T) {
 	assert.False(ok)
 	assert.Equal("", value)
 }
+
+func TestServerTrace(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(env.TraceHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+	var trace string
+	resp := httptest.NewRecorder()
+	HTTPEntry(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		trace = env.GetTrace(ctx)
+		return nil
+	}).ServeHTTP(resp, req)
+	assert.Equal("105445aa7843bc8bf206b12000100000", trace)
+
+	// new trace is created if request has no trace
+	req, _ = http.NewRequest("GET", "/", nil)
+	resp = httptest.NewRecorder()
+	HTTPEntry(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		trace = env.GetTrace(ctx)
+		return nil
+	}).ServeHTTP(resp, req)
+	assert.Len(trace, 32)
+}
+
+func TestServerShutdown(t *testing.T) {
+	assert := assert.New(t)
+	transport := useLocalReporter()
+	defer gerror.Use(nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	httpServer := &http.Server{}
+	served := make(chan error, 1)
+	go func() {
+		served <- httpServer.Serve(listener)
+	}()
+	gerror.Write(context.Background(), "queued before shutdown", "")
+
+	done := make(chan struct{})
+	gracefulShutdown(httpServer, done)
+	<-done
+	assert.Equal(http.ErrServerClosed, <-served)
+	// queued report flushed when shutdown finished
+	assert.Len(transport.Reports(), 1)
+}
@@ -40,6 +40,8 @@ func TaskEntry(taskHandler TaskHandler) http.Handler {
 		//add deadline to context
 		ctx, cancel := setDeadlineTask(r.Context())
 		defer cancel()
+		ctx = withTrace(ctx, r)
+		defer recoverPanic(ctx, w, r, http.StatusInternalServerError, nil)
 
 		err := TaskRun(ctx, taskHandler, r)
 		if err != nil {
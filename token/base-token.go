@@ -1,9 +1,11 @@
 package token
 
 import (
+	"context"
 	"time"
 
 	crypto "github.com/piyuo/libsrv/crypto"
+	"github.com/piyuo/libsrv/identifier"
 	"github.com/piyuo/libsrv/mapping"
 	"github.com/pkg/errors"
 )
@@ -26,6 +28,10 @@ const expiredFormat = "200601021504"
 //
 const keyExpired = "_"
 
+// keyID is token id key name
+//
+const keyID = "#"
+
 // NewToken return a empty token
 //
 //	token := NewToken()
@@ -52,11 +58,11 @@ func isExpired(str string) bool {
 	return true
 }
 
-// FromString return Token from string or expired
+// FromString return Token from string or expired, return ErrRevoked if token has been revoked
 //
-//	token, expired, err := FromString(str)
+//	token, expired, err := FromString(ctx, str)
 //
-func FromString(str string) (Token, bool, error) {
+func FromString(ctx context.Context, str string) (Token, bool, error) {
 	everything, err := crypto.Decrypt(str)
 	if err != nil {
 		return nil, false, errors.Wrapf(err, "decrypt %v", str)
@@ -68,17 +74,25 @@ func FromString(str string) (Token, bool, error) {
 	}
 
 	delete(content, keyExpired)
+	if id, ok := content[keyID].(string); ok {
+		if err := checkRevoked(ctx, id); err != nil {
+			return nil, false, err
+		}
+	}
 	return &BaseToken{
 		content: content,
 	}, false, nil
 }
 
-// ToString return string with expired time, after expired time the token will not read from string
+// ToString return string with expired time, after expired time the token will not read from string. token id will be generated if token does not have one
 //
 //	expired := time.Now().UTC().Add(60 * time.Second)
 //	str := token.ToString(expired)
 //
 func (c *BaseToken) ToString(expired time.Time) (string, error) {
+	if c.content[keyID] == nil {
+		c.content[keyID] = identifier.UUID()
+	}
 	c.content[keyExpired] = expired.Format(expiredFormat)
 	everything := mapping.ToString(c.content)
 	crypted, err := crypto.Encrypt(everything)
@@ -88,6 +102,14 @@ func (c *BaseToken) ToString(expired time.Time) (string, error) {
 	return crypted, nil
 }
 
+// TokenID return token unique id, it is empty before token convert to string
+//
+//	id := token.TokenID()
+//
+func (c *BaseToken) TokenID() string {
+	return c.Get(keyID)
+}
+
 // Get return value from key
 //
 //	value := token.Get("UserID")
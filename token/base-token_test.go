@@ -1,6 +1,7 @@
 package token
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -44,6 +45,7 @@ func TestGetSetDeleteToken(t *testing.T) {
 func TestTokenFromToString(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
+	ctx := context.Background()
 	token := NewToken()
 	token.Set("a", "1")
 	expired := time.Now().UTC().Add(60 * time.Second)
@@ -51,7 +53,7 @@ func TestTokenFromToString(t *testing.T) {
 	assert.Nil(err)
 	assert.NotEmpty(crypted)
 
-	token2, isExpired, err := FromString(crypted)
+	token2, isExpired, err := FromString(ctx, crypted)
 	assert.Nil(err)
 	assert.False(isExpired)
 
@@ -62,6 +64,7 @@ func TestTokenFromToString(t *testing.T) {
 func TestTokenExpired(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
+	ctx := context.Background()
 	token := NewToken()
 	token.Set("a", "1")
 
@@ -70,7 +73,7 @@ func TestTokenExpired(t *testing.T) {
 	assert.Nil(err)
 	assert.NotEmpty(crypted)
 
-	token2, isExpired, err := FromString(crypted)
+	token2, isExpired, err := FromString(ctx, crypted)
 	assert.Nil(err)
 	assert.True(isExpired)
 	assert.Nil(token2)
@@ -79,12 +82,13 @@ func TestTokenExpired(t *testing.T) {
 func TestInvalidToken(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
-	token, expired, err := FromString("")
+	ctx := context.Background()
+	token, expired, err := FromString(ctx, "")
 	assert.NotNil(err)
 	assert.False(expired)
 	assert.Nil(token)
 
-	token, expired, err = FromString("123213123")
+	token, expired, err = FromString(ctx, "123213123")
 	assert.NotNil(err)
 	assert.False(expired)
 	assert.Nil(token)
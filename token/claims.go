@@ -0,0 +1,252 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	crypto "github.com/piyuo/libsrv/crypto"
+	"github.com/piyuo/libsrv/identifier"
+	"github.com/pkg/errors"
+)
+
+// claimsPrefix is prefix of claims token, so it will not mix with BaseToken string
+//
+const claimsPrefix = "c1."
+
+// DefaultClockSkew is default time difference allowed between servers when verify token time
+//
+const DefaultClockSkew = 30 * time.Second
+
+// ErrExpired return when token expired
+//
+var ErrExpired = errors.New("token expired")
+
+// ErrNotYetValid return when token used before not before time
+//
+var ErrNotYetValid = errors.New("token not yet valid")
+
+// ErrWrongAudience return when token audience is not expected audience
+//
+var ErrWrongAudience = errors.New("token wrong audience")
+
+// ErrTampered return when token can not be decrypted or content is invalid
+//
+var ErrTampered = errors.New("token tampered")
+
+// Claims is token with typed claims, it is sealed by crypto package so it can not be read or tampered, use Verify() to read claims from string
+//
+//	claims := NewClaims("user1", "session")
+//	str, err := claims.ToString(time.Now().Add(time.Hour))
+//	claims, err = Verify(ctx, str, "session", DefaultClockSkew)
+//
+type Claims struct {
+	Token `json:"-"`
+
+	// ID is token unique id, it will be generated when token been sealed
+	//
+	ID string `json:"jti"`
+
+	// Subject is whom token refer to, like user id
+	//
+	Subject string `json:"sub,omitempty"`
+
+	// Audience is what token used for, like "session" or "email-verify", token only valid for the same audience
+	//
+	Audience string `json:"aud"`
+
+	// Family is id shared by tokens issued from the same login, revoke family will revoke all tokens in it
+	//
+	Family string `json:"fam,omitempty"`
+
+	// IssuedAt is time token sealed
+	//
+	IssuedAt time.Time `json:"-"`
+
+	// NotBefore is time token start valid, zero mean token is valid after issued
+	//
+	NotBefore time.Time `json:"-"`
+
+	// ExpiresAt is time token expired
+	//
+	ExpiresAt time.Time `json:"-"`
+
+	// Values is custom claims
+	//
+	Values map[string]string `json:"val,omitempty"`
+}
+
+// claimsTimes is claims time in unix seconds, used to marshal claims
+//
+type claimsTimes struct {
+	IssuedAt  int64 `json:"iat"`
+	NotBefore int64 `json:"nbf,omitempty"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// sealedClaims is claims content been sealed
+//
+type sealedClaims struct {
+	*Claims
+	claimsTimes
+}
+
+// NewClaims return claims with subject and audience
+//
+//	claims := NewClaims("user1", "session")
+//
+func NewClaims(subject, audience string) *Claims {
+	return &Claims{
+		Subject:  subject,
+		Audience: audience,
+		Values:   map[string]string{},
+	}
+}
+
+// ToString seal claims into url-safe string with expired time, unique id and issued time will be generated
+//
+//	str, err := claims.ToString(time.Now().UTC().Add(time.Hour))
+//
+func (c *Claims) ToString(expired time.Time) (string, error) {
+	c.ExpiresAt = expired
+	return Sign(c)
+}
+
+// TokenID return token unique id, it is empty before claims been sealed
+//
+//	id := claims.TokenID()
+//
+func (c *Claims) TokenID() string {
+	return c.ID
+}
+
+// Get return custom claim value from key
+//
+//	value := claims.Get("UserID")
+//
+func (c *Claims) Get(key string) string {
+	return c.Values[key]
+}
+
+// Set custom claim value to key
+//
+//	claims.Set("UserID","aa")
+//
+func (c *Claims) Set(key, value string) {
+	if c.Values == nil {
+		c.Values = map[string]string{}
+	}
+	c.Values[key] = value
+}
+
+// Delete custom claim
+//
+//	claims.Delete("UserID")
+//
+func (c *Claims) Delete(key string) {
+	delete(c.Values, key)
+}
+
+// Sign seal claims into url-safe string, claims must have expired time. unique id and issued time will be generated
+//
+//	str, err := Sign(claims)
+//
+func Sign(claims *Claims) (string, error) {
+	if claims.ExpiresAt.IsZero() {
+		return "", errors.New("claims must have expired time")
+	}
+	claims.ID = identifier.UUID()
+	claims.IssuedAt = time.Now().UTC().Truncate(time.Second)
+	content := sealedClaims{
+		Claims: claims,
+		claimsTimes: claimsTimes{
+			IssuedAt:  claims.IssuedAt.Unix(),
+			NotBefore: unixTime(claims.NotBefore),
+			ExpiresAt: claims.ExpiresAt.Unix(),
+		},
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal claims")
+	}
+	crypted, err := crypto.Encrypt(string(data))
+	if err != nil {
+		return "", errors.Wrap(err, "encrypt claims")
+	}
+	return claimsPrefix + crypto.URLEncode(crypted), nil
+}
+
+// Verify return claims from string, token must have expected audience and valid at current time, skew is time difference allowed between servers.
+// return ErrTampered, ErrWrongAudience, ErrNotYetValid, ErrExpired or ErrRevoked if token is invalid
+//
+//	claims, err := Verify(ctx, str, "session", DefaultClockSkew)
+//	if errors.Is(err, ErrExpired) {
+//	}
+//
+func Verify(ctx context.Context, str, audience string, skew time.Duration) (*Claims, error) {
+	return verifyAt(ctx, str, audience, skew, time.Now())
+}
+
+// verifyAt verify token at specific time
+//
+func verifyAt(ctx context.Context, str, audience string, skew time.Duration, now time.Time) (*Claims, error) {
+	claims, err := open(str)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Audience != audience {
+		return nil, errors.Wrapf(ErrWrongAudience, "want %v got %v", audience, claims.Audience)
+	}
+	if !claims.NotBefore.IsZero() && now.Add(skew).Before(claims.NotBefore) {
+		return nil, errors.Wrapf(ErrNotYetValid, "not before %v", claims.NotBefore)
+	}
+	if !now.Add(-skew).Before(claims.ExpiresAt) {
+		return nil, errors.Wrapf(ErrExpired, "expired at %v", claims.ExpiresAt)
+	}
+	if err := checkRevoked(ctx, claims.ID); err != nil {
+		return nil, err
+	}
+	if err := checkRevoked(ctx, claims.Family); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// open decrypt claims from string without verify time and audience
+//
+func open(str string) (*Claims, error) {
+	if !strings.HasPrefix(str, claimsPrefix) {
+		return nil, errors.Wrap(ErrTampered, "invalid prefix")
+	}
+	data, err := crypto.Decrypt(crypto.URLDecode(str[len(claimsPrefix):]))
+	if err != nil {
+		return nil, errors.Wrap(ErrTampered, err.Error())
+	}
+	content := sealedClaims{Claims: &Claims{}}
+	if err := json.Unmarshal([]byte(data), &content); err != nil {
+		return nil, errors.Wrap(ErrTampered, err.Error())
+	}
+	claims := content.Claims
+	if claims.ID == "" || content.claimsTimes.ExpiresAt == 0 {
+		return nil, errors.Wrap(ErrTampered, "missing claims")
+	}
+	claims.IssuedAt = time.Unix(content.claimsTimes.IssuedAt, 0).UTC()
+	if content.claimsTimes.NotBefore != 0 {
+		claims.NotBefore = time.Unix(content.claimsTimes.NotBefore, 0).UTC()
+	}
+	claims.ExpiresAt = time.Unix(content.claimsTimes.ExpiresAt, 0).UTC()
+	if claims.Values == nil {
+		claims.Values = map[string]string{}
+	}
+	return claims, nil
+}
+
+// unixTime return unix seconds of time, return 0 if time is zero
+//
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
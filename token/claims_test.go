@@ -0,0 +1,141 @@
+package token
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaims(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	claims := NewClaims("user1", "session")
+	claims.Set("a", "1")
+	expired := time.Now().UTC().Add(time.Hour)
+	str, err := claims.ToString(expired)
+	assert.Nil(err)
+	assert.NotEmpty(claims.ID)
+	assert.NotContains(str, "+")
+	assert.NotContains(str, "/")
+
+	claims2, err := Verify(ctx, str, "session", DefaultClockSkew)
+	assert.Nil(err)
+	assert.Equal(claims.ID, claims2.ID)
+	assert.Equal("user1", claims2.Subject)
+	assert.Equal("session", claims2.Audience)
+	assert.Equal("1", claims2.Get("a"))
+	assert.Equal(expired.Unix(), claims2.ExpiresAt.Unix())
+	assert.Equal(claims.IssuedAt, claims2.IssuedAt)
+	assert.True(claims2.NotBefore.IsZero())
+
+	claims2.Delete("a")
+	assert.Equal("", claims2.Get("a"))
+
+	// every token has unique id
+	str2, err := claims.ToString(expired)
+	assert.Nil(err)
+	claims3, err := Verify(ctx, str2, "session", DefaultClockSkew)
+	assert.Nil(err)
+	assert.NotEqual(claims2.ID, claims3.ID)
+
+	// claims is token
+	var token Token = NewClaims("user1", "session")
+	token.Set("b", "2")
+	assert.Equal("2", token.Get("b"))
+}
+
+func TestClaimsVerify(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	claims := NewClaims("user1", "email-verify")
+	claims.NotBefore = now.Add(time.Hour)
+	claims.ExpiresAt = now.Add(2 * time.Hour)
+	str, err := Sign(claims)
+	assert.Nil(err)
+
+	_, err = verifyAt(ctx, str, "session", DefaultClockSkew, now.Add(90*time.Minute))
+	assert.True(errors.Is(err, ErrWrongAudience))
+
+	_, err = verifyAt(ctx, str, "email-verify", DefaultClockSkew, now)
+	assert.True(errors.Is(err, ErrNotYetValid))
+
+	_, err = verifyAt(ctx, str, "email-verify", DefaultClockSkew, now.Add(90*time.Minute))
+	assert.Nil(err)
+
+	_, err = verifyAt(ctx, str, "email-verify", DefaultClockSkew, now.Add(3*time.Hour))
+	assert.True(errors.Is(err, ErrExpired))
+
+	// clock skew
+	_, err = verifyAt(ctx, str, "email-verify", time.Minute, now.Add(time.Hour-30*time.Second))
+	assert.Nil(err)
+	_, err = verifyAt(ctx, str, "email-verify", 0, now.Add(time.Hour-30*time.Second))
+	assert.True(errors.Is(err, ErrNotYetValid))
+	_, err = verifyAt(ctx, str, "email-verify", time.Minute, now.Add(2*time.Hour+30*time.Second))
+	assert.Nil(err)
+	_, err = verifyAt(ctx, str, "email-verify", 0, now.Add(2*time.Hour+30*time.Second))
+	assert.True(errors.Is(err, ErrExpired))
+}
+
+func TestClaimsTampered(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+	str, err := NewClaims("user1", "session").ToString(time.Now().Add(time.Hour))
+	assert.Nil(err)
+
+	middle := len(str) / 2
+	replace := "A"
+	if str[middle] == 'A' {
+		replace = "B"
+	}
+	_, err = Verify(ctx, str[:middle]+replace+str[middle+1:], "session", DefaultClockSkew)
+	assert.True(errors.Is(err, ErrTampered))
+
+	for _, invalid := range []string{"", "c1.", "c1.!!", "c1.AAAA", "something"} {
+		_, err = Verify(ctx, invalid, "session", DefaultClockSkew)
+		assert.True(errors.Is(err, ErrTampered))
+	}
+
+	// legacy token is not claims
+	legacy := NewToken()
+	legacy.Set("a", "1")
+	legacyStr, err := legacy.ToString(time.Now().Add(time.Hour))
+	assert.Nil(err)
+	_, err = Verify(ctx, legacyStr, "session", DefaultClockSkew)
+	assert.True(errors.Is(err, ErrTampered))
+
+	// must have expired time
+	_, err = Sign(NewClaims("user1", "session"))
+	assert.NotNil(err)
+}
+
+func TestClaimsFlippedByte(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	str, err := NewClaims("u", "session").ToString(time.Now().Add(time.Hour))
+	assert.Nil(err)
+	// sealed text is after last "." of claims and version prefix
+	head := str[:strings.LastIndex(str, ".")+1]
+	raw, err := base64.RawURLEncoding.DecodeString(str[len(head):])
+	assert.Nil(err)
+
+	for i := len(raw) - 1; i >= len(raw)-32; i-- {
+		flipped := append([]byte(nil), raw...)
+		flipped[i] ^= 1
+		claims, err := Verify(ctx, head+base64.RawURLEncoding.EncodeToString(flipped), "session", DefaultClockSkew)
+		assert.Nil(claims)
+		assert.True(errors.Is(err, ErrTampered))
+		// rejected by authentication, not decoded by legacy
+		assert.Contains(err.Error(), "open ciphertext")
+	}
+}
@@ -0,0 +1,205 @@
+package token
+
+import (
+	"context"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/identifier"
+	"github.com/pkg/errors"
+)
+
+// RefreshAudience is audience of refresh token, refresh token can only be used to get new token pair
+//
+const RefreshAudience = "refresh"
+
+// RefreshCollection is collection name of issued refresh token
+//
+const RefreshCollection = "TokenRefresh"
+
+// ErrReused return when refresh token has been used, all tokens in the same family will be revoked
+//
+var ErrReused = errors.New("refresh token reused")
+
+// RefreshRecord track issued refresh token so it can only be used once, id is refresh token id
+//
+type RefreshRecord struct {
+	db.Entity
+
+	// Family is token family id
+	//
+	Family string `firestore:"Family"`
+
+	// Subject is access token subject
+	//
+	Subject string `firestore:"Subject"`
+
+	// Audience is access token audience
+	//
+	Audience string `firestore:"Audience"`
+
+	// Values is access token custom claims
+	//
+	Values map[string]string `firestore:"Values,omitempty"`
+
+	// Used is true if refresh token has been used
+	//
+	Used bool `firestore:"Used"`
+
+	// Until is refresh token expired time
+	//
+	Until time.Time `firestore:"Until"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *RefreshRecord) Factory() db.Object {
+	return &RefreshRecord{}
+}
+
+// Collection return refresh collection name
+//
+func (c *RefreshRecord) Collection() string {
+	return RefreshCollection
+}
+
+// Pair is short-lived access token and long-lived refresh token, use refresh token to get new pair when access token expired
+//
+type Pair struct {
+
+	// Access is access token
+	//
+	Access string
+
+	// AccessExpires is access token expired time
+	//
+	AccessExpires time.Time
+
+	// Refresh is refresh token, it can only be used once
+	//
+	Refresh string
+
+	// RefreshExpires is refresh token expired time
+	//
+	RefreshExpires time.Time
+}
+
+// IssuePair issue access token from claims and refresh token in a new token family, refresh token is tracked using client set by UseRevocation()
+//
+//	pair, err := IssuePair(ctx, NewClaims("user1", "session"), 15*time.Minute, 30*24*time.Hour)
+//
+func IssuePair(ctx context.Context, access *Claims, accessDuration, refreshDuration time.Duration) (*Pair, error) {
+	client := getRevocationClient()
+	if client == nil {
+		return nil, errors.New("revocation client not set, call UseRevocation() first")
+	}
+	access.Family = identifier.UUID()
+	return issuePair(ctx, client, access, accessDuration, refreshDuration)
+}
+
+// RefreshPair use refresh token to issue new token pair in the same family, refresh token can only be used once. reuse refresh token return ErrReused and revoke all tokens in the family
+//
+//	pair, err := RefreshPair(ctx, refresh, 15*time.Minute, 30*24*time.Hour)
+//	if errors.Is(err, ErrReused) {
+//		// token stolen, ask user to login again
+//	}
+//
+func RefreshPair(ctx context.Context, refresh string, accessDuration, refreshDuration time.Duration) (*Pair, error) {
+	client := getRevocationClient()
+	if client == nil {
+		return nil, errors.New("revocation client not set, call UseRevocation() first")
+	}
+	claims, err := Verify(ctx, refresh, RefreshAudience, DefaultClockSkew)
+	if err != nil {
+		return nil, err
+	}
+
+	var record *RefreshRecord
+	reused := false
+	err = client.Transaction(ctx, func(ctx context.Context, tx db.Transaction) error {
+		obj, err := tx.Get(ctx, &RefreshRecord{}, claims.ID)
+		if err != nil {
+			return err
+		}
+		if obj == nil {
+			return errors.Wrapf(ErrTampered, "refresh token %v not issued", claims.ID)
+		}
+		record = obj.(*RefreshRecord)
+		if record.Used {
+			reused = true
+			return nil
+		}
+		return tx.Update(ctx, record, map[string]interface{}{"Used": true})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "use refresh token")
+	}
+	if reused {
+		until := time.Now().UTC().Add(maxDuration(accessDuration, refreshDuration))
+		if err := Revoke(ctx, record.Family, until); err != nil {
+			return nil, errors.Wrap(err, "revoke family")
+		}
+		return nil, errors.Wrapf(ErrReused, "family %v revoked", record.Family)
+	}
+
+	access := NewClaims(record.Subject, record.Audience)
+	for key, value := range record.Values {
+		access.Set(key, value)
+	}
+	access.Family = record.Family
+	return issuePair(ctx, client, access, accessDuration, refreshDuration)
+}
+
+// RevokeFamily revoke all tokens in the same family as token, like logout from one device
+//
+//	err := RevokeFamily(ctx, claims, 30*24*time.Hour)
+//
+func RevokeFamily(ctx context.Context, claims *Claims, refreshDuration time.Duration) error {
+	if claims.Family == "" {
+		return errors.New("token not in family")
+	}
+	return Revoke(ctx, claims.Family, time.Now().UTC().Add(refreshDuration))
+}
+
+// issuePair issue access token and refresh token in access token family
+//
+func issuePair(ctx context.Context, client db.Client, access *Claims, accessDuration, refreshDuration time.Duration) (*Pair, error) {
+	now := time.Now().UTC()
+	accessStr, err := access.ToString(now.Add(accessDuration))
+	if err != nil {
+		return nil, errors.Wrap(err, "issue access token")
+	}
+	refresh := NewClaims(access.Subject, RefreshAudience)
+	refresh.Family = access.Family
+	refreshStr, err := refresh.ToString(now.Add(refreshDuration))
+	if err != nil {
+		return nil, errors.Wrap(err, "issue refresh token")
+	}
+
+	record := &RefreshRecord{
+		Family:   access.Family,
+		Subject:  access.Subject,
+		Audience: access.Audience,
+		Values:   access.Values,
+		Until:    refresh.ExpiresAt,
+	}
+	record.SetID(refresh.ID)
+	if err := client.Set(ctx, record); err != nil {
+		return nil, errors.Wrap(err, "track refresh token")
+	}
+	return &Pair{
+		Access:         accessStr,
+		AccessExpires:  access.ExpiresAt,
+		Refresh:        refreshStr,
+		RefreshExpires: refresh.ExpiresAt,
+	}, nil
+}
+
+// maxDuration return longer duration
+//
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
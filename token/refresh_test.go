@@ -0,0 +1,89 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/mdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefresh(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	client, err := mdb.NewClient(ctx)
+	assert.Nil(err)
+
+	// revocation not set
+	_, err = IssuePair(ctx, NewClaims("user1", "session"), time.Minute, time.Hour)
+	assert.NotNil(err)
+
+	UseRevocation(client, RevocationOption{})
+	defer UseRevocation(nil, RevocationOption{})
+
+	access := NewClaims("user1", "session")
+	access.Set("role", "admin")
+	pair, err := IssuePair(ctx, access, time.Minute, time.Hour)
+	assert.Nil(err)
+	assert.True(pair.AccessExpires.Before(pair.RefreshExpires))
+
+	accessClaims, err := Verify(ctx, pair.Access, "session", DefaultClockSkew)
+	assert.Nil(err)
+	assert.NotEmpty(accessClaims.Family)
+
+	// refresh token can not used as access token
+	_, err = Verify(ctx, pair.Refresh, "session", DefaultClockSkew)
+	assert.True(errors.Is(err, ErrWrongAudience))
+
+	pair2, err := RefreshPair(ctx, pair.Refresh, time.Minute, time.Hour)
+	assert.Nil(err)
+	accessClaims2, err := Verify(ctx, pair2.Access, "session", DefaultClockSkew)
+	assert.Nil(err)
+	assert.Equal("user1", accessClaims2.Subject)
+	assert.Equal("admin", accessClaims2.Get("role"))
+	assert.Equal(accessClaims.Family, accessClaims2.Family)
+	assert.NotEqual(accessClaims.ID, accessClaims2.ID)
+
+	// access token is not refresh token
+	_, err = RefreshPair(ctx, pair2.Access, time.Minute, time.Hour)
+	assert.True(errors.Is(err, ErrWrongAudience))
+
+	// reuse refresh token revoke whole family
+	_, err = RefreshPair(ctx, pair.Refresh, time.Minute, time.Hour)
+	assert.True(errors.Is(err, ErrReused))
+	_, err = Verify(ctx, pair2.Access, "session", DefaultClockSkew)
+	assert.True(errors.Is(err, ErrRevoked))
+	_, err = RefreshPair(ctx, pair2.Refresh, time.Minute, time.Hour)
+	assert.True(errors.Is(err, ErrRevoked))
+
+	// other family not affected
+	other, err := IssuePair(ctx, NewClaims("user1", "session"), time.Minute, time.Hour)
+	assert.Nil(err)
+	otherClaims, err := Verify(ctx, other.Access, "session", DefaultClockSkew)
+	assert.Nil(err)
+
+	// logout
+	err = RevokeFamily(ctx, otherClaims, time.Hour)
+	assert.Nil(err)
+	_, err = RefreshPair(ctx, other.Refresh, time.Minute, time.Hour)
+	assert.True(errors.Is(err, ErrRevoked))
+	err = RevokeFamily(ctx, NewClaims("user1", "session"), time.Hour)
+	assert.NotNil(err)
+}
+
+func TestRefreshNotIssued(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	client, err := mdb.NewClient(ctx)
+	assert.Nil(err)
+	UseRevocation(client, RevocationOption{})
+	defer UseRevocation(nil, RevocationOption{})
+
+	// refresh token not tracked
+	refresh, err := NewClaims("user1", RefreshAudience).ToString(time.Now().Add(time.Hour))
+	assert.Nil(err)
+	_, err = RefreshPair(ctx, refresh, time.Minute, time.Hour)
+	assert.True(errors.Is(err, ErrTampered))
+}
@@ -0,0 +1,214 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/piyuo/libsrv/cache"
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/log"
+	"github.com/pkg/errors"
+)
+
+// RevocationCollection is collection name of revoked token
+//
+const RevocationCollection = "TokenRevocation"
+
+// revocationCachePrefix is prefix of revocation cache key
+//
+const revocationCachePrefix = "TR-"
+
+// ErrRevoked return when token has been revoked
+//
+var ErrRevoked = errors.New("token revoked")
+
+// Revocation is revoked token record, id is token id
+//
+type Revocation struct {
+	db.Entity
+
+	// Until is time revocation no longer needed, it should be token expired time
+	//
+	Until time.Time `firestore:"Until"`
+}
+
+// Factory create a empty object, return object must be nil safe, no nil in any field
+//
+func (c *Revocation) Factory() db.Object {
+	return &Revocation{}
+}
+
+// Collection return revocation collection name
+//
+func (c *Revocation) Collection() string {
+	return RevocationCollection
+}
+
+// RevocationOption define how revocation is checked when token is read, zero value use default
+//
+type RevocationOption struct {
+
+	// Timeout is max time to read revocation from database, default is 2 seconds
+	//
+	Timeout time.Duration
+
+	// FailOpen accept token when revocation can not be read from database, default is false mean reject token with error
+	//
+	FailOpen bool
+
+	// CacheDuration is how long a not revoked result is cached, token revoked on other server is still accepted on this server until cache expired, default is 1 minute, -1 mean no cache
+	//
+	CacheDuration time.Duration
+}
+
+// withDefault return option with default value
+//
+func (c RevocationOption) withDefault() RevocationOption {
+	if c.Timeout <= 0 {
+		c.Timeout = 2 * time.Second
+	}
+	if c.CacheDuration == 0 {
+		c.CacheDuration = time.Minute
+	}
+	return c
+}
+
+// revocationClient is db client used to store revocation, revocation check is disabled if it is nil
+//
+var revocationClient db.Client
+
+// revocationOption is option used to check revocation
+//
+var revocationOption = RevocationOption{}.withDefault()
+
+// revocationMutex protect revocationClient and revocationOption
+//
+var revocationMutex sync.RWMutex
+
+// UseRevocation set db client used to store revocation, FromString() and Verify() will reject revoked token. set nil to disable revocation check
+//
+//	token.UseRevocation(client, token.RevocationOption{Timeout: time.Second})
+//
+func UseRevocation(client db.Client, option RevocationOption) {
+	revocationMutex.Lock()
+	defer revocationMutex.Unlock()
+	revocationClient = client
+	revocationOption = option.withDefault()
+}
+
+// getRevocationClient return db client used to store revocation, return nil if revocation check is disabled
+//
+func getRevocationClient() db.Client {
+	client, _ := getRevocation()
+	return client
+}
+
+// getRevocation return db client and option used to check revocation, client is nil if revocation check is disabled
+//
+func getRevocation() (db.Client, RevocationOption) {
+	revocationMutex.RLock()
+	defer revocationMutex.RUnlock()
+	return revocationClient, revocationOption
+}
+
+// Revoke token by id until time, until should be token expired time, revoked token can not read by FromString() and Verify()
+//
+//	err := token.Revoke(ctx, claims.ID, claims.ExpiresAt)
+//
+func Revoke(ctx context.Context, id string, until time.Time) error {
+	client := getRevocationClient()
+	if client == nil {
+		return errors.New("revocation client not set, call UseRevocation() first")
+	}
+	if id == "" {
+		return errors.New("token id must not empty")
+	}
+	revocation := &Revocation{Until: until.UTC()}
+	revocation.SetID(id)
+	if err := client.Set(ctx, revocation); err != nil {
+		return errors.Wrapf(err, "revoke %v", id)
+	}
+	cacheRevoked(id, true, time.Until(until))
+	return nil
+}
+
+// IsRevoked return true if token id has been revoked, result is cached so check will not always read database, read is bounded by RevocationOption.Timeout. return false if revocation check is disabled
+//
+//	revoked, err := token.IsRevoked(ctx, id)
+//
+func IsRevoked(ctx context.Context, id string) (bool, error) {
+	client, option := getRevocation()
+	if client == nil || id == "" {
+		return false, nil
+	}
+	found, value, err := cache.GetString(revocationCachePrefix + id)
+	if err != nil {
+		return false, errors.Wrap(err, "get revocation cache")
+	}
+	if found {
+		return value == "1", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, option.Timeout)
+	defer cancel()
+	obj, err := client.Get(ctx, &Revocation{}, id)
+	if err != nil {
+		return false, errors.Wrapf(err, "get revocation %v", id)
+	}
+	if obj == nil || !obj.(*Revocation).Until.After(time.Now()) {
+		if option.CacheDuration > 0 {
+			cacheRevoked(id, false, option.CacheDuration)
+		}
+		return false, nil
+	}
+	until := obj.(*Revocation).Until
+	cacheRevoked(id, true, time.Until(until))
+	return true, nil
+}
+
+// CleanupRevocation delete revocation no longer needed, max is max revocation to delete, return true if all revocation deleted
+//
+//	done, err := token.CleanupRevocation(ctx, 100)
+//
+func CleanupRevocation(ctx context.Context, max int) (bool, error) {
+	client := getRevocationClient()
+	if client == nil {
+		return false, errors.New("revocation client not set, call UseRevocation() first")
+	}
+	done, _, err := client.Query(&Revocation{}).Where("Until", "<", time.Now().UTC()).Delete(ctx, max)
+	if err != nil {
+		return false, errors.Wrap(err, "delete revocation")
+	}
+	return done, nil
+}
+
+// cacheRevoked cache revocation check result, duration less than a second is cached for a second
+//
+func cacheRevoked(id string, revoked bool, d time.Duration) {
+	if d < time.Second {
+		d = time.Second
+	}
+	value := "0"
+	if revoked {
+		value = "1"
+	}
+	cache.SetString(revocationCachePrefix+id, value, d)
+}
+
+// checkRevoked return ErrRevoked if token id has been revoked. if revocation can not be read, error is returned unless RevocationOption.FailOpen is true
+//
+func checkRevoked(ctx context.Context, id string) error {
+	revoked, err := IsRevoked(ctx, id)
+	if err != nil {
+		if _, option := getRevocation(); option.FailOpen {
+			log.Warn(ctx, "accept token without revocation check: %v", err)
+			return nil
+		}
+		return err
+	}
+	if revoked {
+		return errors.Wrapf(ErrRevoked, "token %v", id)
+	}
+	return nil
+}
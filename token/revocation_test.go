@@ -0,0 +1,161 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/piyuo/libsrv/db"
+	"github.com/piyuo/libsrv/mdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocation(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	client, err := mdb.NewClient(ctx)
+	assert.Nil(err)
+
+	// revocation not set
+	err = Revoke(ctx, "id", time.Now().Add(time.Hour))
+	assert.NotNil(err)
+	revoked, err := IsRevoked(ctx, "id")
+	assert.Nil(err)
+	assert.False(revoked)
+
+	UseRevocation(client, RevocationOption{})
+	defer UseRevocation(nil, RevocationOption{})
+
+	expired := time.Now().UTC().Add(time.Hour)
+	token := NewToken()
+	token.Set("a", "1")
+	str, err := token.ToString(expired)
+	assert.Nil(err)
+	assert.NotEmpty(token.TokenID())
+	token2, _, err := FromString(ctx, str)
+	assert.Nil(err)
+	assert.Equal(token.TokenID(), token2.TokenID())
+
+	err = Revoke(ctx, token.TokenID(), expired)
+	assert.Nil(err)
+	token2, isExpired, err := FromString(ctx, str)
+	assert.True(errors.Is(err, ErrRevoked))
+	assert.False(isExpired)
+	assert.Nil(token2)
+
+	// claims
+	claims := NewClaims("user1", "session")
+	str, err = claims.ToString(expired)
+	assert.Nil(err)
+	_, err = Verify(ctx, str, "session", DefaultClockSkew)
+	assert.Nil(err)
+	err = Revoke(ctx, claims.TokenID(), claims.ExpiresAt)
+	assert.Nil(err)
+	_, err = Verify(ctx, str, "session", DefaultClockSkew)
+	assert.True(errors.Is(err, ErrRevoked))
+
+	err = Revoke(ctx, "", expired)
+	assert.NotNil(err)
+}
+
+func TestRevocationCache(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	client, err := mdb.NewClient(ctx)
+	assert.Nil(err)
+	UseRevocation(client, RevocationOption{})
+	defer UseRevocation(nil, RevocationOption{})
+
+	// revocation written by other server is read from database
+	revocation := &Revocation{Until: time.Now().UTC().Add(time.Hour)}
+	revocation.SetID("other-server")
+	err = client.Set(ctx, revocation)
+	assert.Nil(err)
+	revoked, err := IsRevoked(ctx, "other-server")
+	assert.Nil(err)
+	assert.True(revoked)
+
+	// not revoked result is cached
+	revoked, err = IsRevoked(ctx, "cached")
+	assert.Nil(err)
+	assert.False(revoked)
+	revocation = &Revocation{Until: time.Now().UTC().Add(time.Hour)}
+	revocation.SetID("cached")
+	err = client.Set(ctx, revocation)
+	assert.Nil(err)
+	revoked, err = IsRevoked(ctx, "cached")
+	assert.Nil(err)
+	assert.False(revoked)
+
+	// revocation no longer needed
+	revocation = &Revocation{Until: time.Now().UTC().Add(-time.Hour)}
+	revocation.SetID("old")
+	err = client.Set(ctx, revocation)
+	assert.Nil(err)
+	revoked, err = IsRevoked(ctx, "old")
+	assert.Nil(err)
+	assert.False(revoked)
+
+	done, err := CleanupRevocation(ctx, 100)
+	assert.Nil(err)
+	assert.True(done)
+	obj, err := client.Get(ctx, &Revocation{}, "old")
+	assert.Nil(err)
+	assert.Nil(obj)
+	obj, err = client.Get(ctx, &Revocation{}, "cached")
+	assert.Nil(err)
+	assert.NotNil(obj)
+}
+
+// slowClient block on Get until ctx is done
+//
+type slowClient struct {
+	db.Client
+}
+
+func (c *slowClient) Get(ctx context.Context, obj db.Object, id string) (db.Object, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRevocationOption(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	claims := NewClaims("user1", "session")
+	str, err := claims.ToString(time.Now().UTC().Add(time.Hour))
+	assert.Nil(err)
+
+	// fail closed by default, lookup is bounded by timeout
+	UseRevocation(&slowClient{}, RevocationOption{Timeout: 10 * time.Millisecond})
+	defer UseRevocation(nil, RevocationOption{})
+	_, err = Verify(ctx, str, "session", DefaultClockSkew)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+
+	// caller ctx is used
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	UseRevocation(&slowClient{}, RevocationOption{Timeout: time.Minute})
+	_, err = Verify(canceled, str, "session", DefaultClockSkew)
+	assert.True(errors.Is(err, context.Canceled))
+
+	// fail open
+	UseRevocation(&slowClient{}, RevocationOption{Timeout: 10 * time.Millisecond, FailOpen: true})
+	_, err = Verify(ctx, str, "session", DefaultClockSkew)
+	assert.Nil(err)
+
+	// not revoked result is not cached
+	client, err := mdb.NewClient(ctx)
+	assert.Nil(err)
+	UseRevocation(client, RevocationOption{CacheDuration: -1})
+	revoked, err := IsRevoked(ctx, "no-cache")
+	assert.Nil(err)
+	assert.False(revoked)
+	revocation := &Revocation{Until: time.Now().UTC().Add(time.Hour)}
+	revocation.SetID("no-cache")
+	err = client.Set(ctx, revocation)
+	assert.Nil(err)
+	revoked, err = IsRevoked(ctx, "no-cache")
+	assert.Nil(err)
+	assert.True(revoked)
+}
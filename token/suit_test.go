@@ -3,11 +3,23 @@ package token
 import (
 	"os"
 	"testing"
+
+	crypto "github.com/piyuo/libsrv/crypto"
 )
 
 func TestMain(m *testing.M) {
-	//	setup()
+	setup()
 	code := m.Run()
 	//	shutdown()
 	os.Exit(code)
 }
+
+// setup use test keyring so token can be sealed without key file
+//
+func setup() {
+	keyring, err := crypto.NewKeyring("test", map[string][]byte{"test": []byte("0123456789abcdef")})
+	if err != nil {
+		panic(err)
+	}
+	crypto.SetKeyring(keyring)
+}
@@ -13,6 +13,12 @@ type Token interface {
 	//
 	ToString(expired time.Time) (string, error)
 
+	// TokenID return token unique id, it is generated when token convert to string, use it to revoke token
+	//
+	//	err := Revoke(ctx, token.TokenID(), expired)
+	//
+	TokenID() string
+
 	// Get return value from key
 	//
 	//	value := token.Get("UserID")